@@ -6,23 +6,31 @@ import (
 	"encoding/json"
 	"flag"
 	"fmt"
+	"io"
 	"log"
 	"os"
+	"path"
+	"strings"
 	"time"
 
+	"go.burian.dev/c4/cmd/compiler/internal/checker"
 	"go.burian.dev/c4/cmd/compiler/internal/lexer"
 	"go.burian.dev/c4/cmd/compiler/internal/loader"
 	"go.burian.dev/c4/cmd/compiler/internal/parser"
+	"go.burian.dev/c4/cmd/compiler/internal/structurizr"
 )
 
 type compiler struct {
 	sources    map[string][]byte
 	tokens     map[string]*lexer.LexedSource
 	workspaces map[string]*parser.Workspace
+	themes     map[string]*parser.Styles
 
-	loader loader.Loader
-	lexer  *lexer.Lexer
-	parser *parser.Parser
+	loader  loader.Loader
+	lock    *loader.Lock
+	lexer   *lexer.Lexer
+	parser  *parser.Parser
+	checker *checker.Checker
 
 	context context.Context
 
@@ -36,28 +44,66 @@ type compileConfig struct {
 
 	quiet      bool
 	jsonPretty bool
+	format     string
 }
 
 var (
-	defaultLoader = loader.NewLoader()
-	defaultLexer  = new(lexer.Lexer)
-	defaultParser = new(parser.Parser)
+	defaultLoader  = loader.NewLoader()
+	defaultLexer   = new(lexer.Lexer)
+	defaultParser  = new(parser.Parser)
+	defaultChecker = new(checker.Checker)
 )
 
+// subcommands are named by the first argument, and otherwise the
+// arguments are a target to compile
+var commands = map[string]func(args []string) int{
+	"convert": runConvert,
+	"fmt":     runFmt,
+	"lock":    runLock,
+	"lsp":     runLsp,
+}
+
 func main() {
+	if len(os.Args) > 1 {
+		if command, ok := commands[os.Args[1]]; ok {
+			os.Exit(command(os.Args[2:]))
+		}
+	}
 
 	comp := new(compiler)
+	remote := new(remoteFlags)
 
-	flag.StringVar(&comp.outputFile, "out", "out.c4m", "set the output file for compilation")
+	flag.StringVar(&comp.outputFile, "out", "", "set the output file for compilation, or the output directory for formats with a file per view")
+	flag.StringVar(&comp.format, "format", formatJson, "set the output format: "+strings.Join(outputFormats(), ", "))
 	flag.BoolVar(&comp.quiet, "quiet", false, "only print error messages")
+	flag.BoolVar(&remote.allow, "allow-remote", false, "allow #fetch to load sources from remote hosts")
+	remote.register(flag.CommandLine)
+	flag.StringVar(&remote.cacheDir, "cache-dir", defaultCacheDir(), "set the directory to cache fetched sources in, or set it empty to not cache them")
+	flag.BoolVar(&remote.offline, "offline", false, "use cached sources, even if they're stale, when they can't be fetched")
+	maxIncludeDepth := flag.Int("max-include-depth", parser.DefaultMaxIncludeDepth, "set how deeply #include and #fetch may be nested")
 	flag.Parse()
 
+	comp.parser = &parser.Parser{MaxIncludeDepth: *maxIncludeDepth}
+
+	if comp.outputFile == "" {
+		comp.outputFile = defaultOutput(comp.format)
+	}
+
 	target := flag.Arg(0)
 	if target == "" {
 		flag.Usage()
 		return
 	}
 
+	if remote.allow {
+		lock, err := loader.ReadLock(lockPathFor(target))
+		if err != nil {
+			log.Fatalf("Compilation failed: %s", err)
+		}
+		comp.lock = lock
+		comp.loader = remote.loader(lock)
+	}
+
 	err := comp.Run(target)
 	if err != nil {
 		comp.logger.Fatalf("Compilation failed: %s", err)
@@ -74,8 +120,7 @@ func (comp *compiler) Run(target string) error {
 
 	comp.logger.Println("Starting")
 
-	// TODO The compiler's behaviour should be to run check not parse
-	workspace, err := comp.GetWorkspaceFor(target)
+	workspace, err := comp.GetCheckedWorkspaceFor(target)
 	if err != nil {
 		return fmt.Errorf("error compiling: %s", comp.prettyPrintError(err))
 	}
@@ -85,6 +130,12 @@ func (comp *compiler) Run(target string) error {
 		return fmt.Errorf("error writing compiled workspace: %s", comp.prettyPrintError(err))
 	}
 
+	if comp.lock != nil {
+		if err := comp.lock.Save(); err != nil {
+			return fmt.Errorf("error locking fetched sources: %s", err)
+		}
+	}
+
 	comp.logger.Println("Compiled successfully")
 	comp.logger.Printf("Wrote file to %s\n", comp.outputFile)
 
@@ -157,6 +208,9 @@ func (c *compiler) GetWorkspaceFor(target string) (*parser.Workspace, error) {
 		return workspace, nil
 	}
 
+	var workspace *parser.Workspace
+	var err error
+
 	var parser *parser.Parser
 	if c.parser != nil {
 		parser = c.parser
@@ -164,8 +218,14 @@ func (c *compiler) GetWorkspaceFor(target string) (*parser.Workspace, error) {
 		parser = defaultParser
 	}
 
-	c.logger.Printf("Parsing new workspace %s\n", target)
-	workspace, err := parser.Run(target, c)
+	if path.Ext(target) == structurizr.Extension {
+		c.logger.Printf("Importing new Structurizr workspace %s\n", target)
+		workspace, err = c.importWorkspace(target)
+	} else {
+		c.logger.Printf("Parsing new workspace %s\n", target)
+		workspace, err = parser.Run(target, c)
+		c.logWarnings(parser.Warnings())
+	}
 	if err != nil {
 		return nil, err
 	}
@@ -174,7 +234,103 @@ func (c *compiler) GetWorkspaceFor(target string) (*parser.Workspace, error) {
 	return workspace, nil
 }
 
+// importWorkspace reads a workspace from Structurizr's JSON format rather
+// than from the DSL
+func (c *compiler) importWorkspace(target string) (*parser.Workspace, error) {
+	source, err := c.GetSourceFor(target)
+	if err != nil {
+		return nil, err
+	}
+	doc, err := io.ReadAll(source)
+	if err != nil {
+		return nil, err
+	}
+	workspace, err := structurizr.Import(doc)
+	if err != nil {
+		return nil, fmt.Errorf("error importing %s:\n> %w", target, err)
+	}
+	return workspace, nil
+}
+
+func (c *compiler) GetCheckedWorkspaceFor(target string) (*parser.Workspace, error) {
+	workspace, err := c.GetWorkspaceFor(target)
+	if err != nil {
+		return nil, err
+	}
+
+	var checker *checker.Checker
+	if c.checker != nil {
+		checker = c.checker
+	} else {
+		checker = defaultChecker
+	}
+
+	c.logger.Printf("Checking workspace %s\n", target)
+	err = checker.CheckWorkspaces([]*parser.Workspace{workspace}, c)
+	if err != nil {
+		return nil, err
+	}
+
+	return workspace, nil
+}
+
+func (c *compiler) GetThemeFor(target string) (*parser.Styles, error) {
+	if c.themes == nil {
+		c.themes = make(map[string]*parser.Styles)
+	}
+
+	if theme, has := c.themes[target]; has {
+		c.logger.Printf("Fetching cached theme %s\n", target)
+		return theme, nil
+	}
+
+	var parser *parser.Parser
+	if c.parser != nil {
+		parser = c.parser
+	} else {
+		parser = defaultParser
+	}
+
+	c.logger.Printf("Parsing new theme %s\n", target)
+	theme, err := parser.RunTheme(target, c)
+	c.logWarnings(parser.Warnings())
+	if err != nil {
+		return nil, err
+	}
+
+	c.themes[target] = theme
+	return theme, nil
+}
+
+func (c *compiler) logWarnings(warnings []error) {
+	for _, w := range warnings {
+		c.logger.Printf("Warning: %s\n", c.prettyPrintError(w))
+	}
+}
+
+func (c *compiler) DeclarationOf(x any) *lexer.Token {
+	if c.parser != nil {
+		return c.parser.DeclarationOf(x)
+	}
+	return defaultParser.DeclarationOf(x)
+}
+
 func (c *compiler) WriteOutput(w *parser.Workspace) error {
+	switch format := c.format; format {
+	case "", formatJson:
+		return c.writeJson(w)
+	default:
+		if view, ok := viewFormats[format]; ok {
+			return c.writeViews(w, view)
+		}
+		if whole, ok := workspaceFormats[format]; ok {
+			return c.writeWorkspace(w, whole)
+		}
+		return fmt.Errorf("unknown output format %s", format)
+	}
+}
+
+func (c *compiler) writeJson(w *parser.Workspace) error {
 	file, err := os.Create(c.outputFile)
 	if err != nil {
 		return err
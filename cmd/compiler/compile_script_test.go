@@ -32,11 +32,15 @@ func (al *archiveLoader) Load(_ context.Context, target string) ([]byte, error)
 
 type testCase struct {
 	target      string
+	outDir      string
 	outFile     string
 	expectErr   string
+	errMatches  []string
 	matchFile   string
+	matchFiles  []string
 	compareWith string
 	jsonPretty  string
+	format      string
 
 	archive *txtar.Archive
 }
@@ -47,9 +51,12 @@ func SetupTest(t *testing.T, directives textproto.MIMEHeader) (*testCase, error)
 
 		outFile:     directives.Get("Output-File"),
 		matchFile:   directives.Get("Output-Match"),
+		matchFiles:  directives.Values("Output-Match"),
 		compareWith: directives.Get("Compare-With"),
+		format:      directives.Get("Output-Format"),
 
 		expectErr:  directives.Get("Should-Error"),
+		errMatches: directives.Values("Error-Match"),
 		jsonPretty: directives.Get("Json-Pretty"),
 	}
 
@@ -57,7 +64,8 @@ func SetupTest(t *testing.T, directives textproto.MIMEHeader) (*testCase, error)
 		tt.outFile = "_out.c4c"
 	}
 
-	tt.outFile = filepath.Join(t.TempDir(), tt.outFile)
+	tt.outDir = t.TempDir()
+	tt.outFile = filepath.Join(tt.outDir, tt.outFile)
 
 	if tt.compareWith == "" {
 		tt.compareWith = "json"
@@ -70,52 +78,82 @@ func (tc *testCase) Check(t *testing.T, err error) {
 	if err != nil {
 		if tc.expectErr != "" {
 			t.Logf("expected error occurred\n%s", err)
+			for _, want := range tc.errMatches {
+				if !strings.Contains(err.Error(), want) {
+					t.Errorf("expected the error to include %q", want)
+				}
+			}
 			return
 		}
 		t.Errorf("compile error occured: %s", err)
 		return
 	}
 
-	if tc.matchFile != "" {
-		var matchBytes []byte
-		for _, file := range tc.archive.Files {
-			if file.Name == tc.matchFile {
-				matchBytes = file.Data
-				break
-			}
-		}
-		if matchBytes == nil {
-			t.Errorf("Specified output match %s not present in archive", tc.matchFile)
-		}
+	if tc.expectErr != "" {
+		t.Errorf("expected compile error did not occur")
+		return
+	}
+
+	if tc.matchFile == "" {
+		return
+	}
+
+	switch tc.compareWith {
+	case "json":
+		matchBytes := tc.archiveFile(t, tc.matchFile)
 
-		var gotBytes []byte
-		gotBytes, err = os.ReadFile(tc.outFile)
+		gotBytes, err := os.ReadFile(tc.outFile)
 		if err != nil {
 			t.Fatalf("unable to read compiled output: %s", err)
 		}
 
-		switch tc.compareWith {
-		case "json":
-			var want, got map[string]any
-			err = json.Unmarshal(matchBytes, &want)
+		var want, got map[string]any
+		err = json.Unmarshal(matchBytes, &want)
+		if err != nil {
+			t.Fatalf("error interpreting expected output as JSON: %s", err)
+		}
+		err = json.Unmarshal(gotBytes, &got)
+		if err != nil {
+			t.Fatalf("error interpreting compile output as JSON: %s", err)
+		}
+
+		compareObjects(t, nil, want, got)
+		// if !reflect.DeepEqual(want, got) {
+		// 	t.Error("compiled output JSON does not match expected")
+		// }
+
+	case "text":
+		// each match file is compared with the file at the same path
+		// in the output directory
+		for _, name := range tc.matchFiles {
+			matchBytes := tc.archiveFile(t, name)
+
+			gotBytes, err := os.ReadFile(filepath.Join(tc.outDir, name))
 			if err != nil {
-				t.Fatalf("error interpreting expected output as JSON: %s", err)
+				t.Errorf("unable to read compiled output: %s", err)
+				continue
 			}
-			err = json.Unmarshal(gotBytes, &got)
-			if err != nil {
-				t.Fatalf("error interpreting compile output as JSON: %s", err)
+
+			if !bytes.Equal(matchBytes, gotBytes) {
+				t.Errorf("compiled output %s does not match expected", name)
+				t.Logf("want:\n%s", matchBytes)
+				t.Logf("got:\n%s", gotBytes)
 			}
+		}
 
-			compareObjects(t, nil, want, got)
-			// if !reflect.DeepEqual(want, got) {
-			// 	t.Error("compiled output JSON does not match expected")
-			// }
+	default:
+		t.Errorf("unknown comparison method: %s", tc.compareWith)
+	}
+}
 
-		default:
-			t.Errorf("unknown comparison method: %s", tc.compareWith)
+func (tc *testCase) archiveFile(t *testing.T, name string) []byte {
+	for _, file := range tc.archive.Files {
+		if file.Name == name {
+			return file.Data
 		}
 	}
-
+	t.Errorf("Specified output match %s not present in archive", name)
+	return nil
 }
 
 func Test_CompileScripts(t *testing.T) {
@@ -174,6 +212,7 @@ func runScript(t *testing.T, file string) {
 	if tt.jsonPretty != "" {
 		c.jsonPretty = true
 	}
+	c.format = tt.format
 
 	err = c.Run(tt.target)
 
@@ -205,6 +244,21 @@ func compareObjects(t *testing.T, path []string, a, b any) {
 		}
 	}
 
+	if aSlice, ok := a.([]any); ok {
+		bSlice := b.([]any)
+
+		if len(aSlice) != len(bSlice) {
+			t.Errorf("at %s array lengths don't match", strings.Join(path, "."))
+			t.Logf("a = %d", len(aSlice))
+			t.Logf("b = %d", len(bSlice))
+			return
+		}
+
+		for i := range aSlice {
+			compareObjects(t, append(path, fmt.Sprint(i)), aSlice[i], bSlice[i])
+		}
+	}
+
 	if aStr, ok := a.(string); ok {
 		bStr := b.(string)
 
@@ -0,0 +1,60 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"go.burian.dev/c4/cmd/compiler/internal/convert"
+)
+
+// runConvert translates files of Structurizr's DSL into this dialect,
+// reporting anything that couldn't be translated
+func runConvert(args []string) int {
+	flags := flag.NewFlagSet("convert", flag.ExitOnError)
+	write := flags.Bool("w", false, "write each converted file beside its source with the .c4 extension, instead of to standard output")
+	flags.Usage = func() {
+		fmt.Fprintf(flags.Output(), "usage: %s convert [-w] file.dsl ...\n", filepath.Base(os.Args[0]))
+		flags.PrintDefaults()
+	}
+	flags.Parse(args)
+
+	if flags.NArg() == 0 {
+		flags.Usage()
+		return 2
+	}
+
+	status := 0
+	for _, name := range flags.Args() {
+		source, err := os.ReadFile(name)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "error converting %s:\n> %s\n", name, err)
+			status = 1
+			continue
+		}
+
+		converted, problems := convert.Convert(name, source)
+		for _, problem := range problems {
+			fmt.Fprintln(os.Stderr, problem)
+			status = 1
+		}
+
+		if !*write {
+			os.Stdout.Write(converted)
+			continue
+		}
+		out := strings.TrimSuffix(name, filepath.Ext(name)) + ".c4"
+		if out == name {
+			fmt.Fprintf(os.Stderr, "error writing %s: it would overwrite its source\n", out)
+			status = 1
+			continue
+		}
+		if err := os.WriteFile(out, converted, 0o644); err != nil {
+			fmt.Fprintf(os.Stderr, "error writing %s:\n> %s\n", out, err)
+			status = 1
+		}
+	}
+	return status
+}
@@ -17,6 +17,20 @@ type CodeError interface {
 
 func (c *compiler) prettyPrintError(e error) string {
 
+	// the lexer and the parser carry on past errors, so there may be many
+	// to print
+	var list interface{ Unwrap() []error }
+	if errors.As(e, &list) && len(list.Unwrap()) > 1 {
+		errs := list.Unwrap()
+		buf := new(strings.Builder)
+		fmt.Fprintf(buf, "%d errors:\n", len(errs))
+		for _, err := range errs {
+			buf.WriteRune('\n')
+			buf.WriteString(c.prettyPrintError(err))
+		}
+		return buf.String()
+	}
+
 	var ce CodeError
 	if !errors.As(e, &ce) {
 		return e.Error()
@@ -80,6 +94,11 @@ func writeCodeContext(buf *strings.Builder, source *bytes.Reader, tok *lexer.Tok
 	}
 
 	endCode := pos.End.ByteOffset
+	// tokens over several lines, like unterminated strings, are shown by
+	// their first
+	if pos.End.Line > pos.Start.Line {
+		endCode = pos.Start.ByteOffset + 1
+	}
 
 	// expand to cover the rest of the line
 	// unless we start just after the end of a line
@@ -134,11 +153,13 @@ func writeCodeContext(buf *strings.Builder, source *bytes.Reader, tok *lexer.Tok
 		lineStarts = append(lineStarts, startCode)
 
 	}
-	lineStarts = append(lineStarts, len(code))
+	if lineStarts[len(lineStarts)-1] < len(code) {
+		lineStarts = append(lineStarts, len(code))
+	}
 
-	lineNo = pos.End.Line + 1
+	lineNo = pos.Start.Line + 1
 	for i := 0; i < len(lineStarts)-1; i++ {
-		l := string(code[lineStarts[i] : lineStarts[i+1]-1])
+		l := strings.TrimSuffix(string(code[lineStarts[i]:lineStarts[i+1]]), "\n")
 		fmt.Fprintf(buf, lineFormat, fileName, lineNo+i, strings.ReplaceAll(l, "\t", tabReplacement))
 	}
 
@@ -0,0 +1,96 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"flag"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"path/filepath"
+	"time"
+
+	"go.burian.dev/c4/cmd/compiler/internal/formatter"
+)
+
+const standardInput = "<standard input>"
+
+// runFmt formats files canonically, like gofmt, printing the result unless
+// asked to list, diff, or rewrite the files that aren't formatted
+func runFmt(args []string) int {
+	flags := flag.NewFlagSet("fmt", flag.ExitOnError)
+	list := flags.Bool("l", false, "list files whose formatting differs from fmt's")
+	diff := flags.Bool("d", false, "print diffs of files whose formatting differs from fmt's")
+	write := flags.Bool("w", false, "write the result to each file instead of to standard output")
+	flags.Usage = func() {
+		fmt.Fprintf(flags.Output(), "usage: %s fmt [-l] [-d] [-w] [file.c4 ...]\n", filepath.Base(os.Args[0]))
+		flags.PrintDefaults()
+	}
+	flags.Parse(args)
+
+	comp := &compiler{
+		sources: make(map[string][]byte),
+		logger:  log.New(io.Discard, "", 0),
+	}
+	var cancel context.CancelFunc
+	comp.context, cancel = context.WithTimeout(context.Background(), time.Second*5)
+	defer cancel()
+
+	names := flags.Args()
+	if len(names) == 0 {
+		if *write {
+			fmt.Fprintln(os.Stderr, "error formatting: can't use -w with standard input")
+			return 2
+		}
+		source, err := io.ReadAll(os.Stdin)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "error formatting %s:\n> %s\n", standardInput, err)
+			return 1
+		}
+		comp.sources[standardInput] = source
+		names = []string{standardInput}
+	}
+
+	status := 0
+	for _, name := range names {
+		source, has := comp.sources[name]
+		if !has {
+			var err error
+			if source, err = os.ReadFile(name); err != nil {
+				fmt.Fprintf(os.Stderr, "error formatting %s:\n> %s\n", name, err)
+				status = 1
+				continue
+			}
+			comp.sources[name] = source
+		}
+
+		formatted, err := formatter.Format(name, comp)
+		if err != nil {
+			fmt.Fprintln(os.Stderr, comp.prettyPrintError(err))
+			status = 1
+			continue
+		}
+
+		if *list || *diff || *write {
+			if bytes.Equal(source, formatted) {
+				continue
+			}
+			if *list {
+				fmt.Println(name)
+			}
+			if *diff {
+				os.Stdout.Write(formatter.Diff(name, source, formatted))
+			}
+			if *write {
+				if err := os.WriteFile(name, formatted, 0o644); err != nil {
+					fmt.Fprintf(os.Stderr, "error writing %s:\n> %s\n", name, err)
+					status = 1
+				}
+			}
+			continue
+		}
+		os.Stdout.Write(formatted)
+	}
+	return status
+}
@@ -0,0 +1,227 @@
+package checker
+
+import (
+	"fmt"
+
+	"go.burian.dev/c4/cmd/compiler/internal/parser"
+)
+
+// resolves the containers and software systems deployed by every instance
+// beneath the entity. Instances are looked up from the node they're deployed
+// on, the same as relationships declared there.
+func (c *Checker) resolveInstances(e parser.Entity) error {
+	switch inst := e.(type) {
+
+	case *parser.ContainerInstance:
+		target, err := c.resolveReference(inst.Parent(), inst.ContainerId)
+		if err != nil {
+			return c.errorAt(inst, fmt.Errorf("invalid container instance: %w", err))
+		}
+		container, ok := target.(*parser.Container)
+		if !ok {
+			return c.errorAt(inst, fmt.Errorf("invalid container instance: %s is not a container", target.Id()))
+		}
+		inst.Container = container
+		inst.ContainerId = container.Id()
+
+	case *parser.SoftwareSystemInstance:
+		target, err := c.resolveReference(inst.Parent(), inst.SoftwareSystemId)
+		if err != nil {
+			return c.errorAt(inst, fmt.Errorf("invalid software system instance: %w", err))
+		}
+		system, ok := target.(*parser.SoftwareSystem)
+		if !ok {
+			return c.errorAt(inst, fmt.Errorf("invalid software system instance: %s is not a software system", target.Id()))
+		}
+		inst.SoftwareSystem = system
+		inst.SoftwareSystemId = system.Id()
+	}
+
+	for _, child := range e.Base().Children() {
+		if err := c.resolveInstances(child); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// the deployment environment an entity is part of, or nil for entities in
+// the static model
+func environmentOf(e parser.Entity) *parser.DeploymentEnvironment {
+	for ; e != nil; e = e.Parent() {
+		if env, ok := e.(*parser.DeploymentEnvironment); ok {
+			return env
+		}
+	}
+	return nil
+}
+
+// relationships between containers and software systems, whether declared
+// or implied, are replicated between their instances deployed in the same
+// environment
+func (c *Checker) replicateRelationships(m *parser.Model) {
+	var instances []parser.Entity
+	collectInstances(m, &instances)
+
+	byPair := make(map[[2]parser.Entity][]*parser.Relationship)
+	replicated := make(map[[2]parser.Entity]map[*parser.Relationship]bool)
+	for _, r := range m.AllRelationships() {
+		if r.LinkedTo != nil {
+			pair := [2]parser.Entity{r.Source, r.Destination}
+			if replicated[pair] == nil {
+				replicated[pair] = make(map[*parser.Relationship]bool)
+			}
+			replicated[pair][r.LinkedTo] = true
+			continue
+		}
+		if environmentOf(r.Source) == nil {
+			pair := [2]parser.Entity{r.Source, r.Destination}
+			byPair[pair] = append(byPair[pair], r)
+		}
+	}
+
+	for _, src := range instances {
+		for _, dst := range instances {
+			if src == dst || environmentOf(src) != environmentOf(dst) {
+				continue
+			}
+
+			pair := [2]parser.Entity{src, dst}
+			for _, r := range byPair[[2]parser.Entity{deployedEntity(src), deployedEntity(dst)}] {
+				if replicated[pair][r] {
+					continue
+				}
+
+				replica := &parser.Relationship{
+					SourceId:      src.Id(),
+					DestinationId: dst.Id(),
+					Source:        src,
+					Destination:   dst,
+					LinkedTo:      r,
+				}
+				replica.Description = r.Description
+				replica.Technology = r.Technology
+				replica.Tags = r.Tags
+				src.Base().SetRelationship(replica)
+			}
+		}
+	}
+}
+
+func collectInstances(e parser.Entity, into *[]parser.Entity) {
+	switch e.(type) {
+	case *parser.ContainerInstance, *parser.SoftwareSystemInstance:
+		*into = append(*into, e)
+	}
+	for _, child := range e.Base().Children() {
+		collectInstances(child, into)
+	}
+}
+
+// the entity in the static model an instance deploys
+func deployedEntity(e parser.Entity) parser.Entity {
+	switch inst := e.(type) {
+	case *parser.ContainerInstance:
+		return inst.Container
+	case *parser.SoftwareSystemInstance:
+		return inst.SoftwareSystem
+	}
+	return nil
+}
+
+func isDeploymentNode(e parser.Entity) bool {
+	_, ok := e.(*parser.DeploymentNode)
+	return ok
+}
+
+// deployment views name their environment either by identifier or by name
+func (c *Checker) resolveViewEnvironment(m *parser.Model, view *parser.DeploymentView) error {
+	var found *parser.DeploymentEnvironment
+
+	if e, has := c.lookup(nil, parser.IdentifierString(view.Environment)); has {
+		found, _ = e.(*parser.DeploymentEnvironment)
+	}
+	for _, e := range m.Children() {
+		if found != nil {
+			break
+		}
+		if env, ok := e.(*parser.DeploymentEnvironment); ok && env.Name == view.Environment {
+			found = env
+		}
+	}
+
+	if found == nil {
+		return c.errorAt(view, fmt.Errorf("unknown deployment environment %s", view.Environment))
+	}
+
+	view.DeploymentEnvironment = found
+	view.EnvironmentId = found.Id()
+	return nil
+}
+
+// what `include *` shows in a deployment view. Unscoped views show the whole
+// environment, and scoped views only the instances of the scope's containers
+// and the infrastructure and software systems they connect to.
+func defaultDeploymentElements(view *parser.DeploymentView, rels []*parser.Relationship) []parser.Entity {
+	var selected []parser.Entity
+	var walk func(e parser.Entity)
+	walk = func(e parser.Entity) {
+		switch e.(type) {
+		case *parser.DeploymentNode, *parser.InfrastructureNode:
+			if view.Scope == nil {
+				selected = append(selected, e)
+			}
+		case *parser.ContainerInstance:
+			if permittedInDeploymentView(view, e) {
+				selected = append(selected, e)
+			}
+		case *parser.SoftwareSystemInstance:
+			if view.Scope == nil {
+				selected = append(selected, e)
+			}
+		}
+		for _, child := range e.Base().Children() {
+			walk(child)
+		}
+	}
+	walk(view.DeploymentEnvironment)
+
+	if view.Scope == nil {
+		return selected
+	}
+
+	inView := make(map[parser.Entity]bool, len(selected))
+	for _, e := range selected {
+		inView[e] = true
+	}
+	for _, r := range rels {
+		for _, ends := range [][2]parser.Entity{{r.Source, r.Destination}, {r.Destination, r.Source}} {
+			from, to := ends[0], ends[1]
+			if !inView[from] || inView[to] || !permittedInDeploymentView(view, to) {
+				continue
+			}
+			switch to.(type) {
+			case *parser.InfrastructureNode, *parser.SoftwareSystemInstance:
+				inView[to] = true
+				selected = append(selected, to)
+			}
+		}
+	}
+	return selected
+}
+
+func permittedInDeploymentView(view *parser.DeploymentView, e parser.Entity) bool {
+	if environmentOf(e) != view.DeploymentEnvironment {
+		return false
+	}
+
+	switch inst := e.(type) {
+	case *parser.DeploymentNode, *parser.InfrastructureNode:
+		return true
+	case *parser.ContainerInstance:
+		return view.Scope == nil || inst.Container.Parent() == view.Scope
+	case *parser.SoftwareSystemInstance:
+		return view.Scope == nil || inst.SoftwareSystem != view.Scope
+	}
+	return false
+}
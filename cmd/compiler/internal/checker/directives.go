@@ -0,0 +1,63 @@
+package checker
+
+import (
+	"fmt"
+	"strings"
+
+	"go.burian.dev/c4/cmd/compiler/internal/parser"
+)
+
+type identifierMode string
+
+const (
+	// identifiers are qualified by the identifiers of their parents, `a.api.db`
+	identifiersHierarchical = identifierMode("hierarchical")
+
+	// identifiers are used as written, and must be unique across the model
+	identifiersFlat = identifierMode("flat")
+)
+
+// directives in the model override those at the workspace level
+func (c *Checker) applyDirectives(w *parser.Workspace) error {
+	c.identifiers = identifiersHierarchical
+	c.impliedRelationships = true
+
+	directives := w.Directives
+	if w.Model != nil {
+		directives = append(directives[:len(directives):len(directives)], w.Model.Directives...)
+	}
+
+	for _, d := range directives {
+		switch strings.ToLower(d.Name) {
+
+		case "identifiers":
+			if len(d.Arguments) != 1 {
+				return c.errorAt(d, fmt.Errorf("!identifiers takes exactly one argument"))
+			}
+			switch mode := identifierMode(d.Arguments[0]); mode {
+			case identifiersHierarchical, identifiersFlat:
+				c.identifiers = mode
+			default:
+				return c.errorAt(d, fmt.Errorf("unknown identifier mode %s: expected %s or %s", mode, identifiersHierarchical, identifiersFlat))
+			}
+
+		case "impliedrelationships":
+			if len(d.Arguments) != 1 {
+				return c.errorAt(d, fmt.Errorf("!impliedRelationships takes exactly one argument"))
+			}
+			switch d.Arguments[0] {
+			case "true":
+				c.impliedRelationships = true
+			case "false":
+				c.impliedRelationships = false
+			default:
+				return c.errorAt(d, fmt.Errorf("!impliedRelationships must be true or false"))
+			}
+
+		default:
+			return c.errorAt(d, fmt.Errorf("unknown directive !%s", d.Name))
+		}
+	}
+
+	return nil
+}
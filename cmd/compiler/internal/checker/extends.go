@@ -0,0 +1,164 @@
+package checker
+
+import (
+	"fmt"
+
+	"go.burian.dev/c4/cmd/compiler/internal/parser"
+)
+
+// extendWorkspace layers the workspace on top of the workspace it extends,
+// so that its model and views can refer to anything declared in the base.
+// The base is merged first so that its directives and styles are overridden
+// by the extending workspace, and redefining any of its entities is an error.
+func (c *Checker) extendWorkspace(w *parser.Workspace) error {
+	c.inherited = make(map[parser.Entity]bool)
+	return c.extendWorkspaceFrom(w, map[string]bool{})
+}
+
+func (c *Checker) extendWorkspaceFrom(w *parser.Workspace, extending map[string]bool) error {
+	if w.Extends == "" {
+		return nil
+	}
+	if c.deps == nil {
+		return fmt.Errorf("unable to load workspace %s without a source provider", w.Extends)
+	}
+	if extending[w.Extends] {
+		return c.errorAt(w, fmt.Errorf("cyclic extension of workspace %s", w.Extends))
+	}
+	extending[w.Extends] = true
+
+	base, err := c.deps.GetWorkspaceFor(w.Extends)
+	if err != nil {
+		return c.errorAt(w, fmt.Errorf("error loading extended workspace %s:\n> %w", w.Extends, err))
+	}
+	if err := c.extendWorkspaceFrom(base, extending); err != nil {
+		return fmt.Errorf("error extending workspace %s:\n> %w", w.Extends, err)
+	}
+
+	if w.Name == "" {
+		w.Name = base.Name
+	}
+	if w.Description == "" {
+		w.Description = base.Description
+	}
+	w.Directives = appendMissing(base.Directives, w.Directives)
+
+	if base.Model != nil {
+		model, err := c.mergeModels(base.Model, w.Model)
+		if err != nil {
+			return err
+		}
+		w.Model = model
+	}
+
+	if base.Views != nil {
+		w.Views = mergeViews(base.Views, w.Views)
+	}
+
+	return nil
+}
+
+// the merged model is a new model, but it shares the base's entities, which
+// are then reconciled along with the rest of it. Only top level entities
+// are checked for redefinition here, since nested identifiers depend on the
+// identifier mode, and are checked as they're qualified instead.
+func (c *Checker) mergeModels(base, m *parser.Model) (*parser.Model, error) {
+	if m == nil {
+		m = new(parser.Model)
+	}
+
+	merged := new(parser.Model)
+	merged.Name = m.Name
+	merged.Description = m.Description
+
+	for _, e := range base.Children() {
+		merged.Add(e)
+		c.inherit(e)
+	}
+	for _, e := range m.Children() {
+		existing, has := merged.NamedEntities[e.Id()]
+		if !has {
+			merged.Add(e)
+			continue
+		}
+		if existing == e {
+			// already merged by an earlier check of this workspace
+			continue
+		}
+		return nil, c.errRedefinition(e.Id(), existing, e)
+	}
+
+	merged.People = appendMissing(base.People, m.People)
+	merged.SoftwareSystems = appendMissing(base.SoftwareSystems, m.SoftwareSystems)
+	merged.Relationships = appendMissing(base.Relationships, m.Relationships)
+	merged.Directives = appendMissing(base.Directives, m.Directives)
+
+	return merged, nil
+}
+
+// inherit marks an entity and everything in it as coming from an extended
+// workspace
+func (c *Checker) inherit(e parser.Entity) {
+	c.inherited[e] = true
+	for _, child := range e.Base().Children() {
+		c.inherit(child)
+	}
+}
+
+func (c *Checker) errRedefinition(id parser.IdentifierString, original, redefined parser.Entity) error {
+	err := fmt.Errorf("redefinition of identifier %s from the extended workspace", id)
+	if c.deps != nil {
+		if tok := c.deps.DeclarationOf(original); tok != nil {
+			err = fmt.Errorf("redefinition of identifier %s, previously defined in the extended workspace at %s", id, tok.Positions())
+		}
+	}
+	return c.errorAt(redefined, err)
+}
+
+func mergeViews(base, v *parser.Views) *parser.Views {
+	if v == nil {
+		v = new(parser.Views)
+	}
+
+	merged := &parser.Views{
+		SystemLandscapeViews: appendMissing(base.SystemLandscapeViews, v.SystemLandscapeViews),
+		SystemContextViews:   appendMissing(base.SystemContextViews, v.SystemContextViews),
+		ContainerViews:       appendMissing(base.ContainerViews, v.ContainerViews),
+		ComponentViews:       appendMissing(base.ComponentViews, v.ComponentViews),
+		DynamicViews:         appendMissing(base.DynamicViews, v.DynamicViews),
+		DeploymentViews:      appendMissing(base.DeploymentViews, v.DeploymentViews),
+		Styles:               v.Styles,
+	}
+
+	if base.Styles != nil {
+		styles := new(parser.Styles)
+		styles.Merge(base.Styles)
+		styles.Themes = appendMissing(nil, base.Styles.Themes)
+		if v.Styles != nil {
+			styles.Merge(v.Styles)
+			styles.Themes = appendMissing(styles.Themes, v.Styles.Themes)
+		}
+		merged.Styles = styles
+	}
+
+	return merged
+}
+
+// appendMissing returns a new slice of everything in base followed by
+// anything in more that is not already in it
+func appendMissing[T comparable](base, more []T) []T {
+	merged := append([]T(nil), base...)
+	for _, x := range more {
+		found := false
+		for _, y := range merged {
+			if x == y {
+				found = true
+				break
+			}
+		}
+		if !found {
+			merged = append(merged, x)
+		}
+	}
+	return merged
+}
@@ -0,0 +1,59 @@
+package checker
+
+import (
+	"go.burian.dev/c4/cmd/compiler/internal/parser"
+)
+
+// Implied relationships follow the default strategy of the original DSL.
+// For a relationship between two entities, every pair of their parents
+// gets a relationship too, unless one already exists between that pair in
+// either an explicit or previously implied form.
+//
+// Component k in container X of system A relating to system B implies
+// relationships X -> B and A -> B.
+func (c *Checker) createImpliedRelationships(m *parser.Model) {
+	if !c.impliedRelationships {
+		return
+	}
+
+	rels := m.AllRelationships()
+
+	existing := make(map[[2]parser.Entity]bool, len(rels))
+	for _, r := range rels {
+		existing[[2]parser.Entity{r.Source, r.Destination}] = true
+	}
+
+	for _, r := range rels {
+		if r.ImpliedBasedOn != nil {
+			continue
+		}
+
+		for src := r.Source; src != nil; src = src.Parent() {
+			for dst := r.Destination; dst != nil; dst = dst.Parent() {
+				if src == r.Source && dst == r.Destination {
+					continue
+				}
+				if isAncestorOrSelf(src, dst) || isAncestorOrSelf(dst, src) {
+					continue
+				}
+
+				pair := [2]parser.Entity{src, dst}
+				if existing[pair] {
+					continue
+				}
+				existing[pair] = true
+
+				implied := &parser.Relationship{
+					SourceId:       src.Id(),
+					DestinationId:  dst.Id(),
+					Source:         src,
+					Destination:    dst,
+					ImpliedBasedOn: r,
+				}
+				implied.Description = r.Description
+				implied.Technology = r.Technology
+				src.Base().SetRelationship(implied)
+			}
+		}
+	}
+}
@@ -1,25 +1,247 @@
 package checker
 
+import (
+	"fmt"
+	"strings"
+
+	"go.burian.dev/c4/cmd/compiler/internal/lexer"
+	"go.burian.dev/c4/cmd/compiler/internal/parser"
+)
+
 type Checker struct {
+	deps Provider
+
+	identifiers          identifierMode
+	impliedRelationships bool
+
+	// every entity in the model being checked, by fully qualified identifier
+	index map[parser.IdentifierString]parser.Entity
+
+	// entities merged in from extended workspaces
+	inherited map[parser.Entity]bool
+}
+
+type Provider interface {
+	DeclarationOf(any) *lexer.Token
+	GetThemeFor(string) (*parser.Styles, error)
+	GetWorkspaceFor(string) (*parser.Workspace, error)
+}
+
+// CheckWorkspaces resolves and validates every identifier in the given workspaces,
+// filling in the details the parser could not know about
+func (c *Checker) CheckWorkspaces(workspaces []*parser.Workspace, deps Provider) error {
+	c.deps = deps
+
+	for _, w := range workspaces {
+		if err := c.reconcileWorkspace(w); err != nil {
+			return fmt.Errorf("error checking workspace:\n> %w", err)
+		}
+	}
+
+	return nil
+}
+
+func (c *Checker) reconcileWorkspace(w *parser.Workspace) error {
+	if err := c.extendWorkspace(w); err != nil {
+		return fmt.Errorf("error extending workspace:\n> %w", err)
+	}
+
+	if err := c.applyDirectives(w); err != nil {
+		return fmt.Errorf("error applying directives:\n> %w", err)
+	}
+
+	model := w.Model
+	if model == nil {
+		// views may still be declared on an empty model
+		model = new(parser.Model)
+	}
+
+	if err := c.reconcileModel(model); err != nil {
+		return fmt.Errorf("error checking model:\n> %w", err)
+	}
+
+	if w.Views != nil {
+		if err := c.applyThemes(w.Views); err != nil {
+			return fmt.Errorf("error applying themes:\n> %w", err)
+		}
+		if err := c.reconcileViews(model, w.Views); err != nil {
+			return fmt.Errorf("error checking views:\n> %w", err)
+		}
+	}
+
+	return nil
+}
+
+func (c *Checker) reconcileModel(m *parser.Model) error {
+	c.index = make(map[parser.IdentifierString]parser.Entity)
+
+	// make sure all objects have full identifiers before anything
+	// tries to reference them
+	for _, e := range m.Children() {
+		if err := c.qualifyIdentifiers(nil, e); err != nil {
+			return err
+		}
+	}
+
+	for _, e := range m.Children() {
+		if err := c.resolveInstances(e); err != nil {
+			return err
+		}
+	}
+
+	if err := c.resolveRelationships(nil, m.Relationships); err != nil {
+		return err
+	}
+	for _, e := range m.Children() {
+		if err := c.resolveEntityRelationships(e); err != nil {
+			return err
+		}
+	}
+
+	// relationships implied within the static model are replicated too, and
+	// the replicas then imply relationships between the nodes they're on
+	c.createImpliedRelationships(m)
+	c.replicateRelationships(m)
+	c.createImpliedRelationships(m)
+
+	return nil
 }
 
-func (p *Checker) CheckWorkspaces([]*Workspace) error {
-	for _, w := range p.workspaces {
-		p.reconcileWorkspace(w)
+func (c *Checker) qualifyIdentifiers(parent, e parser.Entity) error {
+	id := e.Base().LocalId
+	if strings.ContainsRune(string(id), '.') {
+		return c.errorAt(e, fmt.Errorf("cannot assign identifier %s: identifiers may only contain '.' when referencing", id))
+	}
+
+	if parent != nil && c.identifiers == identifiersHierarchical {
+		id = parent.Id() + "." + id
+	}
+
+	if existing, has := c.index[id]; has && existing != e {
+		switch {
+		case c.inherited[existing] && !c.inherited[e]:
+			return c.errRedefinition(id, existing, e)
+		case c.inherited[e] && !c.inherited[existing]:
+			return c.errRedefinition(id, e, existing)
+		}
+
+		err := fmt.Errorf("duplicate identifier %s", id)
+		if c.deps != nil {
+			if tok := c.deps.DeclarationOf(existing); tok != nil {
+				err = fmt.Errorf("duplicate identifier %s, previously defined at %s", id, tok.Positions())
+			}
+		}
+		return c.errorAt(e, err)
 	}
 
+	e.SetParent(parent)
+	e.SetFullyQualifiedId(id)
+	c.index[id] = e
+
+	for _, child := range e.Base().Children() {
+		if err := c.qualifyIdentifiers(e, child); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (c *Checker) resolveEntityRelationships(e parser.Entity) error {
+	if err := c.resolveRelationships(e, e.Base().Relationships); err != nil {
+		return err
+	}
+	for _, child := range e.Base().Children() {
+		if err := c.resolveEntityRelationships(child); err != nil {
+			return err
+		}
+	}
 	return nil
 }
 
-func (p *Parser) reconcileWorkspace(w *Workspace) {
-	p.reconcileModel(w.Model)
+// resolves the endpoints of relationships declared inside the scope entity,
+// which is nil for relationships declared directly in the model
+func (c *Checker) resolveRelationships(scope parser.Entity, rels []*parser.Relationship) error {
+	for _, r := range rels {
+		if r.Source != nil && r.Destination != nil {
+			// already resolved by an earlier check of this model
+			continue
+		}
+
+		src, err := c.resolveReference(scope, r.SourceId)
+		if err != nil {
+			return c.errorAt(r, fmt.Errorf("invalid relationship source: %w", err))
+		}
+
+		dst, err := c.resolveReference(scope, r.DestinationId)
+		if err != nil {
+			return c.errorAt(r, fmt.Errorf("invalid relationship destination: %w", err))
+		}
+
+		if isAncestorOrSelf(src, dst) || isAncestorOrSelf(dst, src) {
+			return c.errorAt(r, fmt.Errorf("relationship from %s to %s is between an entity and itself or its parent", src.Id(), dst.Id()))
+		}
+
+		if srcEnv, dstEnv := environmentOf(src), environmentOf(dst); srcEnv != dstEnv {
+			if srcEnv == nil || dstEnv == nil {
+				return c.errorAt(r, fmt.Errorf("relationship from %s to %s is between the static and deployment models", src.Id(), dst.Id()))
+			}
+			return c.errorAt(r, fmt.Errorf("relationship from %s to %s crosses deployment environments", src.Id(), dst.Id()))
+		}
+
+		r.Source = src
+		r.Destination = dst
+		r.SourceId = src.Id()
+		r.DestinationId = dst.Id()
+	}
+	return nil
 }
 
-func (p *Parser) reconcileModel(m *Model) {
+// finds the entity an identifier refers to from within the given scope
+func (c *Checker) resolveReference(scope parser.Entity, id parser.IdentifierString) (parser.Entity, error) {
+	if id == "this" {
+		if scope == nil {
+			return nil, fmt.Errorf("'this' does not refer to an entity outside of an entity body")
+		}
+		return scope, nil
+	}
 
-	// make sure all objects have full identifiers
+	if e, found := c.lookup(scope, id); found {
+		return e, nil
+	}
+	return nil, fmt.Errorf("unknown identifier %s", id)
+}
 
-	// for _, r := range m.relationships {
+// identifiers are looked up relative to the scope first, then each of its
+// parents in turn, and finally as a fully qualified identifier.
+// In flat mode there are no scopes and everything is fully qualified.
+func (c *Checker) lookup(scope parser.Entity, id parser.IdentifierString) (parser.Entity, bool) {
+	if c.identifiers == identifiersFlat {
+		scope = nil
+	}
+	for s := scope; s != nil; s = s.Parent() {
+		if e, has := c.index[s.Id()+"."+id]; has {
+			return e, true
+		}
+	}
+	e, has := c.index[id]
+	return e, has
+}
+
+func (c *Checker) errorAt(x any, err error) error {
+	if c.deps == nil {
+		return err
+	}
+	if tok := c.deps.DeclarationOf(x); tok != nil {
+		return parser.ErrorForToken(tok, err)
+	}
+	return err
+}
 
-	// }
+func isAncestorOrSelf(ancestor, e parser.Entity) bool {
+	for ; e != nil; e = e.Parent() {
+		if e == ancestor {
+			return true
+		}
+	}
+	return false
 }
@@ -0,0 +1,767 @@
+package checker
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"reflect"
+	"strings"
+	"testing"
+
+	"go.burian.dev/c4/cmd/compiler/internal/lexer"
+	"go.burian.dev/c4/cmd/compiler/internal/parser"
+)
+
+type mockDependencies struct {
+	sources map[string]string
+	l       *lexer.Lexer
+	p       *parser.Parser
+}
+
+var _ Provider = &mockDependencies{}
+var _ parser.Provider = &mockDependencies{}
+
+func (m *mockDependencies) GetTokenStreamFor(name string) (lexer.TokenStream, error) {
+	ls, err := m.l.Run(name, m)
+	if err != nil {
+		return nil, err
+	}
+	return ls.TokenStream(), nil
+}
+
+func (m *mockDependencies) GetSourceFor(name string) (*bytes.Reader, error) {
+	if buf, has := m.sources[name]; has {
+		return bytes.NewReader([]byte(buf)), nil
+	}
+	return nil, fmt.Errorf("no such source: %s", name)
+}
+
+func (m *mockDependencies) DeclarationOf(x any) *lexer.Token {
+	return m.p.DeclarationOf(x)
+}
+
+func (m *mockDependencies) GetThemeFor(name string) (*parser.Styles, error) {
+	return m.p.RunTheme(name, m)
+}
+
+func (m *mockDependencies) GetWorkspaceFor(name string) (*parser.Workspace, error) {
+	return m.p.Run(name, m)
+}
+
+func checkSource(t *testing.T, input string) (*parser.Workspace, error) {
+	t.Helper()
+
+	deps := &mockDependencies{
+		sources: map[string]string{"test": input},
+		l:       new(lexer.Lexer),
+		p:       new(parser.Parser),
+	}
+
+	w, err := deps.p.Run("test", deps)
+	if err != nil {
+		t.Fatalf("parse error in test input: %s", err)
+	}
+
+	c := new(Checker)
+	return w, c.CheckWorkspaces([]*parser.Workspace{w}, deps)
+}
+
+func TestChecker_ResolveRelationships(t *testing.T) {
+	tests := []struct {
+		name    string
+		input   string
+		want    map[string][2]parser.IdentifierString
+		wantErr bool
+	}{
+		{
+			name: "model relationship",
+			input: `workspace {
+				model {
+					a = softwareSystem 'a'
+					b = softwareSystem 'b'
+					a -> b 'uses'
+				}
+			}`,
+			want: map[string][2]parser.IdentifierString{"uses": {"a", "b"}},
+		},
+		{
+			name: "this and implicit source",
+			input: `workspace {
+				model {
+					a = softwareSystem 'a' {
+						-> b 'implicit'
+						this -> b 'explicit this'
+						b -> this 'reversed'
+					}
+					b = softwareSystem 'b'
+				}
+			}`,
+			want: map[string][2]parser.IdentifierString{
+				"implicit":      {"a", "b"},
+				"explicit this": {"a", "b"},
+				"reversed":      {"b", "a"},
+			},
+		},
+		{
+			name: "nested scope lookup",
+			input: `workspace {
+				model {
+					a = softwareSystem 'a' {
+						api = container 'api' {
+							-> db 'reads'
+							-> b 'calls'
+						}
+						db = container 'db'
+					}
+					b = softwareSystem 'b'
+				}
+			}`,
+			want: map[string][2]parser.IdentifierString{
+				"reads": {"a.api", "a.db"},
+				"calls": {"a.api", "b"},
+			},
+		},
+		{
+			name: "hierarchical reference",
+			input: `workspace {
+				model {
+					a = softwareSystem 'a' {
+						api = container 'api'
+					}
+					u = person 'u' {
+						-> a.api 'uses'
+					}
+				}
+			}`,
+			want: map[string][2]parser.IdentifierString{"uses": {"u", "a.api"}},
+		},
+		{
+			name: "flat reference",
+			input: `workspace {
+				!identifiers flat
+				model {
+					a = softwareSystem 'a' {
+						api = container 'api'
+					}
+					u = person 'u' {
+						-> api 'uses'
+					}
+				}
+			}`,
+			want: map[string][2]parser.IdentifierString{"uses": {"u", "api"}},
+		},
+		{
+			name: "flat mode has no hierarchical references",
+			input: `workspace {
+				model {
+					!identifiers flat
+					a = softwareSystem 'a' {
+						api = container 'api'
+					}
+					u = person 'u' {
+						-> a.api 'uses'
+					}
+				}
+			}`,
+			wantErr: true,
+		},
+		{
+			name: "unknown destination",
+			input: `workspace {
+				model {
+					a = softwareSystem 'a' {
+						-> c 'typo'
+					}
+					b = softwareSystem 'b'
+				}
+			}`,
+			wantErr: true,
+		},
+		{
+			name: "this outside of entity",
+			input: `workspace {
+				model {
+					a = softwareSystem 'a'
+					-> a 'from nowhere'
+				}
+			}`,
+			wantErr: true,
+		},
+		{
+			name: "relationship to parent",
+			input: `workspace {
+				model {
+					a = softwareSystem 'a' {
+						api = container 'api' {
+							-> a 'up'
+						}
+					}
+				}
+			}`,
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w, err := checkSource(t, tt.input)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("Checker.CheckWorkspaces() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if err != nil {
+				t.Log(err)
+				return
+			}
+
+			got := make(map[string][2]parser.IdentifierString)
+			collectRelationships(w.Model, got)
+
+			for desc, ends := range tt.want {
+				if got[desc] != ends {
+					t.Errorf("relationship %q resolved to %v, want %v", desc, got[desc], ends)
+				}
+			}
+		})
+	}
+}
+
+func TestChecker_FullyQualifiedIds(t *testing.T) {
+	w, err := checkSource(t, `workspace {
+		model {
+			a = softwareSystem 'a' {
+				api = container 'api' {
+					handler = component 'handler'
+				}
+			}
+		}
+	}`)
+	if err != nil {
+		t.Fatalf("unexpected check error: %s", err)
+	}
+
+	a := w.Model.NamedEntities["a"]
+	api := a.Base().NamedEntities["api"]
+	handler := api.Base().NamedEntities["handler"]
+
+	for want, e := range map[parser.IdentifierString]parser.Entity{
+		"a":             a,
+		"a.api":         api,
+		"a.api.handler": handler,
+	} {
+		if e.Id() != want {
+			t.Errorf("got fully qualified id %s, want %s", e.Id(), want)
+		}
+	}
+
+	if handler.Parent() != api || api.Parent() != a || a.Parent() != nil {
+		t.Errorf("entity parents not assigned")
+	}
+}
+
+func TestChecker_IdentifierModes(t *testing.T) {
+	tests := []struct {
+		name    string
+		input   string
+		wantErr bool
+	}{
+		{
+			name: "hierarchical allows reuse under different parents",
+			input: `workspace {
+				model {
+					a = softwareSystem 'a' {
+						db = container 'db'
+					}
+					b = softwareSystem 'b' {
+						db = container 'db'
+					}
+				}
+			}`,
+		},
+		{
+			name: "flat requires globally unique identifiers",
+			input: `workspace {
+				!identifiers flat
+				model {
+					a = softwareSystem 'a' {
+						db = container 'db'
+					}
+					b = softwareSystem 'b' {
+						db = container 'db'
+					}
+				}
+			}`,
+			wantErr: true,
+		},
+		{
+			name: "cannot assign qualified identifiers",
+			input: `workspace {
+				model {
+					a.b = softwareSystem 'a'
+				}
+			}`,
+			wantErr: true,
+		},
+		{
+			name: "unknown identifier mode",
+			input: `workspace {
+				!identifiers sideways
+			}`,
+			wantErr: true,
+		},
+		{
+			name: "unknown directive",
+			input: `workspace {
+				!frobnicate
+			}`,
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := checkSource(t, tt.input)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("Checker.CheckWorkspaces() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if err != nil {
+				t.Log(err)
+			}
+		})
+	}
+}
+
+func TestChecker_FlatDuplicateReportsBothSites(t *testing.T) {
+	_, err := checkSource(t, `workspace {
+		!identifiers flat
+		model {
+			a = softwareSystem 'a' {
+				db = container 'db'
+			}
+			b = softwareSystem 'b' {
+				db = container 'db'
+			}
+		}
+	}`)
+	if err == nil {
+		t.Fatalf("expected duplicate identifier error")
+	}
+
+	var ce *parser.CodeError
+	if !errors.As(err, &ce) {
+		t.Fatalf("expected a positioned error, got %s", err)
+	}
+	if got := ce.TokenAtError().Positions().Start.Line; got != 8 {
+		t.Errorf("error reported at line %d, want 8", got)
+	}
+	if !strings.Contains(err.Error(), "line 5") {
+		t.Errorf("error does not reference the first definition: %s", err)
+	}
+}
+
+func TestChecker_ImpliedRelationships(t *testing.T) {
+	tests := []struct {
+		name        string
+		input       string
+		wantImplied [][2]parser.IdentifierString
+	}{
+		{
+			name: "component to external system",
+			input: `workspace {
+				model {
+					a = softwareSystem 'a' {
+						x = container 'x' {
+							k = component 'k' {
+								-> b 'calls'
+							}
+						}
+					}
+					b = softwareSystem 'b'
+				}
+			}`,
+			wantImplied: [][2]parser.IdentifierString{
+				{"a.x", "b"},
+				{"a", "b"},
+			},
+		},
+		{
+			name: "container to container across systems",
+			input: `workspace {
+				model {
+					a = softwareSystem 'a' {
+						x = container 'x' {
+							-> b.y 'calls'
+						}
+					}
+					b = softwareSystem 'b' {
+						y = container 'y'
+					}
+				}
+			}`,
+			wantImplied: [][2]parser.IdentifierString{
+				{"a.x", "b"},
+				{"a", "b.y"},
+				{"a", "b"},
+			},
+		},
+		{
+			name: "deduplicated against explicit",
+			input: `workspace {
+				model {
+					a = softwareSystem 'a' {
+						x = container 'x' {
+							-> b 'calls'
+						}
+						-> b 'explicit'
+					}
+					b = softwareSystem 'b'
+				}
+			}`,
+		},
+		{
+			name: "no implied relationships inside one parent",
+			input: `workspace {
+				model {
+					a = softwareSystem 'a' {
+						x = container 'x' {
+							-> y 'calls'
+						}
+						y = container 'y'
+					}
+				}
+			}`,
+		},
+		{
+			name: "disabled",
+			input: `workspace {
+				!impliedRelationships false
+				model {
+					a = softwareSystem 'a' {
+						x = container 'x' {
+							-> b 'calls'
+						}
+					}
+					b = softwareSystem 'b'
+				}
+			}`,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w, err := checkSource(t, tt.input)
+			if err != nil {
+				t.Fatalf("unexpected check error: %s", err)
+			}
+
+			var gotImplied [][2]parser.IdentifierString
+			for _, r := range w.Model.AllRelationships() {
+				if r.ImpliedBasedOn == nil {
+					continue
+				}
+				if r.ImpliedBasedOn.ImpliedBasedOn != nil {
+					t.Errorf("relationship implied by another implied relationship")
+				}
+				gotImplied = append(gotImplied, [2]parser.IdentifierString{r.SourceId, r.DestinationId})
+			}
+
+			if len(gotImplied) != len(tt.wantImplied) {
+				t.Fatalf("got implied relationships %v, want %v", gotImplied, tt.wantImplied)
+			}
+			for _, want := range tt.wantImplied {
+				found := false
+				for _, got := range gotImplied {
+					if got == want {
+						found = true
+						break
+					}
+				}
+				if !found {
+					t.Errorf("missing implied relationship %s -> %s", want[0], want[1])
+				}
+			}
+		})
+	}
+}
+
+func collectRelationships(e parser.Entity, into map[string][2]parser.IdentifierString) {
+	for _, r := range e.Base().Relationships {
+		if r.ImpliedBasedOn != nil {
+			continue
+		}
+		into[r.Description] = [2]parser.IdentifierString{r.SourceId, r.DestinationId}
+	}
+	for _, child := range e.Base().Children() {
+		collectRelationships(child, into)
+	}
+}
+
+func TestChecker_DeploymentInstances(t *testing.T) {
+	tests := []struct {
+		name    string
+		input   string
+		wantErr bool
+	}{
+		{
+			name: "instances resolve",
+			input: `model {
+				a = softwareSystem 'a' {
+					api = container 'api'
+				}
+				prod = deploymentEnvironment 'Prod' {
+					deploymentNode 'aws' {
+						lb = infrastructureNode 'lb' {
+							-> api1 'routes'
+						}
+						api1 = containerInstance a.api
+						softwareSystemInstance a
+					}
+				}
+			}`,
+		},
+		{
+			name: "container instance of a software system",
+			input: `model {
+				a = softwareSystem 'a'
+				deploymentEnvironment 'Prod' {
+					deploymentNode 'aws' {
+						containerInstance a
+					}
+				}
+			}`,
+			wantErr: true,
+		},
+		{
+			name: "unknown container",
+			input: `model {
+				deploymentEnvironment 'Prod' {
+					deploymentNode 'aws' {
+						containerInstance nope
+					}
+				}
+			}`,
+			wantErr: true,
+		},
+		{
+			name: "relationship across environments",
+			input: `model {
+				staging = deploymentEnvironment 'Staging' {
+					s = deploymentNode 's'
+				}
+				prod = deploymentEnvironment 'Prod' {
+					p = deploymentNode 'p' {
+						-> staging.s 'replicates'
+					}
+				}
+			}`,
+			wantErr: true,
+		},
+		{
+			name: "relationship from static model",
+			input: `model {
+				u = person 'u' {
+					-> prod.p 'logs in'
+				}
+				prod = deploymentEnvironment 'Prod' {
+					p = deploymentNode 'p'
+				}
+			}`,
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w, err := checkSource(t, "workspace {\n"+tt.input+"\n}")
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("Checker.CheckWorkspaces() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if err != nil {
+				t.Log(err)
+				return
+			}
+
+			api := w.Model.NamedEntities["a"].Base().NamedEntities["api"]
+			for _, e := range w.Model.NamedEntities["prod"].Base().Children()[0].Base().Children() {
+				switch inst := e.(type) {
+				case *parser.ContainerInstance:
+					if inst.Container != api || inst.ContainerId != "a.api" {
+						t.Errorf("container instance resolved to %s", inst.ContainerId)
+					}
+				case *parser.SoftwareSystemInstance:
+					if inst.SoftwareSystem != w.Model.NamedEntities["a"] {
+						t.Errorf("software system instance resolved to %s", inst.SoftwareSystemId)
+					}
+				}
+			}
+		})
+	}
+}
+
+func TestChecker_ExtendedWorkspaces(t *testing.T) {
+	base := `workspace 'company' {
+		model {
+			u = person 'user'
+			core = softwareSystem 'core' {
+				api = container 'api'
+			}
+		}
+		views {
+			systemLandscape 'landscape' { include * }
+			styles {
+				element 'Person' { shape person; background '#08427b' }
+			}
+		}
+	}`
+
+	tests := []struct {
+		name    string
+		sources map[string]string
+		wantErr bool
+	}{
+		{
+			name: "references base entities",
+			sources: map[string]string{
+				"base": base,
+				"test": `workspace extends 'base' {
+					model {
+						team = softwareSystem 'team' {
+							-> core.api 'calls'
+						}
+						u -> team 'uses'
+					}
+					views {
+						systemContext team { include * }
+						styles {
+							element 'Person' { background '#000000' }
+						}
+					}
+				}`,
+			},
+		},
+		{
+			name: "redefines a base entity",
+			sources: map[string]string{
+				"base": base,
+				"test": `workspace extends 'base' {
+					model {
+						core = softwareSystem 'core'
+					}
+				}`,
+			},
+			wantErr: true,
+		},
+		{
+			name: "duplicates a base view key",
+			sources: map[string]string{
+				"base": base,
+				"test": `workspace extends 'base' {
+					views {
+						systemLandscape 'landscape' { include * }
+					}
+				}`,
+			},
+			wantErr: true,
+		},
+		{
+			name: "cyclic extension",
+			sources: map[string]string{
+				"a":    `workspace extends 'b' {}`,
+				"b":    `workspace extends 'a' {}`,
+				"test": `workspace extends 'a' {}`,
+			},
+			wantErr: true,
+		},
+		{
+			name: "missing base",
+			sources: map[string]string{
+				"test": `workspace extends 'nope' {}`,
+			},
+			wantErr: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			deps := &mockDependencies{
+				sources: tt.sources,
+				l:       new(lexer.Lexer),
+				p:       new(parser.Parser),
+			}
+
+			w, err := deps.p.Run("test", deps)
+			if err != nil {
+				t.Fatalf("parse error in test input: %s", err)
+			}
+
+			err = new(Checker).CheckWorkspaces([]*parser.Workspace{w}, deps)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("CheckWorkspaces() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if err != nil {
+				t.Log(err)
+				return
+			}
+
+			if w.Name != "company" {
+				t.Errorf("workspace name %q was not inherited from the base", w.Name)
+			}
+			for _, id := range []parser.IdentifierString{"u", "core", "team"} {
+				if _, has := w.Model.NamedEntities[id]; !has {
+					t.Errorf("merged model is missing %s", id)
+				}
+			}
+			for _, r := range w.Model.Relationships {
+				if r.Source == nil || r.Destination == nil {
+					t.Errorf("relationship %s -> %s was not resolved", r.SourceId, r.DestinationId)
+				}
+			}
+			if got := len(w.Views.All()); got != 2 {
+				t.Errorf("merged workspace has %d views, want 2", got)
+			}
+			want := []*parser.ElementStyle{{Tag: "Person", Shape: parser.ShapePerson, Background: "#000000"}}
+			if !reflect.DeepEqual(w.Views.Styles.Elements, want) {
+				t.Errorf("got merged styles %+v, want %+v", w.Views.Styles.Elements, want)
+			}
+		})
+	}
+}
+
+func TestChecker_ExtendedRedefinesNested(t *testing.T) {
+	deps := &mockDependencies{
+		sources: map[string]string{
+			"base": `workspace 'company' {
+				!identifiers flat
+				model {
+					core = softwareSystem 'core' {
+						api = container 'api'
+					}
+				}
+			}`,
+			"test": `workspace extends 'base' {
+				model {
+					team = softwareSystem 'team' {
+						api = container 'our api'
+					}
+				}
+			}`,
+		},
+		l: new(lexer.Lexer),
+		p: new(parser.Parser),
+	}
+
+	w, err := deps.p.Run("test", deps)
+	if err != nil {
+		t.Fatalf("parse error in test input: %s", err)
+	}
+	err = new(Checker).CheckWorkspaces([]*parser.Workspace{w}, deps)
+	if err == nil {
+		t.Fatalf("expected redefining a base container to be an error")
+	}
+
+	var ce *parser.CodeError
+	if !errors.As(err, &ce) {
+		t.Fatalf("expected a positioned error, got %s", err)
+	}
+	if pos := ce.TokenAtError().Positions().Start; pos.File != "test" || pos.Line != 4 {
+		t.Errorf("error reported at %+v, want line 4 of test", pos)
+	}
+	if !strings.Contains(err.Error(), "previously defined in the extended workspace at base (line 5") {
+		t.Errorf("error does not reference the base definition: %s", err)
+	}
+}
@@ -0,0 +1,37 @@
+package checker
+
+import (
+	"fmt"
+
+	"go.burian.dev/c4/cmd/compiler/internal/parser"
+)
+
+// themes are merged in the order they're listed, beneath the workspace's
+// own styles, so that local definitions always take precedence
+func (c *Checker) applyThemes(v *parser.Views) error {
+	if v.Styles == nil || len(v.Styles.Themes) == 0 {
+		return nil
+	}
+	if c.deps == nil {
+		return fmt.Errorf("unable to load themes without a source provider")
+	}
+
+	merged := new(parser.Styles)
+	for _, source := range v.Styles.Themes {
+		theme, err := c.deps.GetThemeFor(source)
+		if err != nil {
+			return c.errorAt(v.Styles, fmt.Errorf("error loading theme %s:\n> %w", source, err))
+		}
+		merged.Merge(theme)
+	}
+
+	local := &parser.Styles{
+		Elements:      v.Styles.Elements,
+		Relationships: v.Styles.Relationships,
+	}
+	merged.Merge(local)
+
+	v.Styles.Elements = merged.Elements
+	v.Styles.Relationships = merged.Relationships
+	return nil
+}
@@ -0,0 +1,483 @@
+package checker
+
+import (
+	"fmt"
+
+	"go.burian.dev/c4/cmd/compiler/internal/parser"
+)
+
+func (c *Checker) reconcileViews(m *parser.Model, v *parser.Views) error {
+	keys := make(map[string]parser.View)
+	generatedKeys := make(map[string]int)
+
+	for _, view := range v.All() {
+		base := view.Base()
+
+		scope, err := c.resolveViewScope(view)
+		if err != nil {
+			return err
+		}
+		base.Scope = scope
+
+		if base.Key == "" {
+			kind := viewKind(view)
+			generatedKeys[kind]++
+			base.Key = fmt.Sprintf("%s-%03d", kind, generatedKeys[kind])
+		}
+		if _, has := keys[base.Key]; has {
+			return c.errorAt(view, fmt.Errorf("duplicate view key %s", base.Key))
+		}
+		keys[base.Key] = view
+
+		if err := c.evaluateView(m, view); err != nil {
+			return fmt.Errorf("error in view %s:\n> %w", base.Key, err)
+		}
+	}
+
+	return nil
+}
+
+func viewKind(view parser.View) string {
+	switch view.(type) {
+	case *parser.SystemLandscapeView:
+		return "SystemLandscape"
+	case *parser.SystemContextView:
+		return "SystemContext"
+	case *parser.ContainerView:
+		return "Container"
+	case *parser.ComponentView:
+		return "Component"
+	case *parser.DynamicView:
+		return "Dynamic"
+	case *parser.DeploymentView:
+		return "Deployment"
+	}
+	panic(fmt.Sprintf("unknown view type %T", view))
+}
+
+func (c *Checker) resolveViewScope(view parser.View) (parser.Entity, error) {
+	var scopeId parser.IdentifierString
+
+	switch v := view.(type) {
+	case *parser.SystemLandscapeView:
+		return nil, nil
+	case *parser.SystemContextView:
+		scopeId = v.SoftwareSystemId
+	case *parser.ContainerView:
+		scopeId = v.SoftwareSystemId
+	case *parser.ComponentView:
+		scopeId = v.ContainerId
+	case *parser.DynamicView:
+		if v.ScopeId == "" {
+			return nil, nil
+		}
+		scopeId = v.ScopeId
+	case *parser.DeploymentView:
+		if v.SoftwareSystemId == "" {
+			return nil, nil
+		}
+		scopeId = v.SoftwareSystemId
+	}
+
+	scope, err := c.resolveReference(nil, scopeId)
+	if err != nil {
+		return nil, c.errorAt(view, fmt.Errorf("invalid view scope: %w", err))
+	}
+
+	switch view.(type) {
+	case *parser.SystemContextView, *parser.ContainerView, *parser.DeploymentView:
+		if _, ok := scope.(*parser.SoftwareSystem); !ok {
+			return nil, c.errorAt(view, fmt.Errorf("invalid view scope: %s is not a software system", scopeId))
+		}
+	case *parser.ComponentView:
+		if _, ok := scope.(*parser.Container); !ok {
+			return nil, c.errorAt(view, fmt.Errorf("invalid view scope: %s is not a container", scopeId))
+		}
+	case *parser.DynamicView:
+		switch scope.(type) {
+		case *parser.SoftwareSystem, *parser.Container:
+		default:
+			return nil, c.errorAt(view, fmt.Errorf("invalid view scope: %s is not a software system or container", scopeId))
+		}
+	}
+
+	return scope, nil
+}
+
+// viewContents is an ordered set of entities
+type viewContents struct {
+	elements []parser.Entity
+	has      map[parser.Entity]bool
+}
+
+func (vc *viewContents) add(e parser.Entity) {
+	if vc.has[e] {
+		return
+	}
+	vc.has[e] = true
+	vc.elements = append(vc.elements, e)
+}
+
+func (vc *viewContents) remove(e parser.Entity) {
+	if !vc.has[e] {
+		return
+	}
+	delete(vc.has, e)
+	for i := range vc.elements {
+		if vc.elements[i] == e {
+			vc.elements = append(vc.elements[:i], vc.elements[i+1:]...)
+			return
+		}
+	}
+}
+
+func (c *Checker) evaluateView(m *parser.Model, view parser.View) error {
+	if dynamic, ok := view.(*parser.DynamicView); ok {
+		return c.evaluateDynamicView(m, dynamic)
+	}
+
+	deployment, isDeployment := view.(*parser.DeploymentView)
+	if isDeployment {
+		if err := c.resolveViewEnvironment(m, deployment); err != nil {
+			return err
+		}
+	}
+
+	base := view.Base()
+	rels := m.AllRelationships()
+
+	contents := &viewContents{has: make(map[parser.Entity]bool)}
+	excludedRelationships := make(map[*parser.Relationship]bool)
+
+	for _, expr := range base.Include {
+		selected, err := c.evaluateViewExpression(m, view, expr, rels)
+		if err != nil {
+			return err
+		}
+		for _, e := range selected {
+			contents.add(e)
+		}
+	}
+
+	if isDeployment {
+		// deployment nodes are drawn as boundaries around everything
+		// deployed on them
+		for _, e := range append([]parser.Entity(nil), contents.elements...) {
+			for parent := e.Parent(); parent != nil; parent = parent.Parent() {
+				if _, isNode := parent.(*parser.DeploymentNode); isNode {
+					contents.add(parent)
+				}
+			}
+		}
+	}
+
+	for _, expr := range base.Exclude {
+		if expr.DestinationId != "" {
+			matched, err := c.matchRelationships(expr, rels, expressionScope(view))
+			if err != nil {
+				return err
+			}
+			for _, r := range matched {
+				excludedRelationships[r] = true
+			}
+			continue
+		}
+
+		selected, err := c.evaluateViewExpression(m, view, expr, rels)
+		if err != nil {
+			return err
+		}
+		for _, e := range selected {
+			contents.remove(e)
+		}
+	}
+
+	base.Elements = contents.elements
+	base.ElementIds = make([]parser.IdentifierString, len(contents.elements))
+	for i, e := range contents.elements {
+		base.ElementIds[i] = e.Id()
+	}
+
+	base.Relationships = nil
+	for _, r := range rels {
+		if !contents.has[r.Source] || !contents.has[r.Destination] || excludedRelationships[r] {
+			continue
+		}
+		if isDeployment && r.ImpliedBasedOn != nil && (isDeploymentNode(r.Source) || isDeploymentNode(r.Destination)) {
+			// the edges between what's deployed on the nodes already
+			// show these
+			continue
+		}
+		base.Relationships = append(base.Relationships, r)
+	}
+
+	return nil
+}
+
+// identifiers in deployment views are relative to their environment, and
+// otherwise to the scope of the view
+func expressionScope(view parser.View) parser.Entity {
+	if deployment, ok := view.(*parser.DeploymentView); ok {
+		return deployment.DeploymentEnvironment
+	}
+	return view.Base().Scope
+}
+
+// returns the entities an expression selects, in a stable order
+func (c *Checker) evaluateViewExpression(m *parser.Model, view parser.View, expr *parser.ViewExpression, rels []*parser.Relationship) ([]parser.Entity, error) {
+	scope := expressionScope(view)
+
+	if expr.Wildcard {
+		return defaultViewElements(m, view, rels), nil
+	}
+
+	e, err := c.resolveReference(scope, expr.Id)
+	if err != nil {
+		return nil, c.errorAt(expr, err)
+	}
+	if !permittedInView(view, e) {
+		return nil, c.errorAt(expr, fmt.Errorf("%s cannot be shown in a %s view", e.Id(), viewKind(view)))
+	}
+
+	selected := []parser.Entity{e}
+
+	if expr.DestinationId != "" {
+		dst, err := c.resolveReference(scope, expr.DestinationId)
+		if err != nil {
+			return nil, c.errorAt(expr, err)
+		}
+		if !permittedInView(view, dst) {
+			return nil, c.errorAt(expr, fmt.Errorf("%s cannot be shown in a %s view", dst.Id(), viewKind(view)))
+		}
+		return append(selected, dst), nil
+	}
+
+	for _, r := range rels {
+		if expr.Incoming && r.Destination == e && permittedInView(view, r.Source) {
+			selected = append(selected, r.Source)
+		}
+		if expr.Outgoing && r.Source == e && permittedInView(view, r.Destination) {
+			selected = append(selected, r.Destination)
+		}
+	}
+
+	return selected, nil
+}
+
+func (c *Checker) matchRelationships(expr *parser.ViewExpression, rels []*parser.Relationship, scope parser.Entity) ([]*parser.Relationship, error) {
+	src, err := c.resolveReference(scope, expr.Id)
+	if err != nil {
+		return nil, c.errorAt(expr, err)
+	}
+	dst, err := c.resolveReference(scope, expr.DestinationId)
+	if err != nil {
+		return nil, c.errorAt(expr, err)
+	}
+
+	var matched []*parser.Relationship
+	for _, r := range rels {
+		if r.Source == src && r.Destination == dst {
+			matched = append(matched, r)
+		}
+	}
+	return matched, nil
+}
+
+// the elements `include *` refers to for each type of view
+func defaultViewElements(m *parser.Model, view parser.View, rels []*parser.Relationship) []parser.Entity {
+	var core []parser.Entity
+
+	switch view.(type) {
+	case *parser.SystemLandscapeView:
+		for _, e := range m.Children() {
+			if permittedInView(view, e) {
+				core = append(core, e)
+			}
+		}
+		return core
+
+	case *parser.SystemContextView:
+		core = []parser.Entity{view.Base().Scope}
+
+	case *parser.ContainerView, *parser.ComponentView:
+		core = view.Base().Scope.Base().Children()
+
+	case *parser.DeploymentView:
+		return defaultDeploymentElements(view.(*parser.DeploymentView), rels)
+	}
+
+	// plus everything directly connected to the core elements
+	inCore := make(map[parser.Entity]bool, len(core))
+	for _, e := range core {
+		inCore[e] = true
+	}
+
+	selected := append([]parser.Entity(nil), core...)
+	for _, r := range rels {
+		if inCore[r.Source] && !inCore[r.Destination] && permittedNeighbour(view, r.Destination) {
+			selected = append(selected, r.Destination)
+		}
+		if inCore[r.Destination] && !inCore[r.Source] && permittedNeighbour(view, r.Source) {
+			selected = append(selected, r.Source)
+		}
+	}
+	return selected
+}
+
+// whether an element connected to the core of a view is shown by `include *`
+func permittedNeighbour(view parser.View, e parser.Entity) bool {
+	if !permittedInView(view, e) {
+		return false
+	}
+	switch view.(type) {
+	case *parser.ContainerView:
+		_, isContainer := e.(*parser.Container)
+		return !isContainer
+	case *parser.ComponentView:
+		_, isComponent := e.(*parser.Component)
+		return !isComponent
+	}
+	return true
+}
+
+// which kinds of entities each type of view can show. The scope of container
+// and component views is drawn as a boundary rather than as an element.
+func permittedInView(view parser.View, e parser.Entity) bool {
+	scope := view.Base().Scope
+
+	switch v := view.(type) {
+	case *parser.DynamicView:
+		return permittedInDynamicView(scope, e)
+	case *parser.DeploymentView:
+		return permittedInDeploymentView(v, e)
+	}
+
+	switch e.(type) {
+	case *parser.Person:
+		return true
+
+	case *parser.SoftwareSystem:
+		switch view.(type) {
+		case *parser.ContainerView:
+			return e != scope
+		case *parser.ComponentView:
+			return e != scope.Parent()
+		}
+		return true
+
+	case *parser.Container:
+		switch view.(type) {
+		case *parser.ContainerView:
+			return true
+		case *parser.ComponentView:
+			return e != scope
+		}
+
+	case *parser.Component:
+		_, isComponentView := view.(*parser.ComponentView)
+		return isComponentView
+	}
+
+	return false
+}
+
+// dynamic views show the same kinds of entities as the static view of the
+// same scope, so a system scope shows its own containers and a container
+// scope its own components
+func permittedInDynamicView(scope, e parser.Entity) bool {
+	switch e.(type) {
+	case *parser.Person:
+		return true
+
+	case *parser.SoftwareSystem:
+		switch scope.(type) {
+		case *parser.SoftwareSystem:
+			return e != scope
+		case *parser.Container:
+			return e != scope.Parent()
+		}
+		return true
+
+	case *parser.Container:
+		switch scope.(type) {
+		case *parser.SoftwareSystem:
+			return e.Parent() == scope
+		case *parser.Container:
+			return e != scope
+		}
+
+	case *parser.Component:
+		_, isContainer := scope.(*parser.Container)
+		return isContainer && e.Parent() == scope
+	}
+
+	return false
+}
+
+// every step of a dynamic view must follow a relationship in the model.
+// A step with a description prefers the relationship with the same
+// description, but may also describe an interaction more specifically.
+func (c *Checker) evaluateDynamicView(m *parser.Model, view *parser.DynamicView) error {
+	rels := m.AllRelationships()
+
+	contents := &viewContents{has: make(map[parser.Entity]bool)}
+	shown := make(map[*parser.Relationship]bool)
+	view.Relationships = nil
+
+	for _, step := range view.Steps {
+		src, err := c.resolveReference(view.Scope, step.SourceId)
+		if err != nil {
+			return c.errorAt(step, fmt.Errorf("invalid step source: %w", err))
+		}
+		dst, err := c.resolveReference(view.Scope, step.DestinationId)
+		if err != nil {
+			return c.errorAt(step, fmt.Errorf("invalid step destination: %w", err))
+		}
+
+		for _, e := range []parser.Entity{src, dst} {
+			if !permittedInView(view, e) {
+				return c.errorAt(step, fmt.Errorf("%s cannot be shown in a %s view", e.Id(), viewKind(view)))
+			}
+		}
+
+		var match *parser.Relationship
+		for _, r := range rels {
+			if r.Source != src || r.Destination != dst {
+				continue
+			}
+			if match == nil || (r.Description == step.Description && match.Description != step.Description) {
+				match = r
+			}
+		}
+		if match == nil {
+			return c.errorAt(step, fmt.Errorf("no relationship from %s to %s in the model", src.Id(), dst.Id()))
+		}
+
+		step.Source = src
+		step.Destination = dst
+		step.SourceId = src.Id()
+		step.DestinationId = dst.Id()
+		step.Relationship = match
+		if step.Description == "" {
+			step.Description = match.Description
+		}
+		if step.Technology == "" {
+			step.Technology = match.Technology
+		}
+
+		contents.add(src)
+		contents.add(dst)
+		if !shown[match] {
+			shown[match] = true
+			view.Relationships = append(view.Relationships, match)
+		}
+	}
+
+	view.Elements = contents.elements
+	view.ElementIds = make([]parser.IdentifierString, len(contents.elements))
+	for i, e := range contents.elements {
+		view.ElementIds[i] = e.Id()
+	}
+
+	return nil
+}
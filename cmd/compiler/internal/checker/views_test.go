@@ -0,0 +1,486 @@
+package checker
+
+import (
+	"reflect"
+	"testing"
+
+	"go.burian.dev/c4/cmd/compiler/internal/lexer"
+	"go.burian.dev/c4/cmd/compiler/internal/parser"
+)
+
+const viewsTestModel = `
+	model {
+		u = person 'user' {
+			-> a.web 'browses'
+		}
+		a = softwareSystem 'a' {
+			web = container 'web' {
+				-> api 'calls'
+			}
+			api = container 'api' {
+				handler = component 'handler' {
+					-> db 'queries'
+					-> b 'notifies'
+				}
+				repo = component 'repo'
+			}
+			db = container 'db'
+		}
+		b = softwareSystem 'b'
+		c = softwareSystem 'c'
+	}
+`
+
+func TestChecker_ViewContents(t *testing.T) {
+	tests := []struct {
+		name      string
+		views     string
+		wantKey   string
+		wantElems []parser.IdentifierString
+		wantRels  [][2]parser.IdentifierString
+		wantErr   bool
+	}{
+		{
+			name:      "landscape wildcard",
+			views:     `systemLandscape 'l' { include * }`,
+			wantKey:   "l",
+			wantElems: []parser.IdentifierString{"a", "b", "c", "u"},
+			wantRels:  [][2]parser.IdentifierString{{"a", "b"}, {"u", "a"}},
+		},
+		{
+			name:      "context wildcard",
+			views:     `systemContext a { include * }`,
+			wantKey:   "SystemContext-001",
+			wantElems: []parser.IdentifierString{"a", "b", "u"},
+			wantRels:  [][2]parser.IdentifierString{{"a", "b"}, {"u", "a"}},
+		},
+		{
+			name:      "container wildcard",
+			views:     `container a { include * }`,
+			wantKey:   "Container-001",
+			wantElems: []parser.IdentifierString{"a.api", "a.db", "a.web", "b", "u"},
+			wantRels: [][2]parser.IdentifierString{
+				{"a.api", "a.db"},
+				{"a.api", "b"},
+				{"a.web", "a.api"},
+				{"u", "a.web"},
+			},
+		},
+		{
+			name:      "component wildcard",
+			views:     `component a.api { include * }`,
+			wantKey:   "Component-001",
+			wantElems: []parser.IdentifierString{"a.api.handler", "a.api.repo", "a.db", "b"},
+			wantRels: [][2]parser.IdentifierString{
+				{"a.api.handler", "a.db"},
+				{"a.api.handler", "b"},
+			},
+		},
+		{
+			name:      "explicit and exclude",
+			views:     `systemLandscape { include * ; exclude c u }`,
+			wantKey:   "SystemLandscape-001",
+			wantElems: []parser.IdentifierString{"a", "b"},
+			wantRels:  [][2]parser.IdentifierString{{"a", "b"}},
+		},
+		{
+			name:      "neighbours",
+			views:     `container a { include ->api-> }`,
+			wantKey:   "Container-001",
+			wantElems: []parser.IdentifierString{"a.api", "a.db", "b", "a.web"},
+			wantRels: [][2]parser.IdentifierString{
+				{"a.api", "a.db"},
+				{"a.api", "b"},
+				{"a.web", "a.api"},
+			},
+		},
+		{
+			name:      "exclude relationship",
+			views:     `container a { include web api db ; exclude web -> api }`,
+			wantKey:   "Container-001",
+			wantElems: []parser.IdentifierString{"a.web", "a.api", "a.db"},
+			wantRels:  [][2]parser.IdentifierString{{"a.api", "a.db"}},
+		},
+		{
+			name:      "dynamic system scope",
+			views:     `dynamic a { u -> web ; web -> api ; api -> db }`,
+			wantKey:   "Dynamic-001",
+			wantElems: []parser.IdentifierString{"u", "a.web", "a.api", "a.db"},
+			wantRels: [][2]parser.IdentifierString{
+				{"u", "a.web"},
+				{"a.web", "a.api"},
+				{"a.api", "a.db"},
+			},
+		},
+		{
+			name:      "dynamic wildcard scope",
+			views:     `dynamic * { u -> a ; a -> b ; u -> a }`,
+			wantKey:   "Dynamic-001",
+			wantElems: []parser.IdentifierString{"u", "a", "b"},
+			wantRels:  [][2]parser.IdentifierString{{"u", "a"}, {"a", "b"}},
+		},
+		{
+			name:    "dynamic step without relationship",
+			views:   `dynamic * { b -> a }`,
+			wantErr: true,
+		},
+		{
+			name:    "dynamic step not permitted",
+			views:   `dynamic * { u -> a.web }`,
+			wantErr: true,
+		},
+		{
+			name:    "dynamic scope of wrong type",
+			views:   `dynamic u { u -> a }`,
+			wantErr: true,
+		},
+		{
+			name:    "scope of wrong type",
+			views:   `container a.api { include * }`,
+			wantErr: true,
+		},
+		{
+			name:    "unknown scope",
+			views:   `systemContext nope { include * }`,
+			wantErr: true,
+		},
+		{
+			name:    "element not permitted",
+			views:   `systemContext a { include a.api }`,
+			wantErr: true,
+		},
+		{
+			name:    "duplicate keys",
+			views:   "systemContext a 'k' { include * }\nsystemContext b 'k' { include * }",
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w, err := checkSource(t, "workspace {\n"+viewsTestModel+"\nviews {\n"+tt.views+"\n}\n}")
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("Checker.CheckWorkspaces() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if err != nil {
+				t.Log(err)
+				return
+			}
+
+			view := w.Views.All()[0].Base()
+
+			if view.Key != tt.wantKey {
+				t.Errorf("got view key %s, want %s", view.Key, tt.wantKey)
+			}
+
+			if !reflect.DeepEqual(view.ElementIds, tt.wantElems) {
+				t.Errorf("got elements %v, want %v", view.ElementIds, tt.wantElems)
+			}
+
+			var gotRels [][2]parser.IdentifierString
+			for _, r := range view.Relationships {
+				gotRels = append(gotRels, [2]parser.IdentifierString{r.SourceId, r.DestinationId})
+			}
+			if !reflect.DeepEqual(gotRels, tt.wantRels) {
+				t.Errorf("got relationships %v, want %v", gotRels, tt.wantRels)
+			}
+		})
+	}
+}
+
+func TestChecker_DynamicSteps(t *testing.T) {
+	w, err := checkSource(t, `workspace {
+		model {
+			u = person 'user'
+			a = softwareSystem 'a' {
+				web = container 'web' {
+					-> api 'reads' 'http'
+					-> api 'writes' 'grpc'
+				}
+				api = container 'api'
+			}
+		}
+		views {
+			dynamic a {
+				web -> api 'writes'
+				web -> api
+				web -> api 'deletes everything'
+			}
+		}
+	}`)
+	if err != nil {
+		t.Fatalf("unexpected check error: %s", err)
+	}
+
+	steps := w.Views.DynamicViews[0].Steps
+	want := []struct {
+		description, technology, relationship string
+	}{
+		{"writes", "grpc", "writes"},
+		{"reads", "http", "reads"},
+		{"deletes everything", "http", "reads"},
+	}
+
+	for i, step := range steps {
+		if step.SourceId != "a.web" || step.DestinationId != "a.api" {
+			t.Errorf("step %s resolved to %s -> %s", step.Order, step.SourceId, step.DestinationId)
+		}
+		if step.Description != want[i].description || step.Technology != want[i].technology {
+			t.Errorf("step %s got %q %q, want %q %q", step.Order, step.Description, step.Technology, want[i].description, want[i].technology)
+		}
+		if step.Relationship.Description != want[i].relationship {
+			t.Errorf("step %s matched relationship %q, want %q", step.Order, step.Relationship.Description, want[i].relationship)
+		}
+	}
+}
+
+const deploymentTestModel = `
+	model {
+		a = softwareSystem 'a' {
+			web = container 'web' {
+				-> api 'calls'
+			}
+			api = container 'api' {
+				-> b 'notifies'
+			}
+		}
+		b = softwareSystem 'b'
+		prod = deploymentEnvironment 'Production' {
+			edge = deploymentNode 'edge' {
+				lb = infrastructureNode 'lb' {
+					-> web1 'routes'
+				}
+				web1 = containerInstance a.web
+			}
+			dc = deploymentNode 'dc' {
+				api1 = containerInstance a.api
+				b1 = softwareSystemInstance b
+			}
+			empty = deploymentNode 'empty'
+		}
+		staging = deploymentEnvironment 'Staging' {
+			s = deploymentNode 's' {
+				containerInstance a.web
+			}
+		}
+	}
+`
+
+func TestChecker_DeploymentViews(t *testing.T) {
+	tests := []struct {
+		name      string
+		views     string
+		wantKey   string
+		wantElems []parser.IdentifierString
+		wantRels  [][2]parser.IdentifierString
+		wantErr   bool
+	}{
+		{
+			name:      "unscoped wildcard",
+			views:     `deployment * prod { include * }`,
+			wantKey:   "Deployment-001",
+			wantElems: []parser.IdentifierString{"prod.dc", "prod.dc.api1", "prod.dc.b1", "prod.edge", "prod.edge.lb", "prod.edge.web1", "prod.empty"},
+			wantRels: [][2]parser.IdentifierString{
+				{"prod.dc.api1", "prod.dc.b1"},
+				{"prod.edge.lb", "prod.edge.web1"},
+				{"prod.edge.web1", "prod.dc.api1"},
+			},
+		},
+		{
+			name:      "scoped wildcard by environment name",
+			views:     `deployment a 'Production' 'k' { include * }`,
+			wantKey:   "k",
+			wantElems: []parser.IdentifierString{"prod.dc.api1", "prod.edge.web1", "prod.dc.b1", "prod.edge.lb", "prod.dc", "prod.edge"},
+			wantRels: [][2]parser.IdentifierString{
+				{"prod.dc.api1", "prod.dc.b1"},
+				{"prod.edge.lb", "prod.edge.web1"},
+				{"prod.edge.web1", "prod.dc.api1"},
+			},
+		},
+		{
+			name:      "explicit elements are drawn inside their nodes",
+			views:     `deployment * prod { include edge.web1 dc.api1 }`,
+			wantKey:   "Deployment-001",
+			wantElems: []parser.IdentifierString{"prod.edge.web1", "prod.dc.api1", "prod.edge", "prod.dc"},
+			wantRels:  [][2]parser.IdentifierString{{"prod.edge.web1", "prod.dc.api1"}},
+		},
+		{
+			name:    "unknown environment",
+			views:   `deployment * 'Development' { include * }`,
+			wantErr: true,
+		},
+		{
+			name:    "element from another environment",
+			views:   `deployment * prod { include staging.s }`,
+			wantErr: true,
+		},
+		{
+			name:    "static element",
+			views:   `deployment * prod { include a }`,
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w, err := checkSource(t, "workspace {\n"+deploymentTestModel+"\nviews {\n"+tt.views+"\n}\n}")
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("Checker.CheckWorkspaces() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if err != nil {
+				t.Log(err)
+				return
+			}
+
+			view := w.Views.All()[0].Base()
+
+			if view.Key != tt.wantKey {
+				t.Errorf("got view key %s, want %s", view.Key, tt.wantKey)
+			}
+
+			if !reflect.DeepEqual(view.ElementIds, tt.wantElems) {
+				t.Errorf("got elements %v, want %v", view.ElementIds, tt.wantElems)
+			}
+
+			var gotRels [][2]parser.IdentifierString
+			for _, r := range view.Relationships {
+				gotRels = append(gotRels, [2]parser.IdentifierString{r.SourceId, r.DestinationId})
+			}
+			if !reflect.DeepEqual(gotRels, tt.wantRels) {
+				t.Errorf("got relationships %v, want %v", gotRels, tt.wantRels)
+			}
+		})
+	}
+}
+
+func TestChecker_ReplicatedRelationships(t *testing.T) {
+	w, err := checkSource(t, "workspace {\n"+deploymentTestModel+"\n}")
+	if err != nil {
+		t.Fatalf("unexpected check error: %s", err)
+	}
+
+	c := new(Checker)
+	if err := c.CheckWorkspaces([]*parser.Workspace{w}, nil); err != nil {
+		t.Fatalf("unexpected error checking twice: %s", err)
+	}
+
+	var got [][2]parser.IdentifierString
+	for _, r := range w.Model.AllRelationships() {
+		if r.LinkedTo == nil {
+			continue
+		}
+		if r.Description != r.LinkedTo.Description {
+			t.Errorf("replicated relationship has description %q, want %q", r.Description, r.LinkedTo.Description)
+		}
+		got = append(got, [2]parser.IdentifierString{r.SourceId, r.DestinationId})
+	}
+
+	want := [][2]parser.IdentifierString{
+		{"prod.dc.api1", "prod.dc.b1"},
+		{"prod.edge.web1", "prod.dc.api1"},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("got replicated relationships %v, want %v", got, want)
+	}
+}
+
+func TestChecker_ReplicatedImpliedRelationships(t *testing.T) {
+	w, err := checkSource(t, `workspace {
+		model {
+			a = softwareSystem 'a' {
+				web = container 'web' {
+					c = component 'c' {
+						-> api 'calls'
+						-> b 'notifies'
+					}
+				}
+				api = container 'api'
+			}
+			b = softwareSystem 'b'
+			prod = deploymentEnvironment 'Production' {
+				n = deploymentNode 'n' {
+					web1 = containerInstance a.web
+					api1 = containerInstance a.api
+					a1 = softwareSystemInstance a
+					b1 = softwareSystemInstance b
+				}
+			}
+		}
+	}`)
+	if err != nil {
+		t.Fatalf("unexpected check error: %s", err)
+	}
+
+	got := make(map[[2]parser.IdentifierString]bool)
+	for _, r := range w.Model.AllRelationships() {
+		if r.LinkedTo == nil {
+			continue
+		}
+		if r.LinkedTo.ImpliedBasedOn == nil {
+			t.Errorf("replicated relationship %s -> %s should be linked to an implied one", r.SourceId, r.DestinationId)
+		}
+		got[[2]parser.IdentifierString{r.SourceId, r.DestinationId}] = true
+	}
+
+	want := map[[2]parser.IdentifierString]bool{
+		{"prod.n.web1", "prod.n.api1"}: true,
+		{"prod.n.web1", "prod.n.b1"}:   true,
+		{"prod.n.a1", "prod.n.b1"}:     true,
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("got replicated relationships %v, want %v", got, want)
+	}
+}
+
+func TestChecker_Themes(t *testing.T) {
+	deps := &mockDependencies{
+		sources: map[string]string{
+			"test": `workspace {
+				views {
+					themes 'base.c4' 'corp.json'
+					styles {
+						element 'Database' { background '#000000' }
+						relationship 'async' { thickness 3 }
+					}
+				}
+			}`,
+			"base.c4": `styles {
+				element 'Database' { shape cylinder; background '#ff0000'; color '#ff0000' }
+				element 'Person' { shape person }
+			}`,
+			"corp.json": `{
+				"name": "corp",
+				"elements": [
+					{"tag": "Database", "color": "#00ff00", "stroke": "#0000ff"}
+				],
+				"relationships": [
+					{"tag": "async", "style": "Dashed", "thickness": 1, "routing": "Orthogonal"}
+				]
+			}`,
+		},
+		l: new(lexer.Lexer),
+		p: new(parser.Parser),
+	}
+
+	w, err := deps.p.Run("test", deps)
+	if err != nil {
+		t.Fatalf("parse error in test input: %s", err)
+	}
+	if err := new(Checker).CheckWorkspaces([]*parser.Workspace{w}, deps); err != nil {
+		t.Fatalf("unexpected check error: %s", err)
+	}
+
+	styles := w.Views.Styles
+	dashed := true
+	want := &parser.Styles{
+		Elements: []*parser.ElementStyle{
+			{Tag: "Database", Shape: parser.ShapeCylinder, Background: "#000000", Color: "#00ff00", Stroke: "#0000ff"},
+			{Tag: "Person", Shape: parser.ShapePerson},
+		},
+		Relationships: []*parser.RelationshipStyle{
+			{Tag: "async", Thickness: 3, Dashed: &dashed, Routing: parser.RoutingOrthogonal},
+		},
+		Themes: []string{"base.c4", "corp.json"},
+	}
+	if !reflect.DeepEqual(styles, want) {
+		t.Errorf("got merged styles %+v, want %+v", styles, want)
+	}
+}
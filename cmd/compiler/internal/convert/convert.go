@@ -0,0 +1,793 @@
+package convert
+
+import (
+	"fmt"
+	"path"
+	"sort"
+	"strings"
+
+	"go.burian.dev/c4/cmd/compiler/internal/lexer"
+)
+
+// Problem is a construct that couldn't be translated. Statements that
+// can't be translated at all are left in the output commented out.
+type Problem struct {
+	File    string
+	Line    int
+	Message string
+}
+
+func (p *Problem) Error() string {
+	return fmt.Sprintf("%s:%d: %s", p.File, p.Line, p.Message)
+}
+
+// Convert translates a file of Structurizr's DSL into this dialect, along
+// with everything in it that couldn't be translated
+func Convert(file string, source []byte) ([]byte, []*Problem) {
+	c := &converter{file: file, blocks: []block{blockRoot}}
+
+	lines := c.scan(string(source))
+
+	// Structurizr's identifiers are flat unless it's told otherwise
+	c.flat = true
+	for _, l := range lines {
+		if len(l.tokens) > 0 && l.tokens[0].is("!identifiers") {
+			c.flat = false
+		}
+	}
+
+	for _, l := range lines {
+		c.line(l)
+	}
+	if len(c.blocks) > 1 && len(lines) > 0 {
+		c.report(lines[len(lines)-1].number, "unexpected end of file: expected '}'")
+	}
+
+	sort.SliceStable(c.problems, func(i, j int) bool { return c.problems[i].Line < c.problems[j].Line })
+	return []byte(c.out.String()), c.problems
+}
+
+// block is the kind of block a statement is in, which decides how it's
+// translated
+type block int
+
+const (
+	blockNone block = iota
+	blockRoot
+	blockWorkspace
+	blockModel
+	blockProperties
+	blockPerspectives
+	blockViews
+	blockView
+	blockDynamic
+	blockStyles
+	blockElementStyle
+	blockRelationshipStyle
+
+	// a block that couldn't be translated, and is commented out whole
+	blockDropped
+)
+
+type converter struct {
+	file string
+	out  strings.Builder
+
+	blocks   []block
+	problems []*Problem
+
+	// whether !identifiers flat needs adding to the workspace, and the
+	// indent of the workspace while it waits to be added
+	flat        bool
+	pendingFlat *string
+}
+
+func (c *converter) report(line int, format string, args ...any) {
+	c.problems = append(c.problems, &Problem{File: c.file, Line: line, Message: fmt.Sprintf(format, args...)})
+}
+
+func (c *converter) current() block {
+	return c.blocks[len(c.blocks)-1]
+}
+
+func (c *converter) line(l *line) {
+	if c.pendingFlat != nil && (len(l.tokens) > 0 || l.comment != "") {
+		// indented like the first line of the workspace
+		indent := l.indent
+		if len(indent) <= len(*c.pendingFlat) || (len(l.tokens) > 0 && l.tokens[0].is("}")) {
+			indent = *c.pendingFlat + "    "
+		}
+		c.write(indent, "!identifiers flat")
+		c.pendingFlat = nil
+	}
+
+	t := l.tokens
+	if len(t) == 0 {
+		c.write(l.indent, l.comment)
+		return
+	}
+
+	if len(t) == 1 && t[0].is("}") {
+		if len(c.blocks) == 1 {
+			c.report(l.number, "unexpected '}'")
+			c.drop(l)
+			return
+		}
+		if c.current() == blockDropped {
+			c.drop(l)
+		} else {
+			c.write(l.indent, withComment("}", l.comment))
+		}
+		c.blocks = c.blocks[:len(c.blocks)-1]
+		return
+	}
+
+	opens, empty := false, false
+	switch {
+	case len(t) >= 2 && t[len(t)-2].is("{") && t[len(t)-1].is("}"):
+		empty = true
+		t = t[:len(t)-2]
+	case t[len(t)-1].is("{"):
+		opens = true
+		t = t[:len(t)-1]
+	}
+
+	if c.current() == blockDropped {
+		c.drop(l)
+		if opens {
+			c.blocks = append(c.blocks, blockDropped)
+		}
+		return
+	}
+
+	words, next, err := c.translate(l, t)
+	if err == nil && (opens || empty) && next == blockNone {
+		err = fmt.Errorf("unexpected block")
+	}
+	if err != nil {
+		c.report(l.number, "%s", err)
+		c.drop(l)
+		if opens {
+			c.blocks = append(c.blocks, blockDropped)
+		}
+		return
+	}
+
+	switch {
+	case opens:
+		words = append(words, "{")
+		c.blocks = append(c.blocks, next)
+	case empty:
+		words = append(words, "{", "}")
+	}
+	c.write(l.indent, withComment(strings.Join(words, " "), l.comment))
+
+	if opens && next == blockWorkspace && c.flat {
+		c.pendingFlat = &l.indent
+	}
+}
+
+func (c *converter) write(indent, text string) {
+	if text != "" {
+		c.out.WriteString(indent)
+		c.out.WriteString(text)
+	}
+	c.out.WriteByte('\n')
+}
+
+// drop comments out a statement that couldn't be translated
+func (c *converter) drop(l *line) {
+	for i, raw := range strings.Split(l.raw, "\n") {
+		indent := l.indent
+		if i > 0 {
+			indent = ""
+		}
+		c.write(indent, "// "+strings.TrimRight(raw, "\r"))
+	}
+}
+
+func withComment(text, comment string) string {
+	if comment == "" {
+		return text
+	}
+	if text == "" {
+		return comment
+	}
+	return text + " " + comment
+}
+
+// translate rewrites a statement, without any '{' it ends with, and gives
+// the kind of block it opens
+func (c *converter) translate(l *line, t []token) ([]string, block, error) {
+	if len(t) == 0 {
+		if c.current() == blockDynamic {
+			// a parallel sequence of steps
+			return nil, blockDynamic, nil
+		}
+		return nil, blockNone, fmt.Errorf("unexpected '{'")
+	}
+
+	if !t[0].quoted && strings.HasPrefix(t[0].text, "!") {
+		return c.directive(l, t)
+	}
+
+	switch c.current() {
+	case blockRoot:
+		// anything but a workspace is a fragment of one to be included
+		switch {
+		case t[0].is("workspace"):
+			return c.workspace(l, t)
+		case t[0].is("model"), t[0].is("views"):
+			return c.workspaceBody(l, t)
+		case t[0].is("styles"):
+			return c.views(l, t)
+		}
+		return c.model(l, t)
+	case blockWorkspace:
+		return c.workspaceBody(l, t)
+	case blockModel:
+		return c.model(l, t)
+	case blockProperties:
+		return c.property(l, t, false)
+	case blockPerspectives:
+		return c.property(l, t, true)
+	case blockViews:
+		return c.views(l, t)
+	case blockView:
+		return c.view(l, t, false)
+	case blockDynamic:
+		return c.view(l, t, true)
+	case blockStyles:
+		return c.styles(l, t)
+	case blockElementStyle:
+		return c.style(l, t, elementStyleProperties, false)
+	case blockRelationshipStyle:
+		return c.style(l, t, relationshipStyleProperties, true)
+	}
+	return nil, blockNone, fmt.Errorf("unknown statement %s", t[0].text)
+}
+
+func (c *converter) directive(l *line, t []token) ([]string, block, error) {
+	name := strings.ToLower(t[0].text)
+	switch name {
+	case "!include":
+		if len(t) != 2 {
+			return nil, blockNone, fmt.Errorf("expected one file to include")
+		}
+		// local files are expected to be converted alongside this one
+		file, pragma := t[1].text, "#include"
+		switch {
+		case strings.Contains(file, "://"):
+			c.report(l.number, "remote files can't be converted, so %s must already be in this dialect", file)
+			pragma = "#fetch"
+		case strings.EqualFold(path.Ext(file), ".dsl"):
+			file = strings.TrimSuffix(file, path.Ext(file)) + ".c4"
+		}
+		quoted, err := quote(file, false)
+		if err != nil {
+			return nil, blockNone, err
+		}
+		return []string{pragma, quoted}, blockNone, nil
+
+	case "!identifiers":
+		if len(t) != 2 || !(t[1].is("flat") || t[1].is("hierarchical")) {
+			return nil, blockNone, fmt.Errorf("expected !identifiers flat or hierarchical")
+		}
+		return []string{t[0].text, strings.ToLower(t[1].text)}, blockNone, nil
+
+	case "!impliedrelationships":
+		if len(t) != 2 || !(t[1].is("true") || t[1].is("false")) {
+			return nil, blockNone, fmt.Errorf("implied relationship strategies other than true or false are not supported")
+		}
+		return []string{t[0].text, strings.ToLower(t[1].text)}, blockNone, nil
+
+	case "!docs", "!adrs":
+		return nil, blockNone, fmt.Errorf("documentation and decision records are not supported")
+
+	case "!constant", "!const", "!var":
+		return nil, blockNone, fmt.Errorf("constants are not supported")
+	}
+	return nil, blockNone, fmt.Errorf("%s has no equivalent", t[0].text)
+}
+
+func (c *converter) workspace(l *line, t []token) ([]string, block, error) {
+	if len(t) > 1 && t[1].is("extends") {
+		if len(t) != 3 {
+			return nil, blockNone, fmt.Errorf("expected one workspace to extend")
+		}
+		// the base workspace decides how identifiers work
+		c.flat = false
+		file := t[2].text
+		if strings.EqualFold(path.Ext(file), ".dsl") {
+			file = strings.TrimSuffix(file, path.Ext(file)) + ".c4"
+		}
+		quoted, err := quote(file, false)
+		if err != nil {
+			return nil, blockNone, err
+		}
+		return []string{t[0].text, t[1].text, quoted}, blockWorkspace, nil
+	}
+
+	args, err := c.arguments(l, t[1:], argString, argString)
+	return append([]string{t[0].text}, args...), blockWorkspace, err
+}
+
+func (c *converter) workspaceBody(l *line, t []token) ([]string, block, error) {
+	switch strings.ToLower(t[0].text) {
+	case "name", "description":
+		return c.value(l, t)
+	case "properties":
+		return c.opening(t, blockProperties)
+	case "model":
+		return c.opening(t, blockModel)
+	case "views":
+		return c.opening(t, blockViews)
+	case "configuration":
+		return nil, blockNone, fmt.Errorf("workspace configuration is not supported")
+	}
+	return nil, blockNone, fmt.Errorf("unknown statement %s in workspace", t[0].text)
+}
+
+// the arguments of everything that declares an element, after its keyword
+var declarations = map[string][]argument{
+	"person":                 {argString, argString, argTags},
+	"softwaresystem":         {argString, argString, argTags},
+	"container":              {argString, argString, argString, argTags},
+	"component":              {argString, argString, argString, argTags},
+	"group":                  {argString},
+	"deploymentenvironment":  {argString},
+	"deploymentnode":         {argString, argString, argString, argTag, argInstances},
+	"infrastructurenode":     {argString, argString, argString, argTags},
+	"containerinstance":      {argIdentifier, argDeploymentGroups, argTags},
+	"softwaresysteminstance": {argIdentifier, argDeploymentGroups, argTags},
+}
+
+// model translates the model and the bodies of everything in it
+func (c *converter) model(l *line, t []token) ([]string, block, error) {
+	var words []string
+	if len(t) > 2 && t[1].is("=") {
+		id, err := identifier(t[0])
+		if err != nil {
+			return nil, blockNone, err
+		}
+		if t[2].is("->") || (len(t) > 3 && t[3].is("->")) {
+			c.report(l.number, "relationships can't be given identifiers, so %s is left out", id)
+		} else {
+			words = []string{id, "="}
+		}
+		t = t[2:]
+	}
+
+	if t[0].is("->") || (len(t) > 2 && t[1].is("->")) {
+		rel, err := c.relationship(l, t)
+		return append(words, rel...), blockModel, err
+	}
+
+	keyword := strings.ToLower(t[0].text)
+	if kinds, ok := declarations[keyword]; ok {
+		args, err := c.arguments(l, t[1:], kinds...)
+		return append(append(words, t[0].text), args...), blockModel, err
+	}
+	if len(words) > 0 {
+		return nil, blockNone, fmt.Errorf("%s can't be given an identifier", t[0].text)
+	}
+
+	switch keyword {
+	case "description", "technology", "url":
+		return c.value(l, t)
+	case "tags":
+		args, err := c.arguments(l, t[1:], argTags)
+		return append([]string{t[0].text}, args...), blockNone, err
+	case "instances":
+		if len(t) != 2 {
+			return nil, blockNone, fmt.Errorf("expected a number of instances")
+		}
+		args, err := c.arguments(l, t[1:], argInstances)
+		if len(args) == 0 {
+			return nil, blockNone, err
+		}
+		return []string{t[0].text, args[0]}, blockNone, err
+	case "properties":
+		return c.opening(t, blockProperties)
+	case "perspectives":
+		return c.opening(t, blockPerspectives)
+	case "deploymentgroup":
+		return nil, blockNone, fmt.Errorf("deployment groups are not supported")
+	case "healthcheck":
+		return nil, blockNone, fmt.Errorf("health checks are not supported")
+	case "enterprise":
+		return nil, blockNone, fmt.Errorf("enterprises are not supported, use a group instead")
+	}
+	return nil, blockNone, fmt.Errorf("unknown statement %s in model", t[0].text)
+}
+
+// relationship translates `[source] -> destination [description] [technology] [tags]`
+func (c *converter) relationship(l *line, t []token) ([]string, error) {
+	var words []string
+	if !t[0].is("->") {
+		source, err := identifier(t[0])
+		if err != nil {
+			return nil, err
+		}
+		words = append(words, source)
+		t = t[1:]
+	}
+	if len(t) < 2 {
+		return nil, fmt.Errorf("expected a destination for the relationship")
+	}
+	destination, err := identifier(t[1])
+	if err != nil {
+		return nil, err
+	}
+	args, err := c.arguments(l, t[2:], argString, argString, argTags)
+	return append(append(words, "->", destination), args...), err
+}
+
+func (c *converter) property(l *line, t []token, perspective bool) ([]string, block, error) {
+	if len(t) < 2 {
+		return nil, blockNone, fmt.Errorf("expected a name and a value")
+	}
+	if perspective && len(t) == 3 {
+		c.report(l.number, "perspective values are not supported, so %q is left out", t[2].text)
+		t = t[:2]
+	}
+	words, err := c.arguments(l, t, argString, argString)
+	return words, blockNone, err
+}
+
+func (c *converter) views(l *line, t []token) ([]string, block, error) {
+	var kinds []argument
+	next := blockView
+	switch strings.ToLower(t[0].text) {
+	case "systemlandscape":
+		kinds = []argument{argString, argString}
+	case "systemcontext", "container", "component":
+		kinds = []argument{argIdentifier, argString, argString}
+	case "dynamic":
+		kinds = []argument{argScope, argString, argString}
+		next = blockDynamic
+	case "deployment":
+		kinds = []argument{argScope, argEnvironment, argString, argString}
+	case "styles":
+		return c.opening(t, blockStyles)
+	case "theme", "themes":
+		return c.themes(l, t)
+	case "filtered", "custom", "image":
+		return nil, blockNone, fmt.Errorf("%s views are not supported", t[0].text)
+	case "branding", "terminology", "properties", "configuration":
+		return nil, blockNone, fmt.Errorf("%s is not supported in views", t[0].text)
+	default:
+		return nil, blockNone, fmt.Errorf("unknown statement %s in views", t[0].text)
+	}
+
+	args, err := c.arguments(l, t[1:], kinds...)
+	return append([]string{t[0].text}, args...), next, err
+}
+
+func (c *converter) themes(l *line, t []token) ([]string, block, error) {
+	for _, theme := range t[1:] {
+		if theme.is("default") {
+			return nil, blockNone, fmt.Errorf("the default theme is not supported")
+		}
+	}
+	args, err := c.arguments(l, t[1:], argTags)
+	return append([]string{t[0].text}, args...), blockNone, err
+}
+
+// view translates the body of a view, where dynamic views also have steps
+func (c *converter) view(l *line, t []token, dynamic bool) ([]string, block, error) {
+	switch strings.ToLower(t[0].text) {
+	case "include", "exclude":
+		if dynamic {
+			return nil, blockNone, fmt.Errorf("dynamic views only show their steps, so %s is not supported", t[0].text)
+		}
+		exprs, err := expressions(t[1:])
+		return append([]string{t[0].text}, exprs...), blockNone, err
+	case "autolayout":
+		layout, err := autoLayout(t[1:])
+		return append([]string{t[0].text}, layout...), blockNone, err
+	case "description":
+		return c.value(l, t)
+	case "title", "animation", "properties", "default":
+		return nil, blockNone, fmt.Errorf("%s is not supported in views", t[0].text)
+	}
+
+	if dynamic && len(t) > 2 && t[1].is("->") {
+		source, err := identifier(t[0])
+		if err != nil {
+			return nil, blockNone, err
+		}
+		destination, err := identifier(t[2])
+		if err != nil {
+			return nil, blockNone, err
+		}
+		args, err := c.arguments(l, t[3:], argString, argString)
+		return append([]string{source, "->", destination}, args...), blockNone, err
+	}
+	return nil, blockNone, fmt.Errorf("unknown statement %s in view", t[0].text)
+}
+
+// expressions checks include and exclude expressions, which are written
+// the same way when they're written at all
+func expressions(t []token) ([]string, error) {
+	var words []string
+	for _, x := range t {
+		if x.quoted || strings.Contains(x.text, "==") {
+			return nil, fmt.Errorf("expression %s is not supported", x.text)
+		}
+		if x.text == "*" || x.text == "->" {
+			words = append(words, x.text)
+			continue
+		}
+		for _, part := range strings.Split(x.text, "->") {
+			if part == "*" {
+				return nil, fmt.Errorf("wildcard relationship expression %s is not supported", x.text)
+			}
+			if part == "" {
+				continue
+			}
+			if _, err := identifier(token{text: part}); err != nil {
+				return nil, err
+			}
+		}
+		words = append(words, x.text)
+	}
+	return words, nil
+}
+
+func autoLayout(t []token) ([]string, error) {
+	var words []string
+	for i, x := range t {
+		switch {
+		case i == 0 && (x.is("tb") || x.is("bt") || x.is("lr") || x.is("rl")):
+			words = append(words, strings.ToLower(x.text))
+		case !x.quoted && isNumber(x.text):
+			words = append(words, x.text)
+		default:
+			return nil, fmt.Errorf("unexpected %s in autoLayout", x.text)
+		}
+	}
+	return words, nil
+}
+
+func (c *converter) styles(l *line, t []token) ([]string, block, error) {
+	switch strings.ToLower(t[0].text) {
+	case "element", "relationship":
+		if len(t) != 2 {
+			return nil, blockNone, fmt.Errorf("expected one tag to style")
+		}
+		next := blockElementStyle
+		if t[0].is("relationship") {
+			next = blockRelationshipStyle
+		}
+		tag, err := c.str(l, t[1])
+		return []string{t[0].text, tag}, next, err
+	case "theme", "themes":
+		return c.themes(l, t)
+	}
+	return nil, blockNone, fmt.Errorf("unknown statement %s in styles", t[0].text)
+}
+
+var elementStyleProperties = map[string]bool{
+	"shape": true, "icon": true, "width": true, "height": true,
+	"background": true, "color": true, "colour": true, "stroke": true,
+	"strokewidth": true, "fontsize": true, "border": true, "opacity": true,
+	"metadata": true, "description": true,
+}
+
+var relationshipStyleProperties = map[string]bool{
+	"thickness": true, "color": true, "colour": true, "dashed": true,
+	"routing": true, "fontsize": true, "width": true, "position": true,
+	"opacity": true,
+}
+
+// style translates `name value` properties of a style. Numbers and
+// booleans are written bare, and anything else is quoted.
+func (c *converter) style(l *line, t []token, properties map[string]bool, relationship bool) ([]string, block, error) {
+	name := strings.ToLower(t[0].text)
+	if len(t) != 2 {
+		return nil, blockNone, fmt.Errorf("expected one value for style property %s", t[0].text)
+	}
+	value := t[1]
+
+	// lines are only solid or dashed
+	if relationship && name == "style" {
+		switch {
+		case value.is("dashed"):
+			return []string{"dashed", "true"}, blockNone, nil
+		case value.is("solid"):
+			return []string{"dashed", "false"}, blockNone, nil
+		}
+		return nil, blockNone, fmt.Errorf("line style %s is not supported", value.text)
+	}
+
+	if !properties[name] {
+		return nil, blockNone, fmt.Errorf("style property %s is not supported", t[0].text)
+	}
+
+	switch {
+	case value.is("true") || value.is("false"):
+		return []string{t[0].text, strings.ToLower(value.text)}, blockNone, nil
+	case !value.quoted && isNumber(value.text):
+		return []string{t[0].text, value.text}, blockNone, nil
+	}
+	str, err := c.str(l, value)
+	return []string{t[0].text, str}, blockNone, err
+}
+
+// opening translates a keyword that only opens a block
+func (c *converter) opening(t []token, next block) ([]string, block, error) {
+	if len(t) != 1 {
+		return nil, blockNone, fmt.Errorf("unexpected %s after %s", t[1].text, t[0].text)
+	}
+	return []string{t[0].text}, next, nil
+}
+
+// value translates a keyword followed by a single string
+func (c *converter) value(l *line, t []token) ([]string, block, error) {
+	if len(t) != 2 {
+		return nil, blockNone, fmt.Errorf("expected one value for %s", t[0].text)
+	}
+	str, err := c.str(l, t[1])
+	return []string{t[0].text, str}, blockNone, err
+}
+
+// argument is the kind of value expected in each position of a statement
+type argument int
+
+const (
+	argString argument = iota
+	// a single string of tags
+	argTag
+	// every remaining argument, as strings of tags
+	argTags
+	argIdentifier
+	// an identifier or '*'
+	argScope
+	// an identifier, or a name as a string
+	argEnvironment
+	argInstances
+	argDeploymentGroups
+)
+
+// arguments translates the arguments of a statement in order. Any that
+// are left over are an error.
+func (c *converter) arguments(l *line, t []token, kinds ...argument) ([]string, error) {
+	var words []string
+	for _, kind := range kinds {
+		if len(t) == 0 {
+			break
+		}
+		x := t[0]
+		t = t[1:]
+
+		switch kind {
+		case argString:
+			str, err := c.str(l, x)
+			if err != nil {
+				return nil, err
+			}
+			words = append(words, str)
+
+		case argTag, argTags:
+			tags := []token{x}
+			if kind == argTags {
+				tags, t = append(tags, t...), nil
+			}
+			for _, tag := range tags {
+				// empty tags are only placeholders for later arguments
+				if tag.text == "" {
+					continue
+				}
+				str, err := c.str(l, tag)
+				if err != nil {
+					return nil, err
+				}
+				words = append(words, str)
+			}
+
+		case argScope:
+			if x.is("*") {
+				words = append(words, "*")
+				continue
+			}
+			fallthrough
+		case argIdentifier:
+			id, err := identifier(x)
+			if err != nil {
+				return nil, err
+			}
+			words = append(words, id)
+
+		case argEnvironment:
+			if id, err := identifier(x); err == nil {
+				words = append(words, id)
+				continue
+			}
+			str, err := c.str(l, x)
+			if err != nil {
+				return nil, err
+			}
+			words = append(words, str)
+
+		case argInstances:
+			switch {
+			case isNumber(x.text) && !strings.Contains(x.text, "."):
+				words = append(words, x.text)
+			case strings.Contains(x.text, ".."):
+				c.report(l.number, "ranges of instances are not supported, so %s is left out", x.text)
+			default:
+				return nil, fmt.Errorf("expected a number of instances, not %s", x.text)
+			}
+
+		case argDeploymentGroups:
+			c.report(l.number, "deployment groups are not supported, so %q is left out", x.text)
+		}
+	}
+
+	if len(t) > 0 {
+		return nil, fmt.Errorf("unexpected %s", t[0].text)
+	}
+	return words, nil
+}
+
+// str quotes a string argument. Constants can't be substituted, so they're
+// reported but left in place.
+func (c *converter) str(l *line, x token) (string, error) {
+	if strings.Contains(x.text, "${") {
+		c.report(l.number, "constants are not supported, so %q is left as it is", x.text)
+	}
+	return quote(x.text, x.block)
+}
+
+// quote writes a string in quotes it doesn't contain, preferring single
+// quotes. Only backticks can hold several lines.
+func quote(s string, multiline bool) (string, error) {
+	quotes := "'\"`"
+	if multiline || strings.Contains(s, "\n") {
+		quotes = "`"
+	}
+	for _, q := range quotes {
+		if !strings.ContainsRune(s, q) {
+			return string(q) + s + string(q), nil
+		}
+	}
+	return "", fmt.Errorf("string %q can't be quoted", s)
+}
+
+// identifier checks an identifier can be written the same way in this
+// dialect, which is stricter about what identifiers look like
+func identifier(x token) (string, error) {
+	id := x.text
+	valid := !x.quoted && id != "" && id[0] >= 'a' && id[0] <= 'z'
+	for _, r := range id {
+		if !((r >= 'a' && r <= 'z') || (r >= 'A' && r <= 'Z') || (r >= '0' && r <= '9') || r == '_' || r == '.' || r == '-') {
+			valid = false
+		}
+	}
+	if valid && strings.ContainsAny(id[len(id)-1:], "._-") {
+		valid = false
+	}
+	if !valid {
+		return "", fmt.Errorf("identifier %s is not supported: identifiers start with a lowercase letter, and contain only letters, digits, '_', '-', and '.'", id)
+	}
+
+	if lexer.IsKeyword(id) && !x.is("this") {
+		return "", fmt.Errorf("identifier %s is not supported: it's a keyword", id)
+	}
+	return id, nil
+}
+
+func isNumber(s string) bool {
+	digits := 0
+	for i, r := range s {
+		switch {
+		case r >= '0' && r <= '9':
+			digits++
+		case r == '.' && i > 0 && i < len(s)-1 && !strings.Contains(s[:i], "."):
+		default:
+			return false
+		}
+	}
+	return digits > 0
+}
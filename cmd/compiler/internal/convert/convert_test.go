@@ -0,0 +1,321 @@
+package convert
+
+import (
+	"bytes"
+	"fmt"
+	"reflect"
+	"testing"
+
+	"go.burian.dev/c4/cmd/compiler/internal/checker"
+	"go.burian.dev/c4/cmd/compiler/internal/lexer"
+	"go.burian.dev/c4/cmd/compiler/internal/parser"
+)
+
+func TestConvert(t *testing.T) {
+	tests := []struct {
+		name         string
+		source       string
+		want         string
+		wantProblems []int
+	}{
+		{
+			name: "comments",
+			source: `# hash comment
+// line comment
+/*
+	block comment
+*/
+a = person "A" // trailing
+`,
+			want: `// hash comment
+// line comment
+/*
+	block comment
+*/
+a = person 'A' // trailing
+`,
+		},
+		{
+			name: "bare words are quoted",
+			source: `a = softwareSystem A "It's a system" Internal
+b = container B "" Go "Web Browser,Internal"
+a -> b Uses HTTPS
+`,
+			want: `a = softwareSystem 'A' "It's a system" 'Internal'
+b = container 'B' '' 'Go' 'Web Browser,Internal'
+a -> b 'Uses' 'HTTPS'
+`,
+		},
+		{
+			name: "continuations and text blocks",
+			source: `a = softwareSystem "A" \
+	"Description" {
+	description """
+		Several
+		lines
+	"""
+}
+`,
+			want: "a = softwareSystem 'A' 'Description' {\n\tdescription `\t\tSeveral\n\t\tlines`\n}\n",
+		},
+		{
+			name: "includes",
+			source: `!include people.dsl
+!include "https://example.com/model.dsl"
+`,
+			want: `#include 'people.c4'
+#fetch 'https://example.com/model.dsl'
+`,
+			wantProblems: []int{2},
+		},
+		{
+			name: "flat identifiers are added to workspaces",
+			source: `workspace {
+	model {
+	}
+}
+`,
+			want: `workspace {
+	!identifiers flat
+	model {
+	}
+}
+`,
+		},
+		{
+			name: "identifiers are kept",
+			source: `workspace {
+	!identifiers hierarchical
+	!impliedRelationships false
+}
+`,
+			want: `workspace {
+	!identifiers hierarchical
+	!impliedRelationships false
+}
+`,
+		},
+		{
+			name: "deployment",
+			source: `deploymentEnvironment Live {
+	deploymentNode "Server" "" "Ubuntu" "" 3 {
+		containerInstance api "" "Canary"
+		deploymentNode "Pool" "" "" "" "1..N"
+	}
+}
+`,
+			want: `deploymentEnvironment 'Live' {
+	deploymentNode 'Server' '' 'Ubuntu' 3 {
+		containerInstance api 'Canary'
+		deploymentNode 'Pool' '' ''
+	}
+}
+`,
+			wantProblems: []int{3, 4},
+		},
+		{
+			name: "views",
+			source: `views {
+	systemContext a "Context" {
+		include * ->b-> c->
+		exclude a->c
+		autoLayout LR 200
+	}
+	deployment * Live {
+		include *
+	}
+	dynamic * {
+		a -> b "Asks"
+		{
+			b -> c
+		}
+	}
+}
+`,
+			want: `views {
+	systemContext a 'Context' {
+		include * ->b-> c->
+		exclude a->c
+		autoLayout lr 200
+	}
+	deployment * 'Live' {
+		include *
+	}
+	dynamic * {
+		a -> b 'Asks'
+		{
+			b -> c
+		}
+	}
+}
+`,
+		},
+		{
+			name: "styles",
+			source: `styles {
+	element "Database" {
+		shape Cylinder
+		background #1168bd
+		fontSize 20
+		metadata false
+	}
+	relationship "Async" {
+		style dashed
+		routing Orthogonal
+	}
+}
+`,
+			want: `styles {
+	element 'Database' {
+		shape 'Cylinder'
+		background '#1168bd'
+		fontSize 20
+		metadata false
+	}
+	relationship 'Async' {
+		dashed true
+		routing 'Orthogonal'
+	}
+}
+`,
+		},
+		{
+			name: "untranslatable statements are commented out",
+			source: `!constant NAME "A"
+a = softwareSystem "${NAME}" {
+	!docs docs
+	perspectives {
+		Security "Encrypted" 5
+	}
+}
+API = container "API"
+r = a -> b
+views {
+	image * {
+		plantuml diagram.puml
+	}
+	theme default
+}
+`,
+			want: `// !constant NAME "A"
+a = softwareSystem '${NAME}' {
+	// !docs docs
+	perspectives {
+		'Security' 'Encrypted'
+	}
+}
+// API = container "API"
+a -> b
+views {
+	// image * {
+		// plantuml diagram.puml
+	// }
+	// theme default
+}
+`,
+			wantProblems: []int{1, 2, 3, 5, 8, 9, 11, 14},
+		},
+		{
+			name:         "keywords can't be identifiers",
+			source:       "url = person \"A\"\n",
+			want:         "// url = person \"A\"\n",
+			wantProblems: []int{1},
+		},
+		{
+			name:         "unclosed blocks",
+			source:       "model {\n\ta = person A\n",
+			want:         "model {\n\ta = person 'A'\n",
+			wantProblems: []int{2},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, problems := Convert("test.dsl", []byte(tt.source))
+			if string(got) != tt.want {
+				t.Errorf("got:\n%s\nwant:\n%s", got, tt.want)
+			}
+
+			var lines []int
+			for _, p := range problems {
+				t.Log(p)
+				lines = append(lines, p.Line)
+			}
+			if !reflect.DeepEqual(lines, tt.wantProblems) {
+				t.Errorf("got problems on lines %v, want %v", lines, tt.wantProblems)
+			}
+		})
+	}
+}
+
+type sources map[string][]byte
+
+func (s sources) GetSourceFor(name string) (*bytes.Reader, error) {
+	if source, ok := s[name]; ok {
+		return bytes.NewReader(source), nil
+	}
+	return nil, fmt.Errorf("no such source: %s", name)
+}
+
+func (s sources) GetTokenStreamFor(name string) (lexer.TokenStream, error) {
+	lexed, err := new(lexer.Lexer).Run(name, s)
+	if err != nil {
+		return nil, err
+	}
+	return lexed.TokenStream(), nil
+}
+
+// converted workspaces should compile as they are
+func TestConvertCompiles(t *testing.T) {
+	const source = `workspace "Bank" {
+	model {
+		customer = person "Customer" "" Customer
+		bank = softwareSystem "Internet Banking" {
+			web = container "Web" "" Java
+			db = container "Database" "" Oracle Database
+		}
+		customer -> web "Uses" HTTPS
+		web -> db "Reads from"
+
+		deploymentEnvironment Live {
+			deploymentNode "Data Center" "" "" "" 2 {
+				containerInstance web
+				containerInstance db
+			}
+		}
+	}
+	views {
+		container bank Containers {
+			include *
+			autoLayout lr
+		}
+		deployment bank Live LiveDeployment {
+			include *
+		}
+		styles {
+			element Database {
+				shape Cylinder
+			}
+		}
+	}
+}
+`
+	converted, problems := Convert("bank.dsl", []byte(source))
+	if len(problems) > 0 {
+		t.Fatalf("unexpected problems converting: %v", problems)
+	}
+
+	deps := sources{"bank.c4": converted}
+	w, err := new(parser.Parser).Run("bank.c4", deps)
+	if err != nil {
+		t.Fatalf("converted source doesn't parse: %s\n%s", err, converted)
+	}
+	if err := new(checker.Checker).CheckWorkspaces([]*parser.Workspace{w}, nil); err != nil {
+		t.Fatalf("converted source doesn't check: %s\n%s", err, converted)
+	}
+
+	// Structurizr's identifiers are flat by default
+	if web := w.Model.NamedEntities["bank"].Base().NamedEntities["web"]; web.Id() != "web" {
+		t.Errorf("got identifier %s, want web", web.Id())
+	}
+}
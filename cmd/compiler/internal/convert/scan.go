@@ -0,0 +1,164 @@
+package convert
+
+import (
+	"strings"
+)
+
+// token is one word of a Structurizr DSL statement
+type token struct {
+	// the text of the word, without quotes or escapes
+	text string
+
+	quoted bool
+	// a """ text block, which may run over several lines
+	block bool
+}
+
+func (t token) is(word string) bool {
+	return !t.quoted && strings.EqualFold(t.text, word)
+}
+
+// line is a statement, comment, or blank line of the original source.
+// Statements may run over several lines with continuations or text blocks.
+type line struct {
+	number int
+	indent string
+
+	tokens []token
+
+	// comments are kept as they're written in this dialect
+	comment string
+
+	// the original text, to comment out anything that can't be translated
+	raw string
+}
+
+// scan splits Structurizr DSL into lines. Comments are only comments at
+// the start of a line, except that a statement may end in a // comment.
+func (c *converter) scan(src string) []*line {
+	var lines []*line
+	number := 1
+
+	for len(src) > 0 {
+		end := strings.IndexByte(src, '\n')
+		if end < 0 {
+			end = len(src)
+		}
+		text := strings.TrimRight(src[:end], " \t\r")
+		trimmed := strings.TrimLeft(text, " \t")
+		l := &line{number: number, indent: text[:len(text)-len(trimmed)]}
+		lines = append(lines, l)
+
+		switch {
+		case trimmed == "":
+
+		case strings.HasPrefix(trimmed, "#"):
+			l.comment = "//" + trimmed[1:]
+
+		case strings.HasPrefix(trimmed, "//"):
+			l.comment = trimmed
+
+		case strings.HasPrefix(trimmed, "/*"):
+			start := len(l.indent) + 2
+			end = len(src)
+			if close := strings.Index(src[start:], "*/"); close < 0 {
+				c.report(number, "block comment is never closed")
+			} else if next := strings.IndexByte(src[start+close:], '\n'); next >= 0 {
+				end = start + close + next
+			}
+			l.comment = strings.TrimRight(strings.TrimLeft(src[:end], " \t"), " \t\r")
+
+		default:
+			end = c.scanStatement(l, src, len(l.indent))
+		}
+
+		l.raw = strings.TrimRight(src[len(l.indent):end], " \t\r")
+		number += strings.Count(src[:end], "\n") + 1
+		if end >= len(src) {
+			break
+		}
+		src = src[end+1:]
+	}
+
+	return lines
+}
+
+// scanStatement reads the tokens of a statement starting at i, and returns
+// the offset of the newline ending it
+func (c *converter) scanStatement(l *line, src string, i int) int {
+	number := l.number
+
+	for {
+		for i < len(src) && (src[i] == ' ' || src[i] == '\t' || src[i] == '\r') {
+			i++
+		}
+		if i >= len(src) || src[i] == '\n' {
+			return i
+		}
+
+		switch rest := src[i:]; {
+		case continues(rest):
+			next := strings.IndexByte(rest, '\n')
+			if next < 0 {
+				return len(src)
+			}
+			i += next + 1
+			number++
+
+		case strings.HasPrefix(rest, "//"):
+			next := strings.IndexByte(rest, '\n')
+			if next < 0 {
+				next = len(rest)
+			}
+			l.comment = strings.TrimRight(rest[:next], " \t\r")
+			i += next
+
+		case strings.HasPrefix(rest, `"""`):
+			close := strings.Index(rest[3:], `"""`)
+			next := 3 + close + 3
+			if close < 0 {
+				c.report(number, "text block is never closed")
+				close, next = len(rest)-3, len(rest)
+			}
+			text := rest[3 : 3+close]
+			number += strings.Count(text, "\n")
+			text = strings.TrimPrefix(strings.TrimPrefix(text, "\r"), "\n")
+			l.tokens = append(l.tokens, token{text: strings.TrimRight(text, " \t\r\n"), quoted: true, block: true})
+			i += next
+
+		case rest[0] == '"':
+			text := new(strings.Builder)
+			j := 1
+			for ; j < len(rest) && rest[j] != '"' && rest[j] != '\n'; j++ {
+				if rest[j] == '\\' && j+1 < len(rest) && rest[j+1] == '"' {
+					j++
+				}
+				text.WriteByte(rest[j])
+			}
+			if j >= len(rest) || rest[j] != '"' {
+				c.report(number, "string is never closed")
+			} else {
+				j++
+			}
+			l.tokens = append(l.tokens, token{text: strings.TrimRight(text.String(), "\r"), quoted: true})
+			i += j
+
+		default:
+			j := strings.IndexAny(rest, " \t\r\n")
+			if j < 0 {
+				j = len(rest)
+			}
+			l.tokens = append(l.tokens, token{text: rest[:j]})
+			i += j
+		}
+	}
+}
+
+// a backslash at the end of a line carries the statement on to the next
+func continues(s string) bool {
+	if s[0] != '\\' {
+		return false
+	}
+	s = strings.TrimLeft(s[1:], " \t\r")
+	return s == "" || s[0] == '\n'
+}
@@ -0,0 +1,172 @@
+package diagram
+
+import (
+	"fmt"
+	"io"
+	"strings"
+
+	"go.burian.dev/c4/cmd/compiler/internal/parser"
+)
+
+// Group is an element in a view along with anything drawn inside of it, or
+// a boundary around elements whose parent isn't in the view
+type Group struct {
+	Element  parser.Entity
+	Boundary parser.Entity
+	Members  []*Group
+}
+
+// GroupElements arranges the elements of a view into the boundaries they're
+// drawn in. Elements on deployment nodes are drawn inside the node, and
+// containers and components are drawn inside a boundary for their parent.
+func GroupElements(elements []parser.Entity) []*Group {
+	var top []*Group
+	groups := make(map[parser.Entity]*Group)
+	boundaries := make(map[parser.Entity]*Group)
+
+	for _, e := range elements {
+		groups[e] = &Group{Element: e}
+	}
+
+	for _, e := range elements {
+		g := groups[e]
+		parent := e.Parent()
+
+		switch {
+		case parent != nil && groups[parent] != nil:
+			if _, isNode := parent.(*parser.DeploymentNode); isNode {
+				groups[parent].Members = append(groups[parent].Members, g)
+				continue
+			}
+			top = append(top, g)
+
+		case isBoundaryMember(e) && parent != nil:
+			b, has := boundaries[parent]
+			if !has {
+				b = &Group{Boundary: parent}
+				boundaries[parent] = b
+				top = append(top, b)
+			}
+			b.Members = append(b.Members, g)
+
+		default:
+			top = append(top, g)
+		}
+	}
+
+	return top
+}
+
+func isBoundaryMember(e parser.Entity) bool {
+	switch e.(type) {
+	case *parser.Container, *parser.Component:
+		return true
+	}
+	return false
+}
+
+// Alias returns an identifier with anything other than letters, digits,
+// and underscores replaced, for diagram languages with stricter identifiers.
+// Different identifiers can be aliased the same, so elements are aliased
+// with Aliases instead.
+func Alias(id parser.IdentifierString) string {
+	return strings.Map(func(r rune) rune {
+		if r == '_' || ('a' <= r && r <= 'z') || ('A' <= r && r <= 'Z') || ('0' <= r && r <= '9') {
+			return r
+		}
+		return '_'
+	}, string(id))
+}
+
+// Aliases gives every element drawn in a view its own alias. Identifiers
+// that Alias would make the same are told apart by a numbered suffix, in
+// the order they're first drawn. The zero value is ready to use.
+type Aliases struct {
+	of    map[parser.IdentifierString]string
+	taken map[string]bool
+}
+
+// Of gives the alias of an identifier
+func (a *Aliases) Of(id parser.IdentifierString) string {
+	if alias, has := a.of[id]; has {
+		return alias
+	}
+	if a.of == nil {
+		a.of = make(map[parser.IdentifierString]string)
+		a.taken = make(map[string]bool)
+	}
+
+	alias := Alias(id)
+	for n := 2; a.taken[alias]; n++ {
+		alias = fmt.Sprintf("%s_%d", Alias(id), n)
+	}
+	a.of[id] = alias
+	a.taken[alias] = true
+	return alias
+}
+
+// Calls writes groups of elements as the nested macro calls that both
+// C4-PlantUML and Mermaid's C4 diagrams are written in
+type Calls struct {
+	Aliases *Aliases
+	// Element gives the call for a single element
+	Element func(parser.Entity) string
+	// Quote makes a string into an argument of a call
+	Quote func(string) string
+}
+
+// WriteGroup writes an element along with anything drawn inside of it
+func (c *Calls) WriteGroup(w io.Writer, g *Group, indent string) {
+	if g.Element == nil {
+		// a boundary for the parent of elements that isn't in the view itself
+		macro := "System_Boundary"
+		if _, isContainer := g.Boundary.(*parser.Container); isContainer {
+			macro = "Container_Boundary"
+		}
+		fmt.Fprintf(w, "%s%s(%s) {\n", indent, macro, Args(c.Aliases.Of(g.Boundary.Id()), c.Quote(g.Boundary.Base().Name)))
+		for _, member := range g.Members {
+			c.WriteGroup(w, member, indent+"    ")
+		}
+		fmt.Fprintf(w, "%s}\n", indent)
+		return
+	}
+
+	fmt.Fprintf(w, "%s%s", indent, c.Element(g.Element))
+	if len(g.Members) == 0 {
+		fmt.Fprintf(w, "\n")
+		return
+	}
+	fmt.Fprintf(w, " {\n")
+	for _, member := range g.Members {
+		c.WriteGroup(w, member, indent+"    ")
+	}
+	fmt.Fprintf(w, "%s}\n", indent)
+}
+
+// Variant gives the macro an element is drawn with, since databases and
+// queues have their own macros rather than a shape
+func Variant(macro, shape string) string {
+	switch shape {
+	case parser.ShapeCylinder:
+		return macro + "Db"
+	case parser.ShapePipe:
+		return macro + "Queue"
+	}
+	return macro
+}
+
+// Quote makes a string into a macro argument, with newlines replaced by
+// whatever the diagram language allows in their place
+func Quote(s, newline string) string {
+	s = strings.ReplaceAll(s, `"`, `'`)
+	s = strings.ReplaceAll(s, "\n", newline)
+	return `"` + s + `"`
+}
+
+// Args joins macro arguments, leaving off any empty trailing ones
+func Args(params ...string) string {
+	for len(params) > 0 && params[len(params)-1] == `""` {
+		params = params[:len(params)-1]
+	}
+	return strings.Join(params, ", ")
+}
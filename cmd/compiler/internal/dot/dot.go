@@ -0,0 +1,287 @@
+package dot
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"strings"
+
+	"go.burian.dev/c4/cmd/compiler/internal/diagram"
+	"go.burian.dev/c4/cmd/compiler/internal/parser"
+)
+
+// Extension is the file extension of rendered graphs
+const Extension = ".dot"
+
+// RenderModel writes every entity and declared relationship of a checked
+// workspace's model as a Graphviz graph. Entities with children are drawn
+// as clusters around them. Implied relationships are left out.
+func RenderModel(out io.Writer, w *parser.Workspace) error {
+	g := newGraph(out, w)
+
+	name := w.Name
+	if name == "" {
+		name = "model"
+	}
+	g.begin(name, parser.LayoutTopBottom)
+
+	if w.Model != nil {
+		for _, e := range w.Model.Children() {
+			g.modelEntity(e, "    ")
+		}
+
+		rels := w.Model.AllRelationships()
+		if len(rels) > 0 {
+			g.printf("\n")
+		}
+		for _, r := range rels {
+			if r.ImpliedBasedOn != nil {
+				continue
+			}
+			g.edge(r)
+		}
+	}
+
+	g.printf("}\n")
+	return g.w.Flush()
+}
+
+// Render writes the contents of a checked view as a Graphviz graph, with
+// the boundaries of the view drawn as clusters
+func Render(out io.Writer, w *parser.Workspace, view parser.View) error {
+	g := newGraph(out, w)
+	base := view.Base()
+
+	direction := parser.LayoutTopBottom
+	if base.AutoLayout != nil {
+		direction = base.AutoLayout.Direction
+	}
+	g.begin(base.Key, direction)
+
+	for _, group := range diagram.GroupElements(base.Elements) {
+		g.viewGroup(group, "    ")
+	}
+
+	if dynamic, ok := view.(*parser.DynamicView); ok {
+		if len(dynamic.Steps) > 0 {
+			g.printf("\n")
+		}
+		for _, step := range dynamic.Steps {
+			r := &parser.Relationship{Source: step.Source, Destination: step.Destination}
+			r.Description = step.Order + ": " + step.Description
+			r.Technology = step.Technology
+			if step.Relationship != nil {
+				r.Tags = step.Relationship.Tags
+			}
+			g.edge(r)
+		}
+	} else {
+		if len(base.Relationships) > 0 {
+			g.printf("\n")
+		}
+		for _, r := range base.Relationships {
+			g.edge(r)
+		}
+	}
+
+	g.printf("}\n")
+	return g.w.Flush()
+}
+
+type graph struct {
+	w      *bufio.Writer
+	styles *parser.Styles
+
+	// entities drawn as clusters, which edges are clipped to
+	clusters map[parser.Entity]bool
+}
+
+func newGraph(out io.Writer, w *parser.Workspace) *graph {
+	g := &graph{
+		w:        bufio.NewWriter(out),
+		clusters: make(map[parser.Entity]bool),
+	}
+	if w.Views != nil {
+		g.styles = w.Views.Styles
+	}
+	return g
+}
+
+func (g *graph) begin(name, direction string) {
+	g.printf("digraph %s {\n", quote(name))
+	g.printf("    compound=true\n")
+	g.printf("    rankdir=%s\n", strings.ToUpper(direction))
+	g.printf("    node [shape=box, style=filled, fillcolor=\"#ffffff\"]\n\n")
+}
+
+// entities with children are drawn as a cluster, with an invisible node
+// inside for relationships to attach to
+func (g *graph) modelEntity(e parser.Entity, indent string) {
+	children := e.Base().Children()
+	if len(children) == 0 {
+		g.node(e, indent)
+		return
+	}
+
+	g.cluster(e, indent)
+	for _, child := range children {
+		g.modelEntity(child, indent+"    ")
+	}
+	g.printf("%s}\n", indent)
+}
+
+func (g *graph) viewGroup(group *diagram.Group, indent string) {
+	if group.Element == nil {
+		g.printf("%ssubgraph %s {\n", indent, quote("cluster_"+string(group.Boundary.Id())))
+		g.printf("%s    label=%s\n", indent, quote(group.Boundary.Base().Name))
+		g.printf("%s    style=dashed\n", indent)
+		for _, member := range group.Members {
+			g.viewGroup(member, indent+"    ")
+		}
+		g.printf("%s}\n", indent)
+		return
+	}
+
+	if len(group.Members) == 0 {
+		g.node(group.Element, indent)
+		return
+	}
+
+	g.cluster(group.Element, indent)
+	for _, member := range group.Members {
+		g.viewGroup(member, indent+"    ")
+	}
+	g.printf("%s}\n", indent)
+}
+
+// cluster opens a cluster for the entity, leaving it to be closed
+func (g *graph) cluster(e parser.Entity, indent string) {
+	g.clusters[e] = true
+	g.printf("%ssubgraph %s {\n", indent, quote("cluster_"+string(e.Id())))
+	g.printf("%s    label=%s\n", indent, quote(label(e)))
+	g.printf("%s    %s [shape=point, style=invis]\n", indent, quote(string(e.Id())))
+}
+
+func (g *graph) node(e parser.Entity, indent string) {
+	style := g.styles.ElementStyle(parser.TagsOf(e))
+
+	attrs := []string{"label=" + quote(label(e))}
+	switch style.Shape {
+	case parser.ShapeCylinder:
+		attrs = append(attrs, "shape=cylinder")
+	case parser.ShapeRoundedBox, parser.ShapePerson:
+		attrs = append(attrs, `style="rounded,filled"`)
+	case parser.ShapeCircle:
+		attrs = append(attrs, "shape=circle")
+	case parser.ShapeEllipse:
+		attrs = append(attrs, "shape=ellipse")
+	case parser.ShapeHexagon:
+		attrs = append(attrs, "shape=hexagon")
+	case parser.ShapeDiamond:
+		attrs = append(attrs, "shape=diamond")
+	case parser.ShapeFolder:
+		attrs = append(attrs, "shape=folder")
+	case parser.ShapeComponent:
+		attrs = append(attrs, "shape=component")
+	}
+	if style.Background != "" {
+		attrs = append(attrs, "fillcolor="+quote(style.Background))
+	}
+	if style.Color != "" {
+		attrs = append(attrs, "fontcolor="+quote(style.Color))
+	}
+	if style.Stroke != "" {
+		attrs = append(attrs, "color="+quote(style.Stroke))
+	}
+
+	g.printf("%s%s [%s]\n", indent, quote(string(e.Id())), strings.Join(attrs, ", "))
+}
+
+func (g *graph) edge(r *parser.Relationship) {
+	text := r.Description
+	if r.Technology != "" {
+		text += "\n[" + r.Technology + "]"
+	}
+
+	attrs := []string{"label=" + quote(text)}
+	if g.clusters[r.Source] {
+		attrs = append(attrs, "ltail="+quote("cluster_"+string(r.Source.Id())))
+	}
+	if g.clusters[r.Destination] {
+		attrs = append(attrs, "lhead="+quote("cluster_"+string(r.Destination.Id())))
+	}
+
+	style := g.styles.RelationshipStyle(parser.TagsOf(r))
+	if style.Dashed != nil && *style.Dashed {
+		attrs = append(attrs, "style=dashed")
+	}
+	if style.Color != "" {
+		attrs = append(attrs, "color="+quote(style.Color), "fontcolor="+quote(style.Color))
+	}
+
+	g.printf("    %s -> %s [%s]\n", quote(string(r.Source.Id())), quote(string(r.Destination.Id())), strings.Join(attrs, ", "))
+}
+
+// label describes an entity by its name, type and technology, and description
+func label(e parser.Entity) string {
+	b := e.Base()
+	name, kind, technology, description := b.Name, "", b.Technology, b.Description
+
+	switch obj := e.(type) {
+	case *parser.Person:
+		kind = "Person"
+	case *parser.SoftwareSystem:
+		kind = "Software System"
+	case *parser.Container:
+		kind = "Container"
+	case *parser.Component:
+		kind = "Component"
+	case *parser.DeploymentEnvironment:
+		kind = "Deployment Environment"
+	case *parser.DeploymentNode:
+		kind = "Deployment Node"
+		if obj.Instances > 1 {
+			name = fmt.Sprintf("%s (x%d)", name, obj.Instances)
+		}
+	case *parser.InfrastructureNode:
+		kind = "Infrastructure Node"
+	case *parser.ContainerInstance:
+		kind = "Container Instance"
+		if obj.Container != nil {
+			deployed := obj.Container.Base()
+			name, technology, description = deployed.Name, deployed.Technology, deployed.Description
+		}
+	case *parser.SoftwareSystemInstance:
+		kind = "Software System Instance"
+		if obj.SoftwareSystem != nil {
+			deployed := obj.SoftwareSystem.Base()
+			name, technology, description = deployed.Name, deployed.Technology, deployed.Description
+		}
+	}
+
+	if name == "" {
+		name = string(e.Id())
+	}
+	text := name
+	if technology != "" {
+		kind += ": " + technology
+	}
+	if kind != "" {
+		text += "\n[" + kind + "]"
+	}
+	if description != "" {
+		text += "\n\n" + description
+	}
+	return text
+}
+
+func (g *graph) printf(format string, a ...any) {
+	fmt.Fprintf(g.w, format, a...)
+}
+
+func quote(s string) string {
+	s = strings.ReplaceAll(s, `\`, `\\`)
+	s = strings.ReplaceAll(s, `"`, `\"`)
+	s = strings.ReplaceAll(s, "\n", `\n`)
+	return `"` + s + `"`
+}
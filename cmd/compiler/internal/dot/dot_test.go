@@ -0,0 +1,59 @@
+package dot
+
+import (
+	"strings"
+	"testing"
+
+	"go.burian.dev/c4/cmd/compiler/internal/parser"
+)
+
+func TestRenderModel(t *testing.T) {
+	a := &parser.SoftwareSystem{}
+	a.SetId("a")
+	a.Name = "a"
+
+	b := &parser.SoftwareSystem{}
+	b.SetId("b")
+
+	api := &parser.Container{}
+	api.SetId("api")
+	api.SetFullyQualifiedId("a.api")
+	api.SetParent(a)
+	api.Name = "api"
+	api.Technology = "go"
+	a.Add(api)
+
+	declared := &parser.Relationship{Source: api, Destination: b}
+	declared.Description = "calls"
+	implied := &parser.Relationship{Source: a, Destination: b, ImpliedBasedOn: declared}
+	api.Relationships = []*parser.Relationship{declared}
+	a.Relationships = []*parser.Relationship{implied}
+
+	m := new(parser.Model)
+	m.Add(b)
+	m.Add(a)
+
+	buf := new(strings.Builder)
+	if err := RenderModel(buf, &parser.Workspace{Model: m}); err != nil {
+		t.Fatalf("unexpected render error: %s", err)
+	}
+
+	want := `digraph "model" {
+    compound=true
+    rankdir=TB
+    node [shape=box, style=filled, fillcolor="#ffffff"]
+
+    subgraph "cluster_a" {
+        label="a\n[Software System]"
+        "a" [shape=point, style=invis]
+        "a.api" [label="api\n[Container: go]"]
+    }
+    "b" [label="b\n[Software System]"]
+
+    "a.api" -> "b" [label="calls"]
+}
+`
+	if got := buf.String(); got != want {
+		t.Errorf("got\n%s\nwant\n%s", got, want)
+	}
+}
@@ -0,0 +1,143 @@
+package formatter
+
+import (
+	"bytes"
+	"fmt"
+	"strings"
+)
+
+// lines of context around each change in a diff
+const context = 3
+
+type edit struct {
+	op   byte
+	line string
+}
+
+// Diff gives the changes from a file to its formatted source as a unified
+// diff, or nothing if they're the same
+func Diff(name string, source, formatted []byte) []byte {
+	if bytes.Equal(source, formatted) {
+		return nil
+	}
+
+	edits := diffLines(splitLines(source), splitLines(formatted))
+
+	// the line of each file that each edit is at
+	from := make([]int, len(edits)+1)
+	to := make([]int, len(edits)+1)
+	for i, e := range edits {
+		from[i+1], to[i+1] = from[i], to[i]
+		if e.op != '+' {
+			from[i+1]++
+		}
+		if e.op != '-' {
+			to[i+1]++
+		}
+	}
+
+	out := new(bytes.Buffer)
+	fmt.Fprintf(out, "--- %s.orig\n+++ %s\n", name, name)
+
+	for i := 0; i < len(edits); {
+		if edits[i].op == ' ' {
+			i++
+			continue
+		}
+
+		// changes close enough to share their context share a hunk
+		end := i + 1
+		for j := i; j < len(edits) && j-end < 2*context; j++ {
+			if edits[j].op != ' ' {
+				end = j + 1
+			}
+		}
+		start := i - context
+		if start < 0 {
+			start = 0
+		}
+		stop := end + context
+		if stop > len(edits) {
+			stop = len(edits)
+		}
+
+		fmt.Fprintf(out, "@@ -%s +%s @@\n", span(from[start], from[stop]), span(to[start], to[stop]))
+		for _, e := range edits[start:stop] {
+			out.WriteByte(e.op)
+			out.WriteString(e.line)
+			if !strings.HasSuffix(e.line, "\n") {
+				out.WriteString("\n\\ No newline at end of file\n")
+			}
+		}
+		i = stop
+	}
+
+	return out.Bytes()
+}
+
+// span gives the range of lines of a hunk, counting from one
+func span(start, end int) string {
+	if end-start == 0 {
+		return fmt.Sprintf("%d,0", start)
+	}
+	return fmt.Sprintf("%d,%d", start+1, end-start)
+}
+
+func splitLines(b []byte) []string {
+	lines := strings.SplitAfter(string(b), "\n")
+	if lines[len(lines)-1] == "" {
+		lines = lines[:len(lines)-1]
+	}
+	return lines
+}
+
+// diffLines finds the edits from a to b with their longest common
+// subsequence, after setting aside the lines they start and end with
+func diffLines(a, b []string) []edit {
+	var prefix, suffix []edit
+	for len(a) > 0 && len(b) > 0 && a[0] == b[0] {
+		prefix = append(prefix, edit{' ', a[0]})
+		a, b = a[1:], b[1:]
+	}
+	for len(a) > 0 && len(b) > 0 && a[len(a)-1] == b[len(b)-1] {
+		suffix = append([]edit{{' ', a[len(a)-1]}}, suffix...)
+		a, b = a[:len(a)-1], b[:len(b)-1]
+	}
+
+	// common[i][j] is the length of the longest common subsequence of
+	// a[i:] and b[j:]
+	common := make([][]int, len(a)+1)
+	for i := range common {
+		common[i] = make([]int, len(b)+1)
+	}
+	for i := len(a) - 1; i >= 0; i-- {
+		for j := len(b) - 1; j >= 0; j-- {
+			switch {
+			case a[i] == b[j]:
+				common[i][j] = common[i+1][j+1] + 1
+			case common[i+1][j] >= common[i][j+1]:
+				common[i][j] = common[i+1][j]
+			default:
+				common[i][j] = common[i][j+1]
+			}
+		}
+	}
+
+	edits := prefix
+	i, j := 0, 0
+	for i < len(a) || j < len(b) {
+		switch {
+		case i < len(a) && j < len(b) && a[i] == b[j]:
+			edits = append(edits, edit{' ', a[i]})
+			i++
+			j++
+		case j >= len(b) || i < len(a) && common[i+1][j] >= common[i][j+1]:
+			edits = append(edits, edit{'-', a[i]})
+			i++
+		default:
+			edits = append(edits, edit{'+', b[j]})
+			j++
+		}
+	}
+	return append(edits, suffix...)
+}
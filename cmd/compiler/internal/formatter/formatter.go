@@ -0,0 +1,479 @@
+package formatter
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+
+	"go.burian.dev/c4/cmd/compiler/internal/lexer"
+	"go.burian.dev/c4/cmd/compiler/internal/parser"
+)
+
+const indent = "    "
+
+// Format gives the named source formatted canonically. Workspaces and
+// themes are parsed first, so that only valid files are formatted; anything
+// else, like a fragment for including, only has to lex.
+func Format(target string, deps parser.Provider) ([]byte, error) {
+	source, err := deps.GetSourceFor(target)
+	if err != nil {
+		return nil, err
+	}
+	src, err := io.ReadAll(source)
+	if err != nil {
+		return nil, err
+	}
+
+	stream, err := deps.GetTokenStreamFor(target)
+	if err != nil {
+		return nil, fmt.Errorf("error lexing %s:\n> %w", target, err)
+	}
+	tokens, err := collect(stream)
+	if err != nil {
+		return nil, fmt.Errorf("error lexing %s:\n> %w", target, err)
+	}
+
+	if first := firstToken(tokens); first.Is(lexer.TypeKeyword) {
+		switch strings.ToLower(string(first.BytesAt(src))) {
+		case string(parser.KeywordWorkspace):
+			_, err = new(parser.Parser).Run(target, deps)
+		case string(parser.KeywordStyles):
+			_, err = new(parser.Parser).RunTheme(target, deps)
+		}
+		if err != nil {
+			return nil, fmt.Errorf("error parsing %s:\n> %w", target, err)
+		}
+	}
+
+	p := &printer{src: src, tokens: tokens}
+	p.print()
+	formatted := p.out.Bytes()
+
+	// the formatting must only ever change the layout of the source
+	reformatted, err := new(lexer.Lexer).Run(target, single(formatted))
+	if err == nil {
+		var again []*lexer.Token
+		again, err = collect(reformatted.TokenStream())
+		if err == nil && !equivalent(src, tokens, formatted, again) {
+			err = fmt.Errorf("the formatted source would have a different meaning")
+		}
+	}
+	if err != nil {
+		return nil, fmt.Errorf("error formatting %s:\n> %w", target, err)
+	}
+
+	return formatted, nil
+}
+
+// single provides the only source it has, for lexing
+type single []byte
+
+func (s single) GetSourceFor(string) (*bytes.Reader, error) {
+	return bytes.NewReader(s), nil
+}
+
+// collect reads every token of a stream, up to the end of its file
+func collect(stream lexer.TokenStream) ([]*lexer.Token, error) {
+	var tokens []*lexer.Token
+	for {
+		tok := stream.NextToken()
+		if tok.Is(lexer.TypeError) {
+			return nil, parser.ErrorForToken(tok, fmt.Errorf("invalid syntax"))
+		}
+		if tok == nil {
+			return tokens, nil
+		}
+		tokens = append(tokens, tok)
+		if tok.Is(lexer.TypeEOF) {
+			return tokens, nil
+		}
+	}
+}
+
+func firstToken(tokens []*lexer.Token) *lexer.Token {
+	for _, tok := range tokens {
+		if !tok.Is(lexer.TypeTerminator) {
+			return tok
+		}
+	}
+	return nil
+}
+
+// equivalent compares the tokens of two sources by their values, ignoring
+// the terminators that don't end statements
+func equivalent(a []byte, aTokens []*lexer.Token, b []byte, bTokens []*lexer.Token) bool {
+	x, y := values(a, aTokens), values(b, bTokens)
+	if len(x) != len(y) {
+		return false
+	}
+	for i := range x {
+		if x[i] != y[i] {
+			return false
+		}
+	}
+	return true
+}
+
+func values(src []byte, tokens []*lexer.Token) []string {
+	var vals []string
+	last := lexer.TypeTerminator
+	for i, tok := range tokens {
+		if tok.Is(lexer.TypeTerminator) {
+			if last == lexer.TypeTerminator || last == lexer.TypeStartBlock || last == lexer.TypeEndBlock ||
+				i+1 < len(tokens) && tokens[i+1].Is(lexer.TypeEndBlock, lexer.TypeEOF) {
+				continue
+			}
+		}
+		last = tok.Type()
+
+		text := string(tok.BytesAt(src))
+		switch tok.Type() {
+		case lexer.TypeTerminator:
+			text = ""
+		case lexer.TypeKeyword:
+			text = strings.ToLower(text)
+		case lexer.TypeString:
+			text = parser.MultilineString(text)
+		}
+		vals = append(vals, fmt.Sprintf("%s %s", tok.Type(), text))
+	}
+	return vals
+}
+
+// the parts of a view expression, after include or exclude
+type expression int
+
+const (
+	expressionNone expression = iota
+	expressionStart
+	expressionIncoming
+	expressionIncomingId
+	expressionId
+	expressionDestination
+)
+
+type printer struct {
+	src    []byte
+	tokens []*lexer.Token
+
+	// offsets of the source's newlines, to find the line of anything in it
+	newlines []int
+
+	out bytes.Buffer
+
+	depth int
+
+	// the statement being printed, at the depth it started at
+	line       strings.Builder
+	lineDepth  int
+	trailing   string
+	ended      bool
+	expression expression
+	// the next word follows the last without a space
+	glue bool
+
+	// the source line that the last thing printed ended on
+	lastLine int
+	// the last line printed opened a block
+	opened bool
+}
+
+func (p *printer) print() {
+	for i, c := range p.src {
+		if c == '\n' {
+			p.newlines = append(p.newlines, i)
+		}
+	}
+
+	offset := 0
+	for i, tok := range p.tokens {
+		pos := tok.Positions()
+		p.comments(offset, pos.Start.ByteOffset)
+		offset = pos.End.ByteOffset
+
+		text := string(tok.BytesAt(p.src))
+
+		switch tok.Type() {
+		case lexer.TypeEOF:
+			p.flush()
+
+		case lexer.TypeTerminator:
+			if p.line.Len() > 0 {
+				p.ended = true
+			}
+
+		case lexer.TypeStartBlock:
+			if p.ended {
+				p.flush()
+			}
+			p.word(tok, "{")
+			p.ended = true
+			p.depth++
+
+		case lexer.TypeEndBlock:
+			if p.ended && p.trailing == "" && p.tokens[i-1].Is(lexer.TypeStartBlock) {
+				// empty blocks are kept on one line
+				p.depth--
+				p.ended = false
+				p.glue = true
+				p.word(tok, "}")
+				p.ended = true
+				continue
+			}
+			p.flush()
+			if p.depth > 0 {
+				p.depth--
+			}
+			p.word(tok, "}")
+			p.ended = true
+
+		case lexer.TypeKeyword:
+			lower := strings.ToLower(text)
+			starts := p.line.Len() == 0 || p.ended
+			if starts || i > 0 && p.tokens[i-1].Is(lexer.TypeAssignment, lexer.TypeKeyword) {
+				text = parser.Keyword(lower).Canonical()
+			}
+			p.word(tok, text)
+			if starts && (lower == string(parser.KeywordInclude) || lower == string(parser.KeywordExclude)) {
+				p.expression = expressionStart
+			}
+
+		case lexer.TypeDirective:
+			p.word(tok, text)
+			p.glue = true
+
+		case lexer.TypeString:
+			p.word(tok, p.quote(text))
+
+		case lexer.TypeIdentifier:
+			p.word(tok, text)
+			switch p.expression {
+			case expressionIncoming:
+				p.expression = expressionIncomingId
+			case expressionStart, expressionId, expressionIncomingId:
+				p.expression = expressionId
+			case expressionDestination:
+				p.expression = expressionStart
+			}
+
+		case lexer.TypeRelationship:
+			p.relationship(tok, i)
+
+		case lexer.TypeWildcard:
+			p.word(tok, text)
+			if p.expression != expressionNone {
+				p.expression = expressionStart
+			}
+
+		default:
+			p.word(tok, text)
+		}
+	}
+}
+
+// relationships in view expressions are joined to the identifiers they
+// apply to, other than in a -> b
+func (p *printer) relationship(tok *lexer.Token, i int) {
+	switch p.expression {
+	case expressionNone:
+		p.word(tok, "->")
+
+	case expressionIncomingId:
+		p.glue = true
+		p.word(tok, "->")
+		p.expression = expressionStart
+
+	case expressionId:
+		if i+1 < len(p.tokens) && p.tokens[i+1].Is(lexer.TypeIdentifier) {
+			p.word(tok, "->")
+			p.expression = expressionDestination
+			return
+		}
+		p.glue = true
+		p.word(tok, "->")
+		p.expression = expressionStart
+
+	default:
+		p.word(tok, "->")
+		p.glue = true
+		p.expression = expressionIncoming
+	}
+}
+
+// word adds a token's text to the statement being printed
+func (p *printer) word(tok *lexer.Token, text string) {
+	if p.ended {
+		p.flush()
+	}
+
+	if p.line.Len() == 0 {
+		p.blankLine(p.lineOf(tok.Positions().Start.ByteOffset), text == "}")
+		p.lineDepth = p.depth
+	} else if !p.glue {
+		p.line.WriteByte(' ')
+	}
+	p.glue = false
+
+	p.line.WriteString(text)
+	p.lastLine = p.lineOf(tok.Positions().End.ByteOffset)
+}
+
+// flush prints the statement so far
+func (p *printer) flush() {
+	if p.line.Len() == 0 {
+		return
+	}
+
+	line := strings.Repeat(indent, p.lineDepth) + p.line.String()
+	if p.trailing != "" {
+		line += " " + p.trailing
+	}
+	p.out.WriteString(line)
+	p.out.WriteByte('\n')
+
+	p.opened = strings.HasSuffix(p.line.String(), "{")
+	p.line.Reset()
+	p.trailing = ""
+	p.ended = false
+	p.expression = expressionNone
+	p.glue = false
+}
+
+// blankLine keeps one blank line where the source had any, other than at
+// the start or end of a block
+func (p *printer) blankLine(line int, closing bool) {
+	if p.out.Len() == 0 || p.opened || closing || line <= p.lastLine+1 {
+		return
+	}
+	p.out.WriteByte('\n')
+}
+
+// comments prints those in the source between two tokens. Comments on
+// their own lines are indented with the statements around them, and others
+// stay at the end of the statement they follow.
+func (p *printer) comments(from, to int) {
+	gap := p.src[from:to]
+	for i := 0; i < len(gap); {
+		var end int
+		switch {
+		case bytes.HasPrefix(gap[i:], []byte("//")):
+			end = bytes.IndexByte(gap[i:], '\n')
+		case bytes.HasPrefix(gap[i:], []byte("/*")):
+			end = bytes.Index(gap[i+2:], []byte("*/"))
+			if end >= 0 {
+				end += 4
+			}
+		default:
+			i++
+			continue
+		}
+		if end < 0 {
+			end = len(gap) - i
+		}
+
+		p.comment(from+i, from+i+end)
+		i += end
+	}
+}
+
+func (p *printer) comment(start, end int) {
+	text := strings.TrimRight(string(p.src[start:end]), " \t\r")
+	line := p.lineOf(start)
+	block := strings.HasPrefix(text, "/*")
+
+	if p.line.Len() > 0 && line == p.lastLine {
+		switch {
+		case !block:
+			if p.trailing != "" {
+				p.trailing += " "
+			}
+			p.trailing += text
+			// a statement can only carry on after a comment on a new line
+			if !p.ended {
+				p.flush()
+			}
+		case p.ended:
+			if p.trailing != "" {
+				p.trailing += " "
+			}
+			p.trailing += text
+		default:
+			p.line.WriteByte(' ')
+			p.line.WriteString(text)
+		}
+		p.lastLine = p.lineOf(end)
+		return
+	}
+
+	p.flush()
+	p.blankLine(line, false)
+
+	// the lines of block comments keep their indentation relative to the
+	// first
+	column := start - p.lineStart(start)
+	lines := strings.Split(text, "\n")
+	for i, l := range lines {
+		if i == 0 {
+			l = strings.Repeat(indent, p.depth) + l
+		} else {
+			l = strings.TrimRight(l, " \t\r")
+			if trimmed := strings.TrimLeft(l, " \t"); len(l)-len(trimmed) >= column {
+				l = strings.Repeat(indent, p.depth) + l[column:]
+			}
+		}
+		p.out.WriteString(l)
+		p.out.WriteByte('\n')
+	}
+
+	p.opened = false
+	p.lastLine = p.lineOf(end)
+}
+
+// lineOf gives the line number of a byte offset in the source
+func (p *printer) lineOf(offset int) int {
+	return sort.SearchInts(p.newlines, offset) + 1
+}
+
+func (p *printer) lineStart(offset int) int {
+	line := p.lineOf(offset)
+	if line == 1 {
+		return 0
+	}
+	return p.newlines[line-2] + 1
+}
+
+// quote prefers single quotes, then double, then backticks, and reindents
+// strings over several lines to one level deeper than their statement
+func (p *printer) quote(raw string) string {
+	content := raw[1 : len(raw)-1]
+	if strings.ContainsRune(content, '\\') {
+		return raw
+	}
+
+	if strings.ContainsRune(content, '\n') {
+		value := parser.MultilineString(raw)
+		if strings.ContainsRune(value, '`') {
+			return raw
+		}
+		lines := strings.Split(value, "\n")
+		for i := range lines {
+			if lines[i] != "" {
+				lines[i] = strings.Repeat(indent, p.depth+1) + lines[i]
+			}
+		}
+		reindented := "`\n" + strings.Join(lines, "\n") + "`"
+		if parser.MultilineString(reindented) != value {
+			return raw
+		}
+		return reindented
+	}
+
+	for _, q := range []string{"'", `"`, "`"} {
+		if !strings.Contains(content, q) {
+			return q + content + q
+		}
+	}
+	return raw
+}
@@ -0,0 +1,222 @@
+package formatter
+
+import (
+	"bytes"
+	"fmt"
+	"testing"
+
+	"go.burian.dev/c4/cmd/compiler/internal/lexer"
+)
+
+type sources map[string][]byte
+
+func (s sources) GetSourceFor(name string) (*bytes.Reader, error) {
+	if source, ok := s[name]; ok {
+		return bytes.NewReader(source), nil
+	}
+	return nil, fmt.Errorf("no such source: %s", name)
+}
+
+func (s sources) GetTokenStreamFor(name string) (lexer.TokenStream, error) {
+	lexed, err := new(lexer.Lexer).Run(name, s)
+	if err != nil {
+		return nil, err
+	}
+	return lexed.TokenStream(), nil
+}
+
+func TestFormat(t *testing.T) {
+	tests := []struct {
+		name   string
+		source string
+		want   string
+	}{
+		{
+			name: "indentation and quotes",
+			source: `workspace "w" {
+  model {
+	a = softwaresystem "A" "It's a system"
+      b = person 'B'
+  }
+}`,
+			want: `workspace 'w' {
+    model {
+        a = softwareSystem 'A' "It's a system"
+        b = person 'B'
+    }
+}
+`,
+		},
+		{
+			name: "one statement per line",
+			source: `a = person "A"; b = person "B" {
+    tags 'x'; url 'https://example.com' }
+model { }
+`,
+			want: `a = person 'A'
+b = person 'B' {
+    tags 'x'
+    url 'https://example.com'
+}
+model {}
+`,
+		},
+		{
+			name: "comments",
+			source: `// leading
+
+/* block
+   comment */
+a = person "A" { // opening
+        // own line
+    description "d" /* inline */ // trailing
+        /*
+          indented
+        */
+}
+`,
+			want: `// leading
+
+/* block
+   comment */
+a = person 'A' { // opening
+    // own line
+    description 'd' /* inline */ // trailing
+    /*
+      indented
+    */
+}
+`,
+		},
+		{
+			name: "blank lines",
+			source: `a = person 'A'
+
+
+
+b = person 'B' {
+
+    description 'b'
+
+}
+`,
+			want: `a = person 'A'
+
+b = person 'B' {
+    description 'b'
+}
+`,
+		},
+		{
+			name:   "multiline strings",
+			source: "a = person 'A' {\n  description `\n      first\n        second\n  `\n}\n",
+			want:   "a = person 'A' {\n    description `\n        first\n          second`\n}\n",
+		},
+		{
+			name: "view expressions",
+			source: `systemcontext a {
+    include * -> b ->c-> d-> e->f
+    exclude a->b
+    autolayout lr
+}
+`,
+			want: `systemContext a {
+    include * ->b-> c -> d ->e-> f
+    exclude a -> b
+    autoLayout lr
+}
+`,
+		},
+		{
+			name: "directives and pragmas",
+			source: `workspace {
+    ! identifiers   flat
+    #include "people.c4"
+}
+`,
+			want: `workspace {
+    !identifiers flat
+    #include 'people.c4'
+}
+`,
+		},
+		{
+			name:   "escapes are kept",
+			source: `a = person "it\"s"` + "\n",
+			want:   `a = person "it\"s"` + "\n",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			deps := sources{"test.c4": []byte(tt.source), "people.c4": nil}
+			got, err := Format("test.c4", deps)
+			if err != nil {
+				t.Fatalf("unexpected error: %s", err)
+			}
+			if string(got) != tt.want {
+				t.Errorf("got:\n%s\nwant:\n%s", got, tt.want)
+			}
+
+			// formatting is stable
+			again, err := Format("test.c4", sources{"test.c4": got, "people.c4": nil})
+			if err != nil {
+				t.Fatalf("unexpected error formatting again: %s", err)
+			}
+			if !bytes.Equal(again, got) {
+				t.Errorf("formatting again changed it:\n%s", again)
+			}
+		})
+	}
+}
+
+func TestFormatErrors(t *testing.T) {
+	tests := []struct {
+		name   string
+		source string
+	}{
+		{name: "lexing", source: "a = person 'A\n"},
+		{name: "parsing", source: "workspace {\n    model {\n        a = person\n    }\n}\n"},
+		{name: "themes", source: "styles {\n    element {\n    }\n}\n"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := Format("test.c4", sources{"test.c4": []byte(tt.source)}); err == nil {
+				t.Errorf("expected an error")
+			} else {
+				t.Log(err)
+			}
+		})
+	}
+}
+
+func TestDiff(t *testing.T) {
+	source := "a\nb\nc\nd\ne\nf\ng\nh\ni\nj"
+	formatted := "a\nB\nc\nd\ne\nf\ng\nh\ni\nj\n"
+
+	want := `--- x.c4.orig
++++ x.c4
+@@ -1,5 +1,5 @@
+ a
+-b
++B
+ c
+ d
+ e
+@@ -7,4 +7,4 @@
+ g
+ h
+ i
+-j
+\ No newline at end of file
++j
+`
+	if got := string(Diff("x.c4", []byte(source), []byte(formatted))); got != want {
+		t.Errorf("got:\n%s\nwant:\n%s", got, want)
+	}
+
+	if got := Diff("x.c4", []byte(formatted), []byte(formatted)); got != nil {
+		t.Errorf("got a diff of identical files:\n%s", got)
+	}
+}
@@ -0,0 +1,43 @@
+package lexer
+
+import (
+	"fmt"
+	"strings"
+)
+
+// Error is a problem found in the source while lexing it, and where
+type Error struct {
+	Message   string
+	Positions PositionRange
+
+	token *Token
+}
+
+func (e *Error) Error() string {
+	return fmt.Sprintf("%s at %s", e.Message, &e.Positions)
+}
+
+// TokenAtError gives the error token the lexer put in the stream in place of
+// the source it couldn't make sense of
+func (e *Error) TokenAtError() *Token {
+	return e.token
+}
+
+// Errors is every error found lexing a source, in the order they're in
+type Errors []*Error
+
+func (e Errors) Error() string {
+	msgs := make([]string, len(e))
+	for i := range e {
+		msgs[i] = e[i].Error()
+	}
+	return strings.Join(msgs, "\n")
+}
+
+func (e Errors) Unwrap() []error {
+	errs := make([]error, len(e))
+	for i := range e {
+		errs[i] = e[i]
+	}
+	return errs
+}
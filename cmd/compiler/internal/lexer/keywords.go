@@ -14,6 +14,13 @@ var knownKeywords = []string{
 	"component",
 	"group",
 
+	"deploymentenvironment",
+	"deploymentnode",
+	"infrastructurenode",
+	"containerinstance",
+	"softwaresysteminstance",
+	"instances",
+
 	"perspectives",
 	"tags",
 	"description",
@@ -24,9 +31,23 @@ var knownKeywords = []string{
 	"this",
 
 	"style",
+	"styles",
+	"element",
+	"relationship",
+	"theme",
+	"themes",
+
+	"systemlandscape",
+	"systemcontext",
+	"include",
+	"exclude",
+	"autolayout",
+	"dynamic",
+	"deployment",
 }
 
-func isKeyword(s string) bool {
+// IsKeyword reports whether a word is reserved, regardless of its case
+func IsKeyword(s string) bool {
 	s = strings.ToLower(s)
 	for _, k := range knownKeywords {
 		if s == k {
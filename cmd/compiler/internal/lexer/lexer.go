@@ -23,7 +23,7 @@ type Lexer struct {
 	cursor *PositionRange
 
 	state stateFn
-	err   error
+	errs  Errors
 
 	tokens        []*Token
 	previousToken *Token
@@ -57,7 +57,7 @@ func (l *Lexer) Run(target string, deps Provider) (*LexedSource, error) {
 	l.cursor.truncateForward()
 
 	l.state = rootState
-	l.err = nil
+	l.errs = nil
 
 	l.tokens = make([]*Token, 0, 5)
 
@@ -65,7 +65,12 @@ func (l *Lexer) Run(target string, deps Provider) (*LexedSource, error) {
 		l.state = l.state(l)
 	}
 
-	return &LexedSource{tokens: l.tokens}, l.err
+	// the tokens are still returned with any errors, for tools that can
+	// make use of them anyway
+	if len(l.errs) > 0 {
+		return &LexedSource{tokens: l.tokens}, l.errs
+	}
+	return &LexedSource{tokens: l.tokens}, nil
 }
 
 func (l *Lexer) next() rune {
@@ -127,6 +132,14 @@ func (l *Lexer) backupOne() {
 	l.atEOF = false
 }
 
+// reports if the next unread byte is b without consuming it, as a second
+// rune of lookahead where backing up isn't possible
+func (l *Lexer) peekByte(b byte) bool {
+	buf := make([]byte, 1)
+	n, _ := l.inputBuffer.ReadAt(buf, int64(l.cursor.End.ByteOffset))
+	return n == 1 && buf[0] == b
+}
+
 func (l *Lexer) acceptOne(r ...rune) bool {
 	n := l.next()
 	for i := range r {
@@ -138,10 +151,12 @@ func (l *Lexer) acceptOne(r ...rune) bool {
 	return false
 }
 
+// acceptWhile accepts runes for as long as f allows, stopping at EOF
+// whatever f says, since there's nothing to advance to past it
 func (l *Lexer) acceptWhile(f func(rune) bool) {
 	n := l.next()
 
-	for f(n) {
+	for n != EOF && f(n) {
 		n = l.next()
 	}
 
@@ -179,6 +194,11 @@ func (l *Lexer) createError(err error) {
 	}
 	l.tokens = append(l.tokens, tok)
 	l.previousToken = tok
+	l.errs = append(l.errs, &Error{
+		Message:   err.Error(),
+		Positions: *tok.position,
+		token:     tok,
+	})
 
 	l.discardToCurrent()
 }
@@ -2,6 +2,7 @@ package lexer
 
 import (
 	"bytes"
+	"errors"
 	"fmt"
 	"testing"
 )
@@ -118,11 +119,31 @@ func TestLexer_Run(t *testing.T) {
 				TypeEndBlock, TypeEOF,
 			},
 		},
+		{
+			name:       "identifiers with digits and dots",
+			input:      `a1.b2 -> c`,
+			wantTokens: []TokenType{TypeIdentifier, TypeRelationship, TypeIdentifier, TypeTerminator, TypeEOF},
+		},
+		{
+			name:       "view expressions",
+			input:      "include *\nexclude ->a->\n",
+			wantTokens: []TokenType{TypeKeyword, TypeWildcard, TypeTerminator, TypeKeyword, TypeRelationship, TypeIdentifier, TypeRelationship, TypeTerminator, TypeEOF},
+		},
+		{
+			name:       "numbers",
+			input:      `autoLayout lr 300 12.5`,
+			wantTokens: []TokenType{TypeKeyword, TypeIdentifier, TypeNumber, TypeNumber, TypeTerminator, TypeEOF},
+		},
 		{
 			name:       "pragmas",
 			input:      `"foo" #include_file`,
 			wantTokens: []TokenType{TypeString, TypePragma, TypeEOF},
 		},
+		{
+			name:       "optional pragmas",
+			input:      "#include? 'a.c4'\n#fetch? 'https://example.com/b.c4'",
+			wantTokens: []TokenType{TypePragma, TypeString, TypeTerminator, TypePragma, TypeString, TypeTerminator, TypeEOF},
+		},
 	}
 
 	l := new(Lexer)
@@ -163,3 +184,79 @@ func TestLexer_Run(t *testing.T) {
 		})
 	}
 }
+
+func TestLexer_RunErrors(t *testing.T) {
+	type wantErr struct {
+		message    string
+		line, col  int
+		byteLength int
+	}
+
+	tests := []struct {
+		name  string
+		input string
+		want  []wantErr
+	}{
+		{
+			name: "errors on each line",
+			input: "a = person 'A\n" +
+				"b -> c.\n" +
+				"c => d\n" +
+				"description `never\n" +
+				"ends",
+			want: []wantErr{
+				{"unterminated string: only strings in backticks can span lines", 1, 11, 2},
+				{"illegal identifier suffix character '.'", 2, 5, 2},
+				{"unexpected token '>'", 3, 3, 1},
+				{"unterminated string", 4, 12, 11},
+			},
+		},
+		{
+			name:  "identifier suffix at EOF",
+			input: "a -> b.",
+			want:  []wantErr{{"illegal identifier suffix character '.'", 1, 5, 2}},
+		},
+		{
+			name:  "unexpected token at EOF",
+			input: "u -",
+			want:  []wantErr{{"unexpected token '-'", 1, 2, 1}},
+		},
+		{
+			name:  "number without decimals at EOF",
+			input: "1.",
+			want:  []wantErr{{"illegal number: expected digits after '.'", 1, 0, 2}},
+		},
+		{
+			name:  "unexpected token mid identifier at EOF",
+			input: "a$",
+			want:  []wantErr{{"unexpected token '$'", 1, 1, 1}},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			l := new(Lexer)
+			out, err := l.Run("main.c4", &mockDependencies{sources: map[string]string{"main.c4": tt.input}})
+			if out == nil {
+				t.Fatalf("expected tokens along with the errors")
+			}
+
+			var errs Errors
+			if !errors.As(err, &errs) {
+				t.Fatalf("expected a list of errors, got %v", err)
+			}
+			if len(errs) != len(tt.want) {
+				t.Fatalf("got %d errors, want %d:\n%s", len(errs), len(tt.want), err)
+			}
+			for i, e := range errs {
+				got := wantErr{e.Message, e.Positions.Start.Line, e.Positions.Start.Column, e.Positions.End.ByteOffset - e.Positions.Start.ByteOffset}
+				if got != tt.want[i] {
+					t.Errorf("got error %+v, want %+v", got, tt.want[i])
+				}
+				if !e.TokenAtError().Is(TypeError) {
+					t.Errorf("error %d isn't at an error token", i)
+				}
+			}
+		})
+	}
+}
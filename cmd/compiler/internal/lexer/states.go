@@ -39,13 +39,16 @@ func rootState(l *Lexer) stateFn {
 		case '-':
 			if l.acceptOne('>') {
 				l.createToken(TypeRelationship)
-				continue
+				return spaceWithOptionalTerminatorState
 			}
+		case '*':
+			l.createToken(TypeWildcard)
+			return spaceWithOptionalTerminatorState
 		case ';':
 			l.createToken(TypeTerminator)
 			continue
 		case EOF:
-			if l.previousToken.Is(TypeIdentifier, TypeString) {
+			if l.previousToken.Is(terminatedTokens...) {
 				l.createToken(TypeTerminator)
 			}
 			l.createToken(TypeEOF)
@@ -60,13 +63,26 @@ func rootState(l *Lexer) stateFn {
 			return identifierState
 		}
 
+		if l.currentRune >= '0' && l.currentRune <= '9' {
+			return numberState
+		}
+
 		l.createError(fmt.Errorf("unexpected token %q", l.currentRune))
 		return errorState
 	}
 }
 
+// tokens that will have a terminator inserted if they end a line
+var terminatedTokens = []TokenType{
+	TypeIdentifier,
+	TypeString,
+	TypeNumber,
+	TypeWildcard,
+	TypeRelationship,
+}
+
 func spaceState(l *Lexer) stateFn {
-	if l.previousToken.Is(TypeIdentifier, TypeString) {
+	if l.previousToken.Is(terminatedTokens...) {
 		return spaceWithOptionalTerminatorState
 	}
 	l.acceptWhile(unicode.IsSpace)
@@ -82,6 +98,8 @@ func pragmaState(l *Lexer) stateFn {
 		}
 		return false
 	})
+	// optional variants of pragmas, like #include?
+	l.acceptOne('?')
 
 	l.createToken(TypePragma)
 	return spaceState
@@ -130,7 +148,7 @@ func blockCommentState(l *Lexer) stateFn {
 			}
 		}
 		if l.acceptOne(EOF) {
-			l.createError(fmt.Errorf("unexpected EOF: expected end of block comment"))
+			l.createError(fmt.Errorf("unterminated block comment"))
 			l.backupOne()
 			break
 		}
@@ -170,7 +188,7 @@ func stringState(l *Lexer) stateFn {
 	})
 
 	if l.acceptOne(EOF) {
-		l.createError(fmt.Errorf("unexpected EOF: expected end of string"))
+		l.createError(fmt.Errorf("unterminated string"))
 		l.createToken(TypeEOF)
 		return nil
 	}
@@ -179,8 +197,13 @@ func stringState(l *Lexer) stateFn {
 		return spaceWithOptionalTerminatorState
 	}
 
-	r := l.next()
-	l.createError(fmt.Errorf("unexpected value in string: %q", r))
+	if r := l.next(); r != '\n' {
+		l.createError(fmt.Errorf("unexpected value in string: %q", r))
+		return errorState
+	}
+	// the error is the string up to the end of its line
+	l.backupOne()
+	l.createError(fmt.Errorf("unterminated string: only strings in backticks can span lines"))
 	return errorState
 }
 
@@ -188,8 +211,13 @@ func identifierState(l *Lexer) stateFn {
 	builder := new(strings.Builder)
 	builder.WriteRune(l.currentRune)
 	l.acceptWhile(func(r rune) bool {
+		// identifiers are allowed to contain '-' but not to run into a relationship
+		if r == '-' && l.peekByte('>') {
+			return false
+		}
 		if (r >= 'a' && r <= 'z') ||
 			(r >= 'A' && r <= 'Z') ||
+			(r >= '0' && r <= '9') ||
 			(r == '_' || r == '.' || r == '-') {
 			builder.WriteRune(r)
 			return true
@@ -205,7 +233,7 @@ func identifierState(l *Lexer) stateFn {
 		return errorState
 	}
 
-	if isKeyword(identifier) {
+	if IsKeyword(identifier) {
 		l.createToken(TypeKeyword)
 	} else {
 		l.createToken(TypeIdentifier)
@@ -213,6 +241,22 @@ func identifierState(l *Lexer) stateFn {
 	return spaceWithOptionalTerminatorState
 }
 
+func numberState(l *Lexer) stateFn {
+	isDigit := func(r rune) bool { return r >= '0' && r <= '9' }
+
+	l.acceptWhile(isDigit)
+	if l.acceptOne('.') {
+		if !l.acceptOne('0', '1', '2', '3', '4', '5', '6', '7', '8', '9') {
+			l.createError(fmt.Errorf("illegal number: expected digits after '.'"))
+			return errorState
+		}
+		l.acceptWhile(isDigit)
+	}
+
+	l.createToken(TypeNumber)
+	return spaceWithOptionalTerminatorState
+}
+
 // Dead simple error handling
 // - If at EOF, end
 // - If mid character stream, clear it
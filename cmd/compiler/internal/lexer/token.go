@@ -55,6 +55,8 @@ const (
 	TypeTerminator
 	TypeRelationship
 	TypePragma
+	TypeWildcard
+	TypeNumber
 
 	TypeEOF
 )
@@ -85,6 +87,10 @@ func (t TokenType) String() string {
 		return "End of File"
 	case TypePragma:
 		return "#Pragma"
+	case TypeWildcard:
+		return "'*'"
+	case TypeNumber:
+		return "Number"
 
 	case TypeUndefined:
 		return "[UNDEFINED TOKEN]"
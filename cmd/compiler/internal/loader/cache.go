@@ -0,0 +1,237 @@
+package loader
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// fetcher is a loader that can make requests for external sources itself,
+// so what's cached can be revalidated rather than fetched again
+type fetcher interface {
+	fetch(ctx context.Context, uri string, header http.Header) (*http.Response, error)
+	// verify checks a source, wherever it came from, is still the one that
+	// was fetched before
+	verify(uri string, data []byte) error
+	// fetches tells whether a URI is one that's fetched over http
+	fetches(uri string) bool
+	// allows makes sure a URI may be fetched, so nothing blocked is served
+	// from the cache either
+	allows(uri string) error
+}
+
+type cachingLoader struct {
+	next    Loader
+	dir     string
+	offline bool
+
+	now func() time.Time
+}
+
+// cacheEntry is a fetched source as it's kept in the cache directory
+type cacheEntry struct {
+	URL          string    `json:"url"`
+	ETag         string    `json:"etag,omitempty"`
+	LastModified string    `json:"last_modified,omitempty"`
+	Expires      time.Time `json:"expires"`
+	Data         []byte    `json:"data"`
+}
+
+type cacheOption func(*cachingLoader)
+
+// Offline serves sources from the cache even once they're stale, if the
+// network can't be reached to fetch them again
+func Offline() cacheOption {
+	return func(c *cachingLoader) {
+		c.offline = true
+	}
+}
+
+// NewCachingLoader wraps a loader so that the external sources it fetches
+// are kept in the given directory, and only fetched again once their
+// Cache-Control headers say they're stale
+func NewCachingLoader(l Loader, dir string, opts ...cacheOption) Loader {
+	c := &cachingLoader{
+		next: l,
+		dir:  dir,
+		now:  time.Now,
+	}
+	for i := range opts {
+		opts[i](c)
+	}
+	return c
+}
+
+func (c *cachingLoader) Load(ctx context.Context, uri string) ([]byte, error) {
+	f, ok := c.next.(fetcher)
+	if !ok || !f.fetches(uri) {
+		return c.next.Load(ctx, uri)
+	}
+
+	if err := f.allows(uri); err != nil {
+		return nil, err
+	}
+
+	// fragments aren't fetched, so they're the same source
+	key := withoutFragment(uri)
+
+	entry := c.read(key)
+	if entry != nil && c.now().Before(entry.Expires) {
+		return entry.Data, f.verify(key, entry.Data)
+	}
+
+	header := make(http.Header)
+	if entry != nil && entry.ETag != "" {
+		header.Set("If-None-Match", entry.ETag)
+	}
+	if entry != nil && entry.LastModified != "" {
+		header.Set("If-Modified-Since", entry.LastModified)
+	}
+
+	resp, err := f.fetch(ctx, uri, header)
+	if err != nil {
+		if entry != nil && c.offline && isNetworkError(err) {
+			return entry.Data, f.verify(key, entry.Data)
+		}
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	var data []byte
+	switch {
+	case resp.StatusCode == http.StatusNotModified && entry != nil:
+		data = entry.Data
+		// the validators needn't be repeated to still hold
+		if resp.Header.Get("ETag") == "" {
+			resp.Header.Set("ETag", entry.ETag)
+		}
+		if resp.Header.Get("Last-Modified") == "" {
+			resp.Header.Set("Last-Modified", entry.LastModified)
+		}
+	case resp.StatusCode == http.StatusOK:
+		data, err = io.ReadAll(resp.Body)
+		if err != nil {
+			return nil, fmt.Errorf("connection inturrupted while fetching response: %w", err)
+		}
+	default:
+		return nil, fmt.Errorf("error fetching %s: server returned %s", resp.Request.URL.Redacted(), resp.Status)
+	}
+
+	if err := f.verify(key, data); err != nil {
+		return nil, err
+	}
+
+	// the cache only saves fetching again, so a source that couldn't be
+	// kept is still fine to use
+	_ = c.store(key, resp, data)
+
+	return data, nil
+}
+
+// read gives the cached entry for a URL, or nil if there isn't one that can
+// be used
+func (c *cachingLoader) read(key string) *cacheEntry {
+	data, err := os.ReadFile(c.path(key))
+	if err != nil {
+		return nil
+	}
+	entry := new(cacheEntry)
+	if err := json.Unmarshal(data, entry); err != nil || entry.URL != key {
+		return nil
+	}
+	return entry
+}
+
+// store keeps a fetched source for as long as its response allows
+func (c *cachingLoader) store(key string, resp *http.Response, data []byte) error {
+	maxAge, noStore := cacheControl(resp.Header)
+	if noStore {
+		err := os.Remove(c.path(key))
+		if errors.Is(err, os.ErrNotExist) {
+			return nil
+		}
+		return err
+	}
+
+	if age, err := strconv.Atoi(resp.Header.Get("Age")); err == nil {
+		maxAge -= time.Duration(age) * time.Second
+	}
+
+	entry := &cacheEntry{
+		URL:          key,
+		ETag:         resp.Header.Get("ETag"),
+		LastModified: resp.Header.Get("Last-Modified"),
+		Expires:      c.now().Add(maxAge),
+		Data:         data,
+	}
+	encoded, err := json.Marshal(entry)
+	if err != nil {
+		return err
+	}
+
+	if err := os.MkdirAll(c.dir, 0o755); err != nil {
+		return fmt.Errorf("error creating cache directory %s: %w", c.dir, err)
+	}
+	// written aside and moved into place, so a compile running alongside
+	// never reads half an entry
+	tmp, err := os.CreateTemp(c.dir, "*.tmp")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+	if _, err := tmp.Write(encoded); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), c.path(key))
+}
+
+func (c *cachingLoader) path(key string) string {
+	sum := sha256.Sum256([]byte(key))
+	return filepath.Join(c.dir, hex.EncodeToString(sum[:])+".json")
+}
+
+// cacheControl gives how long a response may be used before it must be
+// revalidated, and whether it mustn't be stored at all
+func cacheControl(header http.Header) (maxAge time.Duration, noStore bool) {
+	noCache := false
+	for _, directive := range strings.Split(header.Get("Cache-Control"), ",") {
+		name, value, _ := strings.Cut(strings.TrimSpace(directive), "=")
+		switch strings.ToLower(name) {
+		case "no-store":
+			noStore = true
+		case "no-cache":
+			noCache = true
+		case "max-age":
+			if seconds, err := strconv.Atoi(strings.Trim(value, `"`)); err == nil {
+				maxAge = time.Duration(seconds) * time.Second
+			}
+		}
+	}
+	if noCache {
+		maxAge = 0
+	}
+	return maxAge, noStore
+}
+
+// isNetworkError tells whether a fetch failed because the host couldn't be
+// reached, rather than because it wasn't allowed
+func isNetworkError(err error) bool {
+	var opErr *net.OpError
+	var dnsErr *net.DNSError
+	return errors.As(err, &opErr) || errors.As(err, &dnsErr)
+}
@@ -0,0 +1,178 @@
+package loader
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func Test_cachingLoader_Load(t *testing.T) {
+
+	tests := []struct {
+		name    string
+		headers map[string]string
+		// how long after the first load the second is made
+		after time.Duration
+		// the server's response to a revalidation, if it's not the source
+		// again
+		revalidated int
+		wantFetches int
+		wantSent    map[string]string
+	}{
+		{
+			name:        "fresh",
+			headers:     map[string]string{"Cache-Control": "public, max-age=60"},
+			after:       time.Second * 30,
+			wantFetches: 1,
+		},
+		{
+			name:        "stale",
+			headers:     map[string]string{"Cache-Control": "max-age=60"},
+			after:       time.Second * 90,
+			wantFetches: 2,
+		},
+		{
+			name:        "age counts against max-age",
+			headers:     map[string]string{"Cache-Control": "max-age=60", "Age": "45"},
+			after:       time.Second * 30,
+			wantFetches: 2,
+		},
+		{
+			name:        "no-store",
+			headers:     map[string]string{"Cache-Control": "no-store, max-age=60"},
+			wantFetches: 2,
+		},
+		{
+			name:        "no-cache revalidates",
+			headers:     map[string]string{"Cache-Control": "no-cache, max-age=60", "ETag": `"v1"`},
+			revalidated: http.StatusNotModified,
+			wantFetches: 2,
+			wantSent:    map[string]string{"If-None-Match": `"v1"`},
+		},
+		{
+			name:        "etag",
+			headers:     map[string]string{"ETag": `"v1"`},
+			revalidated: http.StatusNotModified,
+			wantFetches: 2,
+			wantSent:    map[string]string{"If-None-Match": `"v1"`},
+		},
+		{
+			name:        "last-modified",
+			headers:     map[string]string{"Last-Modified": "Mon, 02 Jan 2006 15:04:05 GMT"},
+			revalidated: http.StatusNotModified,
+			wantFetches: 2,
+			wantSent:    map[string]string{"If-Modified-Since": "Mon, 02 Jan 2006 15:04:05 GMT"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			fetches := 0
+			var sent http.Header
+			server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				fetches++
+				sent = r.Header
+				for name, value := range tt.headers {
+					w.Header().Set(name, value)
+				}
+				if fetches > 1 && tt.revalidated != 0 {
+					w.WriteHeader(tt.revalidated)
+					return
+				}
+				w.Write([]byte("a = person 'a'"))
+			}))
+			defer server.Close()
+
+			now := time.Now()
+			l := NewCachingLoader(NewLoader(AllowRemote(), AllowInsecure()), t.TempDir()).(*cachingLoader)
+			l.now = func() time.Time { return now }
+
+			ctx := context.Background()
+			for i := 0; i < 2; i++ {
+				data, err := l.Load(ctx, server.URL+"/people.c4#fragment")
+				if err != nil {
+					t.Fatalf("cachingLoader.Load() error = %v", err)
+				}
+				if string(data) != "a = person 'a'" {
+					t.Errorf("got source %q", data)
+				}
+				now = now.Add(tt.after)
+			}
+
+			if fetches != tt.wantFetches {
+				t.Errorf("got %d fetches, want %d", fetches, tt.wantFetches)
+			}
+			for name, value := range tt.wantSent {
+				if got := sent.Get(name); got != value {
+					t.Errorf("got %s: %q, want %q", name, got, value)
+				}
+			}
+		})
+	}
+}
+
+func Test_cachingLoader_Offline(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("a = person 'a'"))
+	}))
+	uri := server.URL + "/people.c4"
+	dir := t.TempDir()
+	ctx := context.Background()
+
+	if _, err := NewCachingLoader(NewLoader(AllowRemote(), AllowInsecure()), dir).Load(ctx, uri); err != nil {
+		t.Fatalf("cachingLoader.Load() error = %v", err)
+	}
+	server.Close()
+
+	if _, err := NewCachingLoader(NewLoader(AllowRemote(), AllowInsecure()), dir).Load(ctx, uri); err == nil {
+		t.Errorf("expected an error for a stale source with the server gone")
+	}
+
+	data, err := NewCachingLoader(NewLoader(AllowRemote(), AllowInsecure()), dir, Offline()).Load(ctx, uri)
+	if err != nil {
+		t.Fatalf("expected the stale source offline, got error %v", err)
+	}
+	if string(data) != "a = person 'a'" {
+		t.Errorf("got source %q", data)
+	}
+
+	// being offline doesn't get around what's blocked
+	if _, err := NewCachingLoader(NewLoader(AllowRemote()), dir, Offline()).Load(ctx, uri); err == nil {
+		t.Errorf("expected plaintext http to still be blocked")
+	}
+}
+
+func Test_cachingLoader_Blocked(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Cache-Control", "max-age=3600")
+		w.Write([]byte("a = person 'a'"))
+	}))
+	defer server.Close()
+	uri := server.URL + "/people.c4"
+	dir := t.TempDir()
+	ctx := context.Background()
+
+	if _, err := NewCachingLoader(NewLoader(AllowRemote(), AllowInsecure(), AllowedRemoteHosts("127.0.0.1")), dir).Load(ctx, uri); err != nil {
+		t.Fatalf("cachingLoader.Load() error = %v", err)
+	}
+
+	// what's cached is still only served if it could be fetched
+	tests := []struct {
+		name  string
+		setup []loaderOption
+	}{
+		{name: "host no longer allowed", setup: []loaderOption{AllowRemote(), AllowInsecure(), AllowedRemoteHosts("example.com")}},
+		{name: "remote blocked", setup: []loaderOption{AllowInsecure()}},
+		{name: "plaintext blocked", setup: []loaderOption{AllowRemote()}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			for _, opts := range [][]cacheOption{nil, {Offline()}} {
+				if _, err := NewCachingLoader(NewLoader(tt.setup...), dir, opts...).Load(ctx, uri); err == nil {
+					t.Errorf("expected the cached source to be blocked")
+				}
+			}
+		})
+	}
+}
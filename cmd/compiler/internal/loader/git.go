@@ -0,0 +1,45 @@
+package loader
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io/fs"
+	"net/url"
+	"os/exec"
+	"path"
+	"strings"
+)
+
+// loadGit reads a file as it is at a ref of the git repository the loader
+// is rooted in, from a URI like git:path/to/file.c4@ref. Without a ref, it's
+// read as it is at HEAD.
+func (l *sourceLoader) loadGit(ctx context.Context, uri *url.URL) ([]byte, error) {
+	source, err := pathOf(uri)
+	if err != nil {
+		return nil, fmt.Errorf("invalid URL %s: %w", uri, err)
+	}
+
+	filename, ref := source, "HEAD"
+	if i := strings.LastIndexByte(source, '@'); i >= 0 {
+		filename, ref = source[:i], source[i+1:]
+	}
+	// the path mustn't lead outside of the root, and the ref mustn't be
+	// taken for an option
+	filename = path.Clean(filename)
+	if !fs.ValidPath(filename) || ref == "" || strings.HasPrefix(ref, "-") {
+		return nil, fmt.Errorf("invalid git source %s: expected git:path@ref within %s", uri, l.root())
+	}
+
+	cmd := exec.CommandContext(ctx, "git", "-C", l.root(), "show", ref+":./"+filename)
+	stderr := new(bytes.Buffer)
+	cmd.Stderr = stderr
+	data, err := cmd.Output()
+	if err != nil {
+		if msg := strings.TrimSpace(stderr.String()); msg != "" {
+			return nil, fmt.Errorf("error reading %s at %s from git: %s", filename, ref, msg)
+		}
+		return nil, fmt.Errorf("error reading %s at %s from git: %w", filename, ref, err)
+	}
+	return data, nil
+}
@@ -8,6 +8,7 @@ import (
 	"net/http"
 	"net/url"
 	"os"
+	"path"
 	"strings"
 )
 
@@ -17,6 +18,8 @@ type Loader interface {
 
 type sourceLoader struct {
 	config *sourceLoadConfig
+
+	schemes map[string]SchemeHandler
 }
 
 type sourceLoadConfig struct {
@@ -25,29 +28,113 @@ type sourceLoadConfig struct {
 	BlockRemote        bool
 	ChrootTo           string
 	AuthorizationToken string
+	Lock               *Lock
+	Schemes            map[string]SchemeHandler
 }
 
 var defaultConfig = &sourceLoadConfig{
 	BlockRemote: true,
 }
 
+// SchemeHandler loads the sources named by URIs of the scheme it handles
+type SchemeHandler func(ctx context.Context, uri *url.URL) ([]byte, error)
+
 func (l *sourceLoader) Load(ctx context.Context, uri string) ([]byte, error) {
 
-	if strings.ContainsRune(uri, ':') {
-		return l.loadExternal(ctx, uri)
+	scheme, ok := schemeOf(uri)
+	if !ok {
+		return l.loadFile(uri)
+	}
+
+	handler, has := l.schemes[scheme]
+	if !has {
+		return nil, fmt.Errorf("unable to load %s: no handler for %s: sources", uri, scheme)
+	}
+
+	netUrl, err := url.Parse(uri)
+	if err != nil {
+		return nil, fmt.Errorf("invalid URL %s: %w", uri, err)
+	}
+
+	// whatever the scheme, sources on other hosts are only loaded from
+	// those allowed
+	if netUrl.Host != "" {
+		if err := l.checkHost(uri, netUrl); err != nil {
+			return nil, err
+		}
 	}
-	return l.loadFile(uri)
+
+	return handler(ctx, netUrl)
 }
 
-func (l *sourceLoader) loadFile(filename string) ([]byte, error) {
-	var fs fs.FS
+// schemeOf gives the scheme a URI starts with, unless it's a plain path
+func schemeOf(uri string) (string, bool) {
+	scheme, _, found := strings.Cut(uri, ":")
+	// a single letter is more likely a drive than a scheme
+	if !found || len(scheme) < 2 {
+		return "", false
+	}
+	for i, r := range scheme {
+		switch {
+		case (r >= 'a' && r <= 'z') || (r >= 'A' && r <= 'Z'):
+		case i > 0 && ((r >= '0' && r <= '9') || r == '+' || r == '-' || r == '.'):
+		default:
+			return "", false
+		}
+	}
+	return strings.ToLower(scheme), true
+}
+
+// pathOf gives the path a URI names, whether it's written like file:a/b.c4
+// or file:///a/b.c4. Either way it's relative to the loader's root, which
+// is as far up as any local path can lead.
+func pathOf(uri *url.URL) (string, error) {
+	if uri.Opaque != "" {
+		return url.PathUnescape(uri.Opaque)
+	}
+	return strings.TrimPrefix(uri.Path, "/"), nil
+}
+
+// Canonical gives the one name of a source however its URI is written, so
+// that file:a.c4, file:///a.c4, ./a.c4, and a.c4 can be told to be the same
+func Canonical(uri string) string {
+	scheme, ok := schemeOf(uri)
+	switch {
+	case !ok:
+		return path.Clean(uri)
+	case scheme == "file":
+		u, err := url.Parse(uri)
+		if err != nil {
+			return uri
+		}
+		filename, err := pathOf(u)
+		if err != nil {
+			return uri
+		}
+		return path.Clean(filename)
+	case scheme == "http" || scheme == "https":
+		return withoutFragment(uri)
+	}
+	return uri
+}
+
+func (l *sourceLoader) root() string {
 	if l.config.ChrootTo != "" {
-		fs = os.DirFS(l.config.ChrootTo)
-	} else {
-		fs = os.DirFS(".")
+		return l.config.ChrootTo
 	}
+	return "."
+}
 
-	file, err := fs.Open(filename)
+func (l *sourceLoader) loadFileURI(_ context.Context, uri *url.URL) ([]byte, error) {
+	filename, err := pathOf(uri)
+	if err != nil {
+		return nil, fmt.Errorf("invalid URL %s: %w", uri, err)
+	}
+	return l.loadFile(filename)
+}
+
+func (l *sourceLoader) loadFile(filename string) ([]byte, error) {
+	file, err := os.DirFS(l.root()).Open(filename)
 	if err != nil {
 		return nil, fmt.Errorf("failed to open file %s: %w", filename, err)
 	}
@@ -61,43 +148,79 @@ func (l *sourceLoader) loadFile(filename string) ([]byte, error) {
 	return data, err
 }
 
+func (l *sourceLoader) loadExternalURI(ctx context.Context, uri *url.URL) ([]byte, error) {
+	return l.loadExternal(ctx, uri.String())
+}
+
 func (l *sourceLoader) loadExternal(ctx context.Context, uri string) ([]byte, error) {
 
-	netUrl, err := url.Parse(uri)
+	resp, err := l.fetch(ctx, uri, nil)
 	if err != nil {
-		return nil, fmt.Errorf("invalid URL %s: %w", uri, err)
+		return nil, err
 	}
+	defer resp.Body.Close()
 
-	if l.config.BlockRemote {
-		return nil, fmt.Errorf("unable to load %s: loading external sources blocked", uri)
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("error fetching %s: server returned %s", resp.Request.URL.Redacted(), resp.Status)
+	}
+
+	data, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, fmt.Errorf("connection inturrupted while fetching response: %w", err)
+	}
+
+	if err := l.verify(uri, data); err != nil {
+		return nil, err
+	}
+	return data, nil
+
+}
+
+// verify checks a fetched source against the lock, if there is one
+func (l *sourceLoader) verify(uri string, data []byte) error {
+	if l.config.Lock == nil {
+		return nil
+	}
+	return l.config.Lock.verify(uri, data)
+}
+
+// withoutFragment gives a URL as it's fetched, since fragments never are
+func withoutFragment(uri string) string {
+	u, err := url.Parse(uri)
+	if err != nil {
+		return uri
+	}
+	u.Fragment = ""
+	return u.String()
+}
+
+// fetch makes the request for an external source, with any extra headers
+// given, and leaves the response to the caller
+func (l *sourceLoader) fetch(ctx context.Context, uri string, header http.Header) (*http.Response, error) {
+
+	if err := l.allows(uri); err != nil {
+		return nil, err
+	}
+	netUrl, err := url.Parse(uri)
+	if err != nil {
+		return nil, fmt.Errorf("invalid URL %s: %w", uri, err)
 	}
 
 	client := new(http.Client)
 
 	if len(l.config.AllowedHosts) > 0 {
-		allow := false
-		for _, host := range l.config.AllowedHosts {
-			if strings.EqualFold(netUrl.Hostname(), host) {
-				allow = true
-				break
-			}
-		}
-		if !allow {
-			return nil, fmt.Errorf("unable to load %s: loading source from %s blocked", uri, netUrl.Hostname())
-		}
 		client.CheckRedirect = l.checkRedirect
 	}
 
-	if netUrl.Scheme == "http" && !l.config.AllowInsecure {
-		return nil, fmt.Errorf("unable to load %s: loading over plaintext http blocked", uri)
-	}
-
 	netUrl.Fragment = ""
 
 	req, err := http.NewRequestWithContext(ctx, http.MethodGet, netUrl.String(), http.NoBody)
 	if err != nil {
 		return nil, fmt.Errorf("error creating external request: %s", err)
 	}
+	for name, values := range header {
+		req.Header[name] = values
+	}
 
 	if l.config.AuthorizationToken != "" {
 		req.Header.Add("Authorization", fmt.Sprintf("Bearer %s", l.config.AuthorizationToken))
@@ -105,21 +228,59 @@ func (l *sourceLoader) loadExternal(ctx context.Context, uri string) ([]byte, er
 
 	resp, err := client.Do(req)
 	if err != nil {
-		return nil, fmt.Errorf("error fetching source: %s", err)
+		return nil, fmt.Errorf("error fetching source: %w", err)
 	}
-	defer resp.Body.Close()
+	return resp, nil
+}
 
-	if resp.StatusCode != http.StatusOK {
-		return nil, fmt.Errorf("error fetching %s: server returned %s", resp.Request.URL.Redacted(), resp.Status)
+// fetches tells whether a URI is fetched over http by the loader, rather
+// than loaded some other way
+func (l *sourceLoader) fetches(uri string) bool {
+	scheme, ok := schemeOf(uri)
+	if !ok || (scheme != "http" && scheme != "https") {
+		return false
 	}
+	_, replaced := l.config.Schemes[scheme]
+	return !replaced
+}
 
-	data, err := io.ReadAll(resp.Body)
+// allows makes sure the loader's configuration lets a URL be fetched,
+// whether it really is or it's already cached
+func (l *sourceLoader) allows(uri string) error {
+	netUrl, err := url.Parse(uri)
 	if err != nil {
-		return nil, fmt.Errorf("connection inturrupted while fetching response: %w", err)
+		return fmt.Errorf("invalid URL %s: %w", uri, err)
 	}
 
-	return data, nil
+	if err := l.checkHost(uri, netUrl); err != nil {
+		return err
+	}
+
+	if netUrl.Scheme == "http" && !l.config.AllowInsecure {
+		return fmt.Errorf("unable to load %s: loading over plaintext http blocked", uri)
+	}
+	return nil
+}
 
+// checkHost makes sure sources may be loaded from the host of a URL
+func (l *sourceLoader) checkHost(uri string, netUrl *url.URL) error {
+	if l.config.BlockRemote {
+		return fmt.Errorf("unable to load %s: loading external sources blocked", uri)
+	}
+
+	if len(l.config.AllowedHosts) > 0 {
+		allow := false
+		for _, host := range l.config.AllowedHosts {
+			if strings.EqualFold(netUrl.Hostname(), host) {
+				allow = true
+				break
+			}
+		}
+		if !allow {
+			return fmt.Errorf("unable to load %s: loading source from %s blocked", uri, netUrl.Hostname())
+		}
+	}
+	return nil
 }
 
 func (l *sourceLoader) checkRedirect(req *http.Request, _ []*http.Request) error {
@@ -168,6 +329,43 @@ func SetAuthorizationToken(token string) loaderOption {
 	}
 }
 
+// LockedBy checks every external source fetched against the hashes in the
+// lock, and adds the hashes of those it doesn't have yet
+func LockedBy(lock *Lock) loaderOption {
+	return func(conf *sourceLoadConfig) {
+		conf.Lock = lock
+	}
+}
+
+// HandleScheme loads sources with URIs of the given scheme with the
+// handler, in place of any the loader already has for it. Sources with a
+// host are still only loaded from those allowed.
+func HandleScheme(scheme string, handler SchemeHandler) loaderOption {
+	return func(conf *sourceLoadConfig) {
+		if conf.Schemes == nil {
+			conf.Schemes = make(map[string]SchemeHandler)
+		}
+		conf.Schemes[strings.ToLower(scheme)] = handler
+	}
+}
+
+// Embedded loads embed: sources, like embed:people.c4, from the given
+// filesystem, such as one embedded in a program using the compiler. Nothing
+// outside of it can be read.
+func Embedded(fsys fs.FS) loaderOption {
+	return HandleScheme("embed", func(_ context.Context, uri *url.URL) ([]byte, error) {
+		filename, err := pathOf(uri)
+		if err != nil {
+			return nil, fmt.Errorf("invalid URL %s: %w", uri, err)
+		}
+		data, err := fs.ReadFile(fsys, filename)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read embedded file %s: %w", filename, err)
+		}
+		return data, nil
+	})
+}
+
 func NewLoader(opts ...loaderOption) Loader {
 	l := new(sourceLoader)
 	conf := *defaultConfig
@@ -175,5 +373,15 @@ func NewLoader(opts ...loaderOption) Loader {
 	for i := range opts {
 		opts[i](l.config)
 	}
+
+	l.schemes = map[string]SchemeHandler{
+		"file":  l.loadFileURI,
+		"http":  l.loadExternalURI,
+		"https": l.loadExternalURI,
+		"git":   l.loadGit,
+	}
+	for scheme, handler := range l.config.Schemes {
+		l.schemes[scheme] = handler
+	}
 	return l
 }
@@ -2,9 +2,18 @@ package loader
 
 import (
 	"context"
+	"net/url"
+	"os"
+	"os/exec"
+	"path/filepath"
 	"testing"
+	"testing/fstest"
 )
 
+func loadNothing(context.Context, *url.URL) ([]byte, error) {
+	return nil, nil
+}
+
 func Test_loader_Load(t *testing.T) {
 
 	tests := []struct {
@@ -35,6 +44,64 @@ func Test_loader_Load(t *testing.T) {
 			setup:   []loaderOption{RootedAt("testdata")},
 			wantErr: true,
 		},
+		{
+			name:    "file scheme",
+			uri:     "file:testdata/main.c4",
+			wantErr: false,
+		},
+		{
+			name:    "file scheme with an empty authority",
+			uri:     "file:///main.c4",
+			setup:   []loaderOption{RootedAt("testdata")},
+			wantErr: false,
+		},
+		{
+			name:    "file scheme with an empty authority blocks outside",
+			uri:     "file:///../loader.go",
+			setup:   []loaderOption{RootedAt("testdata")},
+			wantErr: true,
+		},
+		{
+			name:    "chrooted file scheme blocks outside",
+			uri:     "file:../loader.go",
+			setup:   []loaderOption{RootedAt("testdata")},
+			wantErr: true,
+		},
+		{
+			name:    "embedded load",
+			uri:     "embed:people.c4",
+			setup:   []loaderOption{Embedded(fstest.MapFS{"people.c4": {Data: []byte("u = person 'user'")}})},
+			wantErr: false,
+		},
+		{
+			name:    "embedded load blocks outside",
+			uri:     "embed:../people.c4",
+			setup:   []loaderOption{Embedded(fstest.MapFS{"people.c4": {Data: []byte("u = person 'user'")}})},
+			wantErr: true,
+		},
+		{
+			name:    "unknown scheme",
+			uri:     "s3://bucket/people.c4",
+			wantErr: true,
+		},
+		{
+			name:    "custom scheme",
+			uri:     "s3://bucket/people.c4",
+			setup:   []loaderOption{AllowRemote(), HandleScheme("s3", loadNothing)},
+			wantErr: false,
+		},
+		{
+			name:    "custom scheme still blocks remote load",
+			uri:     "s3://bucket/people.c4",
+			setup:   []loaderOption{HandleScheme("s3", loadNothing)},
+			wantErr: true,
+		},
+		{
+			name:    "custom scheme still has allow list",
+			uri:     "s3://bucket/people.c4",
+			setup:   []loaderOption{AllowRemote(), AllowedRemoteHosts("example.com"), HandleScheme("s3", loadNothing)},
+			wantErr: true,
+		},
 		{
 			name:    "block remote load",
 			uri:     "https://example.com/resources/foo.c4",
@@ -84,3 +151,81 @@ func Test_loader_Load(t *testing.T) {
 		})
 	}
 }
+
+func Test_loader_LoadGit(t *testing.T) {
+	if _, err := exec.LookPath("git"); err != nil {
+		t.Skip("git isn't installed")
+	}
+
+	repo := t.TempDir()
+	git := func(args ...string) {
+		t.Helper()
+		cmd := exec.Command("git", append([]string{"-C", repo, "-c", "user.name=c4", "-c", "user.email=c4@example.com"}, args...)...)
+		if out, err := cmd.CombinedOutput(); err != nil {
+			t.Fatalf("git %v: %s\n%s", args, err, out)
+		}
+	}
+	write := func(data string) {
+		t.Helper()
+		if err := os.MkdirAll(filepath.Join(repo, "common"), 0o755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(filepath.Join(repo, "common", "people.c4"), []byte(data), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	git("init", "-q")
+	write("u = person 'v1'")
+	git("add", ".")
+	git("commit", "-q", "-m", "v1")
+	git("tag", "v1")
+	write("u = person 'v2'")
+	git("commit", "-q", "-a", "-m", "v2")
+
+	tests := []struct {
+		uri     string
+		root    string
+		want    string
+		wantErr bool
+	}{
+		{uri: "git:common/people.c4@v1", root: repo, want: "u = person 'v1'"},
+		{uri: "git:common/people.c4", root: repo, want: "u = person 'v2'"},
+		{uri: "git:people.c4@v1", root: filepath.Join(repo, "common"), want: "u = person 'v1'"},
+		{uri: "git:../common/people.c4@v1", root: filepath.Join(repo, "common"), wantErr: true},
+		{uri: "git:common/people.c4@--output=x", root: repo, wantErr: true},
+		{uri: "git:common/missing.c4@v1", root: repo, wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.uri, func(t *testing.T) {
+			got, err := NewLoader(RootedAt(tt.root)).Load(context.Background(), tt.uri)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("loader.Load() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if string(got) != tt.want {
+				t.Errorf("got source %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestCanonical(t *testing.T) {
+	tests := []struct {
+		uri  string
+		want string
+	}{
+		{uri: "a/b.c4", want: "a/b.c4"},
+		{uri: "./a/../a/b.c4", want: "a/b.c4"},
+		{uri: "file:a/b.c4", want: "a/b.c4"},
+		{uri: "file:///a/b.c4", want: "a/b.c4"},
+		{uri: "https://example.com/a.c4#frag", want: "https://example.com/a.c4"},
+		{uri: "git:a/b.c4@v1", want: "git:a/b.c4@v1"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.uri, func(t *testing.T) {
+			if got := Canonical(tt.uri); got != tt.want {
+				t.Errorf("Canonical(%q) = %q, want %q", tt.uri, got, tt.want)
+			}
+		})
+	}
+}
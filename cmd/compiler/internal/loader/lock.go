@@ -0,0 +1,110 @@
+package loader
+
+import (
+	"bufio"
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// LockFileName is the name of the lock file kept next to a target
+const LockFileName = "c4.lock"
+
+const hashPrefix = "sha256:"
+
+// Lock is the hash of every external source a target fetches, so that a
+// source changing upstream can't silently change what's compiled
+type Lock struct {
+	path string
+
+	mu      sync.Mutex
+	hashes  map[string]string
+	changed bool
+}
+
+// ReadLock reads the lock file at path, or starts an empty one if there
+// isn't one yet
+func ReadLock(path string) (*Lock, error) {
+	lock := &Lock{path: path, hashes: make(map[string]string)}
+
+	data, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return lock, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("error reading lock file %s: %w", path, err)
+	}
+
+	scanner := bufio.NewScanner(bytes.NewReader(data))
+	for line := 1; scanner.Scan(); line++ {
+		fields := strings.Fields(scanner.Text())
+		if len(fields) == 0 {
+			continue
+		}
+		if len(fields) != 2 || !strings.HasPrefix(fields[1], hashPrefix) {
+			return nil, fmt.Errorf("error reading lock file %s: line %d isn't a URL and its %s hash", path, line, hashPrefix)
+		}
+		lock.hashes[fields[0]] = fields[1]
+	}
+	return lock, nil
+}
+
+// NewLock starts a lock to replace whatever is at path, for finding the
+// hashes of every source afresh
+func NewLock(path string) *Lock {
+	return &Lock{path: path, hashes: make(map[string]string), changed: true}
+}
+
+// verify checks a fetched source against its hash, or records the hash if
+// the source is new to the lock
+func (l *Lock) verify(uri string, data []byte) error {
+	uri = withoutFragment(uri)
+	sum := sha256.Sum256(data)
+	hash := hashPrefix + hex.EncodeToString(sum[:])
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	locked, has := l.hashes[uri]
+	if !has {
+		l.hashes[uri] = hash
+		l.changed = true
+		return nil
+	}
+	if locked != hash {
+		return fmt.Errorf("unable to load %s: it has changed since it was locked in %s, run the `lock update` command if the change is expected", uri, l.path)
+	}
+	return nil
+}
+
+// Save writes the lock file, if anything was added to it
+func (l *Lock) Save() error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if !l.changed {
+		return nil
+	}
+
+	uris := make([]string, 0, len(l.hashes))
+	for uri := range l.hashes {
+		uris = append(uris, uri)
+	}
+	sort.Strings(uris)
+
+	out := new(bytes.Buffer)
+	for _, uri := range uris {
+		fmt.Fprintf(out, "%s %s\n", uri, l.hashes[uri])
+	}
+	if err := os.WriteFile(l.path, out.Bytes(), 0o644); err != nil {
+		return fmt.Errorf("error writing lock file %s: %w", l.path, err)
+	}
+	l.changed = false
+	return nil
+}
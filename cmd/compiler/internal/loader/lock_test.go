@@ -0,0 +1,73 @@
+package loader
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestLock(t *testing.T) {
+	source := "a = person 'a'"
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Cache-Control", "max-age=60")
+		w.Write([]byte(source))
+	}))
+	defer server.Close()
+
+	path := filepath.Join(t.TempDir(), LockFileName)
+	ctx := context.Background()
+	load := func(lock *Lock, cacheDir string) error {
+		var l Loader = NewLoader(AllowRemote(), AllowInsecure(), LockedBy(lock))
+		if cacheDir != "" {
+			l = NewCachingLoader(l, cacheDir)
+		}
+		_, err := l.Load(ctx, server.URL+"/people.c4#a")
+		return err
+	}
+
+	lock, err := ReadLock(path)
+	if err != nil {
+		t.Fatalf("ReadLock() error = %v", err)
+	}
+	if err := load(lock, ""); err != nil {
+		t.Fatalf("unexpected error loading before the source is locked: %v", err)
+	}
+	if err := lock.Save(); err != nil {
+		t.Fatalf("Lock.Save() error = %v", err)
+	}
+	written, _ := os.ReadFile(path)
+	want := server.URL + "/people.c4 sha256:4a606f0f9ef53525c262b73674536944f1ebebe2b22185fd245be04068c128f6\n"
+	if string(written) != want {
+		t.Errorf("got lock file %q, want %q", written, want)
+	}
+
+	// a source that's changed is refused
+	source = "a = person 'b'"
+	if lock, err = ReadLock(path); err != nil {
+		t.Fatalf("ReadLock() error = %v", err)
+	}
+	if err := load(lock, ""); err == nil {
+		t.Errorf("expected an error loading a source that's changed since it was locked")
+	}
+
+	// and so is a cached one that's older than the lock
+	cacheDir := t.TempDir()
+	if err := load(NewLock(path), cacheDir); err != nil {
+		t.Fatalf("unexpected error caching the source: %v", err)
+	}
+	source = "a = person 'c'"
+	lock = NewLock(path)
+	if err := load(lock, ""); err != nil {
+		t.Fatalf("unexpected error updating the lock: %v", err)
+	}
+	lock.Save()
+	if lock, err = ReadLock(path); err != nil {
+		t.Fatalf("ReadLock() error = %v", err)
+	}
+	if err := load(lock, cacheDir); err == nil {
+		t.Errorf("expected an error loading a cached source that's changed since it was locked")
+	}
+}
@@ -0,0 +1,385 @@
+package lsp
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+
+	"go.burian.dev/c4/cmd/compiler/internal/checker"
+	"go.burian.dev/c4/cmd/compiler/internal/lexer"
+	"go.burian.dev/c4/cmd/compiler/internal/loader"
+	"go.burian.dev/c4/cmd/compiler/internal/parser"
+)
+
+// analysis is what's known of a document after compiling it, and of
+// anything it includes or extends
+type analysis struct {
+	target string
+
+	documents map[string][]byte
+	loader    loader.Loader
+	context   context.Context
+
+	sources map[string][]byte
+	tokens  map[string][]*lexer.Token
+	order   []string
+	parser  *parser.Parser
+
+	// whether the target was a workspace to compile, or only lexed
+	compiled bool
+	err      error
+
+	// every entity by its identifier, and by where the keyword declaring it
+	// starts
+	index    map[parser.IdentifierString]parser.Entity
+	declared map[lexer.Position]parser.Entity
+
+	references []*reference
+}
+
+// reference is an identifier in the source that names an entity
+type reference struct {
+	token       *lexer.Token
+	entity      parser.Entity
+	declaration bool
+}
+
+type codeError interface {
+	TokenAtError() *lexer.Token
+}
+
+// analyse compiles a workspace, or only lexes anything else, like a
+// fragment for including
+func analyse(ctx context.Context, target string, documents map[string][]byte, load loader.Loader) *analysis {
+	a := &analysis{
+		target:    target,
+		documents: documents,
+		loader:    load,
+		context:   ctx,
+		sources:   make(map[string][]byte),
+		tokens:    make(map[string][]*lexer.Token),
+		parser:    new(parser.Parser),
+		index:     make(map[parser.IdentifierString]parser.Entity),
+		declared:  make(map[lexer.Position]parser.Entity),
+	}
+
+	if _, err := a.GetTokenStreamFor(target); err != nil {
+		a.err = err
+		return a
+	}
+	if first := firstKeyword(a.sources[target], a.tokens[target]); first != parser.KeywordWorkspace {
+		return a
+	}
+
+	a.compiled = true
+	w := a.compile()
+	if w != nil && w.Model != nil {
+		for _, e := range w.Model.Children() {
+			a.indexEntity(e)
+		}
+	}
+	a.resolveReferences()
+	return a
+}
+
+func (a *analysis) compile() (w *parser.Workspace) {
+	// the parser panics on some internal errors, which mustn't stop the
+	// server
+	defer func() {
+		if r := recover(); r != nil {
+			a.err = fmt.Errorf("%v", r)
+		}
+	}()
+
+	w, a.err = a.parser.Run(a.target, a)
+	if a.err != nil {
+		return w
+	}
+	a.err = new(checker.Checker).CheckWorkspaces([]*parser.Workspace{w}, a)
+	return w
+}
+
+func (a *analysis) indexEntity(e parser.Entity) {
+	a.index[e.Id()] = e
+	if tok := a.parser.DeclarationOf(e); tok != nil {
+		a.declared[tok.Positions().Start] = e
+	}
+	for _, child := range e.Base().Children() {
+		a.indexEntity(child)
+	}
+}
+
+func firstKeyword(src []byte, tokens []*lexer.Token) parser.Keyword {
+	for _, tok := range tokens {
+		if tok.Is(lexer.TypeTerminator) {
+			continue
+		}
+		if tok.Is(lexer.TypeKeyword) {
+			return parser.Keyword(strings.ToLower(string(tok.BytesAt(src))))
+		}
+		break
+	}
+	return ""
+}
+
+func (a *analysis) GetSourceFor(name string) (*bytes.Reader, error) {
+	if source, has := a.sources[name]; has {
+		return bytes.NewReader(source), nil
+	}
+
+	source, open := a.documents[name]
+	if !open {
+		var err error
+		if source, err = a.loader.Load(a.context, name); err != nil {
+			return nil, fmt.Errorf("language server could not provide source: %w", err)
+		}
+	}
+	a.sources[name] = source
+	a.order = append(a.order, name)
+	return bytes.NewReader(source), nil
+}
+
+func (a *analysis) GetTokenStreamFor(name string) (lexer.TokenStream, error) {
+	lexed, err := new(lexer.Lexer).Run(name, a)
+	if lexed == nil {
+		return nil, err
+	}
+
+	// the tokens of a source with errors are still good for completion
+	if _, has := a.tokens[name]; !has {
+		stream := lexed.TokenStream()
+		for {
+			tok := stream.NextToken()
+			if tok == nil {
+				break
+			}
+			a.tokens[name] = append(a.tokens[name], tok)
+			if tok.Is(lexer.TypeEOF) {
+				break
+			}
+		}
+	}
+	if err != nil {
+		return nil, err
+	}
+	return lexed.TokenStream(), nil
+}
+
+func (a *analysis) GetWorkspaceFor(name string) (*parser.Workspace, error) {
+	return a.parser.Run(name, a)
+}
+
+func (a *analysis) GetThemeFor(name string) (*parser.Styles, error) {
+	return a.parser.RunTheme(name, a)
+}
+
+func (a *analysis) DeclarationOf(x any) *lexer.Token {
+	return a.parser.DeclarationOf(x)
+}
+
+// diagnostics gives the problems found in each file of the analysis,
+// including an empty list for files without any
+func (a *analysis) diagnostics() map[string][]Diagnostic {
+	diags := make(map[string][]Diagnostic)
+	for _, name := range a.order {
+		diags[name] = []Diagnostic{}
+	}
+	for _, w := range a.parser.Warnings() {
+		name, d := a.diagnosticOf(w)
+		d.Severity = severityWarning
+		diags[name] = append(diags[name], d)
+	}
+	if a.err == nil {
+		return diags
+	}
+
+	// the lexer and the parser carry on past errors, so there may be many
+	errs := []error{a.err}
+	var list interface{ Unwrap() []error }
+	if errors.As(a.err, &list) {
+		errs = list.Unwrap()
+	}
+	for _, err := range errs {
+		name, d := a.diagnosticOf(err)
+		diags[name] = append(diags[name], d)
+	}
+	return diags
+}
+
+// diagnosticOf gives the diagnostic for an error, and the file it's in
+func (a *analysis) diagnosticOf(err error) (string, Diagnostic) {
+	name := a.target
+	var rng Range
+	message := err.Error()
+
+	var ce codeError
+	if errors.As(err, &ce) {
+		tok := ce.TokenAtError()
+		name = tok.Positions().Start.File
+		rng = a.rangeOf(tok)
+
+		// the position is already given by the diagnostic itself
+		var code *parser.CodeError
+		if errors.As(err, &code) && code.Unwrap() != nil {
+			message = code.Unwrap().Error()
+		} else if ee := new(parser.ExpectationError); errors.As(err, &ee) {
+			message = ee.Error()
+		}
+	}
+
+	return name, Diagnostic{
+		Range:    rng,
+		Severity: severityError,
+		Source:   "c4",
+		Message:  message,
+	}
+}
+
+func (a *analysis) rangeOf(tok *lexer.Token) Range {
+	src := a.sources[tok.Positions().Start.File]
+	pos := tok.Positions()
+	return Range{
+		Start: positionOf(src, pos.Start.ByteOffset),
+		End:   positionOf(src, pos.End.ByteOffset),
+	}
+}
+
+// resolveReferences finds the entity named by every identifier in the
+// sources, looking identifiers up from the block they're in, as the checker
+// does
+func (a *analysis) resolveReferences() {
+	for _, name := range a.order {
+		src := a.sources[name]
+		tokens := a.tokens[name]
+
+		var b blocks
+		for i, tok := range tokens {
+			b.next(src, tok)
+			if !tok.Is(lexer.TypeIdentifier) || b.inStyles() || b.directive ||
+				b.statement == parser.KeywordAutoLayout {
+				continue
+			}
+
+			// a = person ...
+			if i+2 < len(tokens) && tokens[i+1].Is(lexer.TypeAssignment) {
+				if e, has := a.declared[tokens[i+2].Positions().Start]; has {
+					a.references = append(a.references, &reference{token: tok, entity: e, declaration: true})
+				}
+				continue
+			}
+
+			if e, found := a.lookup(b.scope(a), parser.IdentifierString(tok.BytesAt(src))); found {
+				a.references = append(a.references, &reference{token: tok, entity: e})
+			}
+		}
+	}
+}
+
+func (a *analysis) lookup(scope parser.Entity, id parser.IdentifierString) (parser.Entity, bool) {
+	for s := scope; s != nil; s = s.Parent() {
+		if e, has := a.index[s.Id()+"."+id]; has {
+			return e, true
+		}
+	}
+	e, has := a.index[id]
+	return e, has
+}
+
+// referenceAt gives the reference at an offset in a file
+func (a *analysis) referenceAt(name string, offset int) *reference {
+	for _, ref := range a.references {
+		pos := ref.token.Positions()
+		if pos.Start.File == name && pos.Start.ByteOffset <= offset && offset <= pos.End.ByteOffset {
+			return ref
+		}
+	}
+	return nil
+}
+
+// blocks follows the blocks that tokens are in, and the statement being
+// read in the innermost of them
+type blocks struct {
+	// the keyword opening each block, and the token declaring it
+	keywords     []parser.Keyword
+	declarations []*lexer.Token
+
+	// the first keyword of the statement, and the last that could declare
+	// an entity
+	statement   parser.Keyword
+	declaration *lexer.Token
+	// the statement so far
+	words int
+	last  lexer.TokenType
+
+	// the statement is a directive
+	directive bool
+}
+
+func (b *blocks) next(src []byte, tok *lexer.Token) {
+	b.last = tok.Type()
+
+	switch tok.Type() {
+	case lexer.TypeStartBlock:
+		b.keywords = append(b.keywords, b.statement)
+		b.declarations = append(b.declarations, b.declaration)
+		b.end()
+	case lexer.TypeEndBlock:
+		if len(b.keywords) > 0 {
+			b.keywords = b.keywords[:len(b.keywords)-1]
+			b.declarations = b.declarations[:len(b.declarations)-1]
+		}
+		b.end()
+	case lexer.TypeTerminator:
+		b.end()
+	case lexer.TypeDirective:
+		b.directive = true
+		b.words++
+	case lexer.TypeKeyword:
+		if b.statement == "" {
+			b.statement = parser.Keyword(strings.ToLower(string(tok.BytesAt(src))))
+		}
+		b.declaration = tok
+		b.words++
+	default:
+		b.words++
+	}
+}
+
+func (b *blocks) end() {
+	b.statement = ""
+	b.declaration = nil
+	b.words = 0
+	b.directive = false
+}
+
+// block gives the keyword opening the innermost block
+func (b *blocks) block() parser.Keyword {
+	if len(b.keywords) == 0 {
+		return ""
+	}
+	return b.keywords[len(b.keywords)-1]
+}
+
+func (b *blocks) inStyles() bool {
+	for _, k := range b.keywords {
+		if k == parser.KeywordStyles {
+			return true
+		}
+	}
+	return false
+}
+
+// scope gives the innermost entity whose body the blocks are in
+func (b *blocks) scope(a *analysis) parser.Entity {
+	for i := len(b.declarations) - 1; i >= 0; i-- {
+		if b.declarations[i] == nil {
+			continue
+		}
+		if e, has := a.declared[b.declarations[i].Positions().Start]; has {
+			return e
+		}
+	}
+	return nil
+}
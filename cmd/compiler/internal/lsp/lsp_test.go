@@ -0,0 +1,230 @@
+package lsp
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+const bank = `workspace 'Bank' {
+    model {
+        customer = person 'Customer'
+        bank = softwareSystem 'Internet Banking' {
+            web = container 'Web' 'Serves the site' 'Java'
+            customer -> web 'Uses'
+        }
+        customer -> bank
+    }
+    views {
+        container bank {
+            include *
+        }
+    }
+}
+`
+
+// session is a scripted conversation with a server
+type session struct {
+	t   *testing.T
+	in  bytes.Buffer
+	ids int
+}
+
+func (s *session) send(method string, params any) {
+	s.t.Helper()
+	msg := &message{Method: method}
+	if params != nil {
+		var err error
+		if msg.Params, err = json.Marshal(params); err != nil {
+			s.t.Fatal(err)
+		}
+	}
+	if !strings.HasPrefix(method, "textDocument/did") && method != "exit" {
+		s.ids++
+		id := json.RawMessage(fmt.Sprint(s.ids))
+		msg.ID = &id
+	}
+	if err := writeMessage(&s.in, msg); err != nil {
+		s.t.Fatal(err)
+	}
+}
+
+// run serves the conversation, and gives the responses by request and the
+// notifications in order
+func (s *session) run() (map[string]json.RawMessage, []*message) {
+	s.t.Helper()
+	out := new(bytes.Buffer)
+	if err := Serve(&s.in, out); err != nil {
+		s.t.Fatalf("unexpected error serving: %s", err)
+	}
+
+	responses := make(map[string]json.RawMessage)
+	var notifications []*message
+	r := bufio.NewReader(out)
+	for {
+		msg, err := readMessage(r)
+		if err != nil {
+			break
+		}
+		if msg.ID == nil {
+			notifications = append(notifications, msg)
+			continue
+		}
+		if msg.Error != nil {
+			s.t.Errorf("request %s failed: %s", *msg.ID, msg.Error)
+		}
+		responses[string(*msg.ID)] = msg.Result
+	}
+	return responses, notifications
+}
+
+func at(line, character int) map[string]any {
+	return map[string]any{
+		"textDocument": map[string]any{"uri": "file:///project/main.c4"},
+		"position":     Position{Line: line, Character: character},
+		"context":      map[string]any{"includeDeclaration": true},
+	}
+}
+
+func open(text string) map[string]any {
+	return map[string]any{"textDocument": map[string]any{"uri": "file:///project/main.c4", "text": text}}
+}
+
+func TestServe(t *testing.T) {
+	s := &session{t: t}
+	s.send("initialize", map[string]any{"rootUri": "file:///project"})
+	s.send("textDocument/didOpen", open(bank))
+	s.send("textDocument/definition", at(7, 22))  // 2: bank in customer -> bank
+	s.send("textDocument/references", at(2, 10))  // 3: customer
+	s.send("textDocument/hover", at(5, 25))       // 4: web
+	s.send("textDocument/completion", at(4, 12))  // 5: in the container
+	s.send("textDocument/completion", at(2, 28))  // 6: after the person
+	s.send("textDocument/definition", at(10, 22)) // 7: bank in the view
+	s.send("shutdown", nil)
+	s.send("exit", nil)
+	responses, notifications := s.run()
+
+	if len(notifications) != 1 || notifications[0].Method != "textDocument/publishDiagnostics" {
+		t.Fatalf("expected diagnostics for the document, got %v", notifications)
+	}
+	var diags publishDiagnosticsParams
+	json.Unmarshal(notifications[0].Params, &diags)
+	if diags.URI != "file:///project/main.c4" || len(diags.Diagnostics) != 0 {
+		t.Errorf("expected no diagnostics, got %+v", diags)
+	}
+
+	var location Location
+	json.Unmarshal(responses["2"], &location)
+	if want := (Range{Start: Position{3, 8}, End: Position{3, 12}}); location.Range != want {
+		t.Errorf("got definition at %+v, want %+v", location.Range, want)
+	}
+	json.Unmarshal(responses["7"], &location)
+	if want := (Range{Start: Position{3, 8}, End: Position{3, 12}}); location.Range != want {
+		t.Errorf("got definition at %+v, want %+v", location.Range, want)
+	}
+
+	var locations []Location
+	json.Unmarshal(responses["3"], &locations)
+	var lines []int
+	for _, l := range locations {
+		lines = append(lines, l.Range.Start.Line)
+	}
+	if want := []int{2, 5, 7}; !reflect.DeepEqual(lines, want) {
+		t.Errorf("got references on lines %v, want %v", lines, want)
+	}
+
+	var h hover
+	json.Unmarshal(responses["4"], &h)
+	if want := "**Web** (container `bank.web`)\n\nServes the site\n\nTechnology: Java"; h.Contents.Value != want {
+		t.Errorf("got hover %q, want %q", h.Contents.Value, want)
+	}
+
+	var items []CompletionItem
+	json.Unmarshal(responses["5"], &items)
+	var labels []string
+	for _, item := range items {
+		labels = append(labels, item.Label)
+	}
+	if want := []string{"container", "description", "tags", "url", "properties", "perspectives", "this", "group"}; !reflect.DeepEqual(labels, want) {
+		t.Errorf("got completions %v, want %v", labels, want)
+	}
+
+	json.Unmarshal(responses["6"], &items)
+	if len(items) != 0 {
+		t.Errorf("expected no completions within a statement, got %v", items)
+	}
+}
+
+func TestServeDiagnostics(t *testing.T) {
+	s := &session{t: t}
+	s.send("initialize", map[string]any{"rootUri": "file:///project"})
+	s.send("textDocument/didOpen", open(strings.Replace(bank, "customer -> bank", "customer -> bnak", 1)))
+	s.send("textDocument/didChange", map[string]any{
+		"textDocument":   map[string]any{"uri": "file:///project/main.c4"},
+		"contentChanges": []map[string]any{{"text": strings.NewReplacer("= person", "= persn", "include *", "include").Replace(bank)}},
+	})
+	s.send("shutdown", nil)
+	s.send("exit", nil)
+	_, notifications := s.run()
+
+	if len(notifications) != 2 {
+		t.Fatalf("expected diagnostics after each change, got %d", len(notifications))
+	}
+
+	var diags publishDiagnosticsParams
+	json.Unmarshal(notifications[0].Params, &diags)
+	if len(diags.Diagnostics) != 1 {
+		t.Fatalf("expected a diagnostic for the unknown identifier, got %+v", diags.Diagnostics)
+	}
+	if d := diags.Diagnostics[0]; d.Range.Start.Line != 7 || !strings.Contains(d.Message, "unknown identifier bnak") {
+		t.Errorf("got diagnostic %+v", d)
+	}
+
+	// parsing carries on past the first error
+	json.Unmarshal(notifications[1].Params, &diags)
+	var starts []Position
+	for _, d := range diags.Diagnostics {
+		starts = append(starts, d.Range.Start)
+	}
+	if want := []Position{{2, 25}, {11, 19}}; !reflect.DeepEqual(starts, want) {
+		t.Errorf("got diagnostics at %v, want %v: %+v", starts, want, diags.Diagnostics)
+	}
+}
+
+func TestPositions(t *testing.T) {
+	src := []byte("a\né\U0001F600b\n")
+	for offset, want := range map[int]Position{0: {0, 0}, 2: {1, 0}, 4: {1, 1}, 8: {1, 3}, 10: {2, 0}} {
+		if got := positionOf(src, offset); got != want {
+			t.Errorf("got position %+v of offset %d, want %+v", got, offset, want)
+		}
+		if got := offsetOf(src, want); got != offset {
+			t.Errorf("got offset %d of position %+v, want %d", got, want, offset)
+		}
+	}
+}
+
+func TestReadMessageLength(t *testing.T) {
+	tests := []struct {
+		name    string
+		input   string
+		wantErr bool
+	}{
+		{name: "valid", input: "Content-Length: 2\r\n\r\n{}"},
+		{name: "missing", input: "\r\n{}", wantErr: true},
+		{name: "negative", input: "Content-Length: -1\r\n\r\n{}", wantErr: true},
+		{name: "too long", input: fmt.Sprintf("Content-Length: %d\r\n\r\n{}", maxMessageLength+1), wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := readMessage(bufio.NewReader(strings.NewReader(tt.input)))
+			if (err != nil) != tt.wantErr {
+				t.Errorf("readMessage() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
@@ -0,0 +1,391 @@
+package lsp
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/url"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"go.burian.dev/c4/cmd/compiler/internal/lexer"
+	"go.burian.dev/c4/cmd/compiler/internal/loader"
+	"go.burian.dev/c4/cmd/compiler/internal/parser"
+)
+
+// Server answers a single client's requests about the documents it has open
+type Server struct {
+	// the directory that names of sources are relative to, and that
+	// anything not open is loaded from
+	root   string
+	loader loader.Loader
+
+	out io.Writer
+
+	// the text of every open document, by name
+	documents map[string][]byte
+	// the latest analysis of every open document
+	analyses map[string]*analysis
+
+	shutdown bool
+}
+
+// Serve speaks the protocol over a client's input and output until the
+// client asks the server to exit
+func Serve(in io.Reader, out io.Writer) error {
+	s := &Server{
+		out:       out,
+		documents: make(map[string][]byte),
+		analyses:  make(map[string]*analysis),
+	}
+
+	r := bufio.NewReader(in)
+	for {
+		msg, err := readMessage(r)
+		if err != nil {
+			if errors.Is(err, io.EOF) {
+				return nil
+			}
+			return fmt.Errorf("error reading message:\n> %w", err)
+		}
+
+		if msg.Method == "exit" {
+			if !s.shutdown {
+				return fmt.Errorf("client exited without shutting down")
+			}
+			return nil
+		}
+
+		if err := s.handle(msg); err != nil {
+			return err
+		}
+	}
+}
+
+func (s *Server) handle(msg *message) error {
+	result, err := s.dispatch(msg)
+	var re *responseError
+	if err != nil && !errors.As(err, &re) {
+		return err
+	}
+
+	// notifications have no response
+	if msg.ID == nil {
+		return nil
+	}
+
+	resp := &message{ID: msg.ID}
+	if re != nil {
+		resp.Error = re
+	} else {
+		if resp.Result, err = json.Marshal(result); err != nil {
+			return err
+		}
+	}
+	return writeMessage(s.out, resp)
+}
+
+func (s *Server) dispatch(msg *message) (any, error) {
+	switch msg.Method {
+	case "initialize":
+		var params initializeParams
+		if err := unmarshalParams(msg, &params); err != nil {
+			return nil, err
+		}
+		return s.initialize(params), nil
+
+	case "shutdown":
+		s.shutdown = true
+		return nil, nil
+
+	case "textDocument/didOpen":
+		var params didOpenParams
+		if err := unmarshalParams(msg, &params); err != nil {
+			return nil, err
+		}
+		s.documents[s.nameOf(params.TextDocument.URI)] = []byte(params.TextDocument.Text)
+		return nil, s.refresh()
+
+	case "textDocument/didChange":
+		var params didChangeParams
+		if err := unmarshalParams(msg, &params); err != nil {
+			return nil, err
+		}
+		// only whole documents are synchronised
+		if n := len(params.ContentChanges); n > 0 {
+			s.documents[s.nameOf(params.TextDocument.URI)] = []byte(params.ContentChanges[n-1].Text)
+		}
+		return nil, s.refresh()
+
+	case "textDocument/didClose":
+		var params didCloseParams
+		if err := unmarshalParams(msg, &params); err != nil {
+			return nil, err
+		}
+		name := s.nameOf(params.TextDocument.URI)
+		delete(s.documents, name)
+		delete(s.analyses, name)
+		if err := s.publish(name, []Diagnostic{}); err != nil {
+			return nil, err
+		}
+		return nil, s.refresh()
+
+	case "textDocument/definition":
+		return s.positionRequest(msg, s.definition)
+
+	case "textDocument/references":
+		return s.positionRequest(msg, s.references)
+
+	case "textDocument/hover":
+		return s.positionRequest(msg, s.hover)
+
+	case "textDocument/completion":
+		return s.positionRequest(msg, s.completion)
+	}
+
+	return nil, &responseError{Code: codeMethodNotFound, Message: fmt.Sprintf("method %s not supported", msg.Method)}
+}
+
+func unmarshalParams(msg *message, params any) error {
+	if err := json.Unmarshal(msg.Params, params); err != nil {
+		return &responseError{Code: codeInvalidParams, Message: err.Error()}
+	}
+	return nil
+}
+
+func (s *Server) initialize(params initializeParams) any {
+	s.root = params.RootPath
+	if u, err := url.Parse(params.RootURI); err == nil && u.Scheme == "file" {
+		s.root = u.Path
+	}
+	s.loader = loader.NewLoader(loader.RootedAt(s.root))
+
+	return map[string]any{
+		"capabilities": map[string]any{
+			"textDocumentSync":   syncFull,
+			"definitionProvider": true,
+			"referencesProvider": true,
+			"hoverProvider":      true,
+			"completionProvider": map[string]any{},
+		},
+		"serverInfo": map[string]any{
+			"name": "c4",
+		},
+	}
+}
+
+// nameOf gives the name of a document relative to the root, as the loader
+// would find it
+func (s *Server) nameOf(uri string) string {
+	u, err := url.Parse(uri)
+	if err != nil || u.Scheme != "file" {
+		return uri
+	}
+	if name, err := filepath.Rel(s.root, u.Path); err == nil {
+		return filepath.ToSlash(name)
+	}
+	return u.Path
+}
+
+func (s *Server) uriOf(name string) string {
+	if strings.ContainsRune(name, ':') {
+		return name
+	}
+	u := url.URL{Scheme: "file", Path: filepath.Join(s.root, filepath.FromSlash(name))}
+	return u.String()
+}
+
+// refresh analyses every open document again, and publishes what's wrong
+// with them. Fragments are only lexed, unless a workspace includes them.
+func (s *Server) refresh() error {
+	names := make([]string, 0, len(s.documents))
+	for name := range s.documents {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	diags := make(map[string][]Diagnostic)
+	var fragments []string
+	for _, name := range names {
+		a := analyse(context.Background(), name, s.documents, s.loader)
+		s.analyses[name] = a
+		if !a.compiled {
+			fragments = append(fragments, name)
+			continue
+		}
+		for file, d := range a.diagnostics() {
+			diags[file] = append(diags[file], d...)
+		}
+	}
+	for _, name := range fragments {
+		if _, included := diags[name]; !included {
+			diags[name] = s.analyses[name].diagnostics()[name]
+		}
+	}
+
+	files := make([]string, 0, len(diags))
+	for file := range diags {
+		files = append(files, file)
+	}
+	sort.Strings(files)
+	for _, file := range files {
+		if err := s.publish(file, diags[file]); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (s *Server) publish(name string, diags []Diagnostic) error {
+	params, err := json.Marshal(publishDiagnosticsParams{URI: s.uriOf(name), Diagnostics: diags})
+	if err != nil {
+		return err
+	}
+	return writeMessage(s.out, &message{Method: "textDocument/publishDiagnostics", Params: params})
+}
+
+func (s *Server) positionRequest(msg *message, handler func(a *analysis, name string, offset int, params positionParams) any) (any, error) {
+	var params positionParams
+	if err := unmarshalParams(msg, &params); err != nil {
+		return nil, err
+	}
+	name := s.nameOf(params.TextDocument.URI)
+	a := s.analysisOf(name)
+	if a == nil {
+		return nil, nil
+	}
+	return handler(a, name, offsetOf(a.sources[name], params.Position), params), nil
+}
+
+// analysisOf gives the analysis that knows the most about a document,
+// which for a fragment is that of a workspace including it
+func (s *Server) analysisOf(name string) *analysis {
+	if a := s.analyses[name]; a != nil && len(a.index) > 0 {
+		return a
+	}
+	for _, a := range s.analyses {
+		if _, has := a.tokens[name]; has && len(a.index) > 0 {
+			return a
+		}
+	}
+	return s.analyses[name]
+}
+
+func (s *Server) locationOf(a *analysis, tok *lexer.Token) Location {
+	return Location{URI: s.uriOf(tok.Positions().Start.File), Range: a.rangeOf(tok)}
+}
+
+func (s *Server) definition(a *analysis, name string, offset int, _ positionParams) any {
+	ref := a.referenceAt(name, offset)
+	if ref == nil {
+		return nil
+	}
+	for _, r := range a.references {
+		if r.entity == ref.entity && r.declaration {
+			return s.locationOf(a, r.token)
+		}
+	}
+	if tok := a.parser.DeclarationOf(ref.entity); tok != nil {
+		return s.locationOf(a, tok)
+	}
+	return nil
+}
+
+func (s *Server) references(a *analysis, name string, offset int, params positionParams) any {
+	ref := a.referenceAt(name, offset)
+	if ref == nil {
+		return nil
+	}
+	locations := []Location{}
+	for _, r := range a.references {
+		if r.entity == ref.entity && (!r.declaration || params.Context.IncludeDeclaration) {
+			locations = append(locations, s.locationOf(a, r.token))
+		}
+	}
+	return locations
+}
+
+func (s *Server) hover(a *analysis, name string, offset int, _ positionParams) any {
+	ref := a.referenceAt(name, offset)
+	if ref == nil {
+		return nil
+	}
+
+	e := ref.entity.Base()
+	text := new(strings.Builder)
+	title := e.Name
+	if title == "" {
+		title = string(ref.entity.Id())
+	}
+	fmt.Fprintf(text, "**%s**", title)
+	fmt.Fprintf(text, " (%s `%s`)", keywordOf(ref.entity).Canonical(), ref.entity.Id())
+	if e.Description != "" {
+		fmt.Fprintf(text, "\n\n%s", e.Description)
+	}
+	if e.Technology != "" {
+		fmt.Fprintf(text, "\n\nTechnology: %s", e.Technology)
+	}
+
+	return hover{
+		Contents: markupContent{Kind: "markdown", Value: text.String()},
+		Range:    a.rangeOf(ref.token),
+	}
+}
+
+func keywordOf(e parser.Entity) parser.Keyword {
+	switch e.(type) {
+	case *parser.Person:
+		return parser.KeywordPerson
+	case *parser.SoftwareSystem:
+		return parser.KeywordSoftwareSystem
+	case *parser.Container:
+		return parser.KeywordContainer
+	case *parser.Component:
+		return parser.KeywordComponent
+	case *parser.DeploymentEnvironment:
+		return parser.KeywordDeploymentEnvironment
+	case *parser.DeploymentNode:
+		return parser.KeywordDeploymentNode
+	case *parser.InfrastructureNode:
+		return parser.KeywordInfrastructureNode
+	case *parser.ContainerInstance:
+		return parser.KeywordContainerInstance
+	case *parser.SoftwareSystemInstance:
+		return parser.KeywordSoftwareSystemInstance
+	}
+	return ""
+}
+
+// completion offers the keywords the block at the position allows, where a
+// statement starts or after an assignment
+func (s *Server) completion(a *analysis, name string, offset int, _ positionParams) any {
+	src := a.sources[name]
+	items := []CompletionItem{}
+
+	var b blocks
+	for _, tok := range a.tokens[name] {
+		pos := tok.Positions()
+		// the word being typed doesn't count
+		if pos.Start.ByteOffset >= offset || pos.End.ByteOffset >= offset && tok.Is(lexer.TypeIdentifier, lexer.TypeKeyword) {
+			break
+		}
+		b.next(src, tok)
+	}
+	if b.words > 0 && b.last != lexer.TypeAssignment {
+		return items
+	}
+
+	block := b.block()
+	if block == "" && firstKeyword(src, a.tokens[name]) != parser.KeywordWorkspace {
+		// fragments are most often of the model
+		block = parser.KeywordModel
+	}
+	for _, k := range parser.BodyKeywords[block] {
+		items = append(items, CompletionItem{Label: k.Canonical(), Kind: completionKeyword})
+	}
+	return items
+}
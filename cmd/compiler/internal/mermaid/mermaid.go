@@ -0,0 +1,187 @@
+package mermaid
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"strings"
+
+	"go.burian.dev/c4/cmd/compiler/internal/diagram"
+	"go.burian.dev/c4/cmd/compiler/internal/parser"
+)
+
+// Extension is the file extension of rendered views
+const Extension = ".mmd"
+
+// Render writes a checked view as a Mermaid C4 diagram. Mermaid has no tags
+// of its own, so the workspace's styles are applied to each element and
+// relationship individually.
+func Render(out io.Writer, w *parser.Workspace, view parser.View) error {
+	var styles *parser.Styles
+	if w.Views != nil {
+		styles = w.Views.Styles
+	}
+
+	r := &renderer{
+		w:      bufio.NewWriter(out),
+		view:   view,
+		styles: styles,
+
+		aliases: new(diagram.Aliases),
+	}
+
+	r.render()
+	return r.w.Flush()
+}
+
+type renderer struct {
+	w      *bufio.Writer
+	view   parser.View
+	styles *parser.Styles
+
+	aliases *diagram.Aliases
+
+	// style updates are written after everything they refer to
+	updates []string
+}
+
+func (r *renderer) render() {
+	base := r.view.Base()
+
+	r.printf("%s\n", diagramType(r.view))
+
+	title := base.Description
+	if title == "" {
+		title = base.Key
+	}
+	r.printf("    title %s\n\n", strings.ReplaceAll(title, "\n", " "))
+
+	calls := &diagram.Calls{Aliases: r.aliases, Element: r.element, Quote: quote}
+	for _, g := range diagram.GroupElements(base.Elements) {
+		calls.WriteGroup(r.w, g, "    ")
+	}
+
+	if dynamic, ok := r.view.(*parser.DynamicView); ok {
+		if len(dynamic.Steps) > 0 {
+			r.printf("\n")
+		}
+		for _, step := range dynamic.Steps {
+			r.printf("    RelIndex(%s)\n", diagram.Args(
+				quote(step.Order),
+				r.aliases.Of(step.Source.Id()),
+				r.aliases.Of(step.Destination.Id()),
+				quote(step.Description),
+				quote(step.Technology),
+			))
+			r.relationshipStyle(step.Relationship)
+		}
+	} else {
+		if len(base.Relationships) > 0 {
+			r.printf("\n")
+		}
+		for _, rel := range base.Relationships {
+			r.printf("    Rel(%s)\n", diagram.Args(
+				r.aliases.Of(rel.Source.Id()),
+				r.aliases.Of(rel.Destination.Id()),
+				quote(rel.Description),
+				quote(rel.Technology),
+			))
+			r.relationshipStyle(rel)
+		}
+	}
+
+	if len(r.updates) > 0 {
+		r.printf("\n")
+	}
+	for _, update := range r.updates {
+		r.printf("    %s\n", update)
+	}
+}
+
+// element returns the Mermaid call for a single element, noting any style
+// it needs to be updated with
+func (r *renderer) element(e parser.Entity) string {
+	b := e.Base()
+	id := r.aliases.Of(e.Id())
+	style := r.styles.ElementStyle(parser.TagsOf(e))
+
+	var params []string
+	if style.Background != "" {
+		params = append(params, "$bgColor="+quote(style.Background))
+	}
+	if style.Color != "" {
+		params = append(params, "$fontColor="+quote(style.Color))
+	}
+	if style.Stroke != "" {
+		params = append(params, "$borderColor="+quote(style.Stroke))
+	}
+	if len(params) > 0 {
+		r.updates = append(r.updates, fmt.Sprintf("UpdateElementStyle(%s, %s)", id, strings.Join(params, ", ")))
+	}
+
+	switch obj := e.(type) {
+	case *parser.Person:
+		return "Person(" + diagram.Args(id, quote(b.Name), quote(b.Description)) + ")"
+
+	case *parser.SoftwareSystem:
+		return diagram.Variant("System", style.Shape) + "(" + diagram.Args(id, quote(b.Name), quote(b.Description)) + ")"
+
+	case *parser.Container:
+		return diagram.Variant("Container", style.Shape) + "(" + diagram.Args(id, quote(b.Name), quote(b.Technology), quote(b.Description)) + ")"
+
+	case *parser.Component:
+		return diagram.Variant("Component", style.Shape) + "(" + diagram.Args(id, quote(b.Name), quote(b.Technology), quote(b.Description)) + ")"
+
+	case *parser.DeploymentNode:
+		name := b.Name
+		if obj.Instances > 1 {
+			name = fmt.Sprintf("%s (x%d)", name, obj.Instances)
+		}
+		return "Deployment_Node(" + diagram.Args(id, quote(name), quote(b.Technology), quote(b.Description)) + ")"
+
+	case *parser.InfrastructureNode:
+		return "Node(" + diagram.Args(id, quote(b.Name), quote(b.Technology), quote(b.Description)) + ")"
+
+	case *parser.ContainerInstance:
+		deployed := obj.Container.Base()
+		return diagram.Variant("Container", style.Shape) + "(" + diagram.Args(id, quote(deployed.Name), quote(deployed.Technology), quote(deployed.Description)) + ")"
+
+	case *parser.SoftwareSystemInstance:
+		deployed := obj.SoftwareSystem.Base()
+		return diagram.Variant("System", style.Shape) + "(" + diagram.Args(id, quote(deployed.Name), quote(deployed.Description)) + ")"
+	}
+
+	return "System(" + diagram.Args(id, quote(b.Name), quote(b.Description)) + ")"
+}
+
+func (r *renderer) relationshipStyle(rel *parser.Relationship) {
+	style := r.styles.RelationshipStyle(parser.TagsOf(rel))
+	if style.Color == "" {
+		return
+	}
+	r.updates = append(r.updates, fmt.Sprintf("UpdateRelStyle(%s, %s, $textColor=%s, $lineColor=%s)",
+		r.aliases.Of(rel.Source.Id()), r.aliases.Of(rel.Destination.Id()), quote(style.Color), quote(style.Color)))
+}
+
+func diagramType(view parser.View) string {
+	switch view.(type) {
+	case *parser.ContainerView:
+		return "C4Container"
+	case *parser.ComponentView:
+		return "C4Component"
+	case *parser.DynamicView:
+		return "C4Dynamic"
+	case *parser.DeploymentView:
+		return "C4Deployment"
+	}
+	return "C4Context"
+}
+
+func (r *renderer) printf(format string, a ...any) {
+	fmt.Fprintf(r.w, format, a...)
+}
+
+// Mermaid strings can't escape quotes or span lines
+func quote(s string) string {
+	return diagram.Quote(s, " ")
+}
@@ -0,0 +1,47 @@
+package mermaid
+
+import (
+	"strings"
+	"testing"
+
+	"go.burian.dev/c4/cmd/compiler/internal/parser"
+)
+
+func TestRender(t *testing.T) {
+	db := &parser.Container{}
+	db.SetId("db")
+	db.Name = `the "db"`
+	db.Description = "line one\nline two"
+	db.Tags = []string{"Database"}
+
+	view := &parser.ComponentView{}
+	view.Key = "components"
+	view.Description = "Inside the api"
+	view.Elements = []parser.Entity{db}
+
+	w := &parser.Workspace{
+		Views: &parser.Views{
+			Styles: &parser.Styles{
+				Elements: []*parser.ElementStyle{
+					{Tag: "Database", Shape: parser.ShapeCylinder, Stroke: "#000000"},
+				},
+			},
+		},
+	}
+
+	buf := new(strings.Builder)
+	if err := Render(buf, w, view); err != nil {
+		t.Fatalf("unexpected render error: %s", err)
+	}
+
+	want := `C4Component
+    title Inside the api
+
+    ContainerDb(db, "the 'db'", "", "line one line two")
+
+    UpdateElementStyle(db, $borderColor="#000000")
+`
+	if got := buf.String(); got != want {
+		t.Errorf("got\n%s\nwant\n%s", got, want)
+	}
+}
@@ -0,0 +1,293 @@
+package parser
+
+import (
+	"fmt"
+
+	"go.burian.dev/c4/cmd/compiler/internal/lexer"
+)
+
+// DeploymentEnvironment is the root of a deployment model, such as
+// staging or production
+type DeploymentEnvironment struct {
+	baseEntity
+}
+
+// DeploymentNode is anything containers run on: physical hardware, virtual
+// machines, container runtimes, and so on. Nodes may be nested.
+type DeploymentNode struct {
+	baseEntity
+	Instances int `json:"instances,omitempty"`
+}
+
+// InfrastructureNode is supporting infrastructure within a deployment node
+// that isn't part of the static model, such as a load balancer or DNS
+type InfrastructureNode struct {
+	baseEntity
+}
+
+// ContainerInstance is a container from the static model deployed on a node
+type ContainerInstance struct {
+	baseEntity
+	ContainerId IdentifierString `json:"container_id"`
+
+	// resolved by the checker
+	Container *Container `json:"-"`
+}
+
+// SoftwareSystemInstance is a software system from the static model deployed
+// on a node
+type SoftwareSystemInstance struct {
+	baseEntity
+	SoftwareSystemId IdentifierString `json:"software_system_id"`
+
+	// resolved by the checker
+	SoftwareSystem *SoftwareSystem `json:"-"`
+}
+
+// DeploymentView shows the deployment nodes of one environment as nested
+// boundaries around the instances deployed on them. Views scoped to a
+// software system only show the instances of its containers.
+type DeploymentView struct {
+	baseView
+	SoftwareSystemId IdentifierString `json:"software_system_id,omitempty"`
+
+	// either the identifier or the name of the environment
+	Environment string `json:"environment"`
+
+	// resolved by the checker
+	EnvironmentId         IdentifierString       `json:"environment_id,omitempty"`
+	DeploymentEnvironment *DeploymentEnvironment `json:"-"`
+}
+
+// parses `deploymentEnvironment <name> { ... }`
+func (p *Parser) parseDeploymentEnvironment() (*DeploymentEnvironment, error) {
+	env := new(DeploymentEnvironment)
+	p.declare(env)
+
+	err := p.parseShortDeclarationSeq(1,
+		&env.Name,
+	)
+	if err != nil {
+		return nil, fmt.Errorf("error parsing deployment environment short declaration:\n> %w", err)
+	}
+
+	if p.acceptOne(lexer.TypeTerminator) {
+		return env, nil
+	}
+
+	if !p.acceptOne(lexer.TypeStartBlock) {
+		return nil, p.errExpectedNext().Tokens(lexer.TypeStartBlock)
+	}
+
+	err = p.parseEntityBase(&env.baseEntity, BodyKeywords[KeywordDeploymentEnvironment]...)
+	if err != nil {
+		return nil, fmt.Errorf("error parsing deployment environment body:\n> %w", err)
+	}
+
+	if !p.acceptOne(lexer.TypeEndBlock) {
+		return nil, p.errExpectedNext().Tokens(lexer.TypeEndBlock)
+	}
+
+	return env, nil
+}
+
+// parses `deploymentNode <name> [description] [technology] [tags] [instances] { ... }`
+func (p *Parser) parseDeploymentNode() (*DeploymentNode, error) {
+	n := new(DeploymentNode)
+	n.Instances = 1
+	p.declare(n)
+
+	err := p.parseShortDeclarationSeq(1,
+		&n.Name,
+		&n.Description,
+		&n.Technology,
+		&n.Tags,
+	)
+	if err != nil {
+		return nil, fmt.Errorf("error parsing deployment node short declaration:\n> %w", err)
+	}
+
+	if p.acceptOne(lexer.TypeNumber) {
+		if n.Instances, err = p.currentInstances(); err != nil {
+			return nil, err
+		}
+	}
+
+	if p.acceptOne(lexer.TypeTerminator) {
+		return n, nil
+	}
+
+	if !p.acceptOne(lexer.TypeStartBlock) {
+		return nil, p.errExpectedNext().Tokens(lexer.TypeStartBlock)
+	}
+
+	// instances isn't handled by the base parser, and may come up at any time
+	for {
+		err = p.parseEntityBase(&n.baseEntity, BodyKeywords[KeywordDeploymentNode]...)
+		if err != nil {
+			return nil, fmt.Errorf("error parsing deployment node body:\n> %w", err)
+		}
+
+		if p.acceptOne(lexer.TypeEndBlock) {
+			return n, nil
+		}
+
+		err = p.statement(func() error {
+			if !p.acceptOne(lexer.TypeKeyword) {
+				return p.errExpectedNext().Tokens(lexer.TypeEndBlock)
+			}
+			if p.currentKeyword() != KeywordInstances {
+				panic("unhandled keyword by entity base parser should have errored")
+			}
+
+			if !p.acceptOne(lexer.TypeNumber) {
+				return p.errExpectedNext().Tokens(lexer.TypeNumber)
+			}
+			var err error
+			if n.Instances, err = p.currentInstances(); err != nil {
+				return err
+			}
+			if !p.acceptOne(lexer.TypeTerminator) {
+				return p.errExpectedNext().Tokens(lexer.TypeTerminator)
+			}
+			return nil
+		})
+		if err != nil {
+			return nil, err
+		}
+	}
+}
+
+func (p *Parser) currentInstances() (int, error) {
+	n, err := p.currentInt()
+	if err != nil {
+		return 0, err
+	}
+	if n < 1 {
+		return 0, ErrorForToken(p.currentToken, fmt.Errorf("a deployment node must have at least one instance"))
+	}
+	return n, nil
+}
+
+// parses `infrastructureNode <name> [description] [technology] [tags] { ... }`
+func (p *Parser) parseInfrastructureNode() (*InfrastructureNode, error) {
+	n := new(InfrastructureNode)
+	p.declare(n)
+
+	err := p.parseShortDeclarationSeq(1,
+		&n.Name,
+		&n.Description,
+		&n.Technology,
+		&n.Tags,
+	)
+	if err != nil {
+		return nil, fmt.Errorf("error parsing infrastructure node short declaration:\n> %w", err)
+	}
+
+	if p.acceptOne(lexer.TypeTerminator) {
+		return n, nil
+	}
+
+	if !p.acceptOne(lexer.TypeStartBlock) {
+		return nil, p.errExpectedNext().Tokens(lexer.TypeStartBlock)
+	}
+
+	err = p.parseEntityBase(&n.baseEntity, BodyKeywords[KeywordInfrastructureNode]...)
+	if err != nil {
+		return nil, fmt.Errorf("error parsing infrastructure node body:\n> %w", err)
+	}
+
+	if !p.acceptOne(lexer.TypeEndBlock) {
+		return nil, p.errExpectedNext().Tokens(lexer.TypeEndBlock)
+	}
+
+	return n, nil
+}
+
+// parses `containerInstance <container> [tags] { ... }`
+func (p *Parser) parseContainerInstance() (*ContainerInstance, error) {
+	ci := new(ContainerInstance)
+	p.declare(ci)
+
+	if err := p.parseInstanceBody(&ci.baseEntity, &ci.ContainerId); err != nil {
+		return nil, fmt.Errorf("error parsing container instance:\n> %w", err)
+	}
+	return ci, nil
+}
+
+// parses `softwareSystemInstance <softwareSystem> [tags] { ... }`
+func (p *Parser) parseSoftwareSystemInstance() (*SoftwareSystemInstance, error) {
+	si := new(SoftwareSystemInstance)
+	p.declare(si)
+
+	if err := p.parseInstanceBody(&si.baseEntity, &si.SoftwareSystemId); err != nil {
+		return nil, fmt.Errorf("error parsing software system instance:\n> %w", err)
+	}
+	return si, nil
+}
+
+func (p *Parser) parseInstanceBody(e *baseEntity, of *IdentifierString) error {
+	err := p.parseShortDeclarationSeq(1,
+		of,
+		&e.Tags,
+	)
+	if err != nil {
+		return fmt.Errorf("error parsing instance short declaration:\n> %w", err)
+	}
+
+	if p.acceptOne(lexer.TypeTerminator) {
+		return nil
+	}
+
+	if !p.acceptOne(lexer.TypeStartBlock) {
+		return p.errExpectedNext().Tokens(lexer.TypeStartBlock)
+	}
+
+	err = p.parseEntityBase(e, instanceKeywords...)
+	if err != nil {
+		return fmt.Errorf("error parsing instance body:\n> %w", err)
+	}
+
+	if !p.acceptOne(lexer.TypeEndBlock) {
+		return p.errExpectedNext().Tokens(lexer.TypeEndBlock)
+	}
+
+	return nil
+}
+
+// parses `deployment <softwareSystem|*> <environment> [key] [description] { ... }`
+func (p *Parser) parseDeploymentView(v *DeploymentView) error {
+	if !p.acceptOne(lexer.TypeWildcard) {
+		if !p.acceptIdentifierString() {
+			return p.errExpectedNext().Tokens(lexer.TypeIdentifier, lexer.TypeWildcard)
+		}
+		v.SoftwareSystemId = p.claimHeldIdentifier()
+	}
+
+	if p.acceptIdentifierString() {
+		v.Environment = string(p.claimHeldIdentifier())
+	} else if p.acceptOne(lexer.TypeString) {
+		p.backupToken()
+		name, err := p.parseString()
+		if err != nil {
+			return fmt.Errorf("error parsing deployment environment name:\n> %w", err)
+		}
+		v.Environment = name
+	} else {
+		return p.errExpectedNext().Tokens(lexer.TypeIdentifier, lexer.TypeString)
+	}
+
+	err := p.parseShortDeclarationSeq(0,
+		&v.Key,
+		&v.Description,
+	)
+	if err != nil {
+		return fmt.Errorf("error parsing view short declaration:\n> %w", err)
+	}
+
+	if !p.acceptOne(lexer.TypeStartBlock) {
+		return p.errExpectedNext().Tokens(lexer.TypeStartBlock)
+	}
+
+	return p.parseViewBody(&v.baseView)
+}
@@ -0,0 +1,47 @@
+package parser
+
+import (
+	"go.burian.dev/c4/cmd/compiler/internal/lexer"
+)
+
+// Directive is an instruction to the later stages of the compiler, such as
+// `!identifiers flat`. The parser only records them, it's up to the checker
+// to decide what they mean.
+type Directive struct {
+	Name      string   `json:"name"`
+	Arguments []string `json:"arguments,omitempty"`
+}
+
+// parses the remainder of a directive after its leading '!'
+func (p *Parser) parseDirective() (*Directive, error) {
+	d := new(Directive)
+	p.declare(d)
+
+	if !p.acceptOne(lexer.TypeIdentifier) {
+		return nil, p.errExpectedNext().Tokens(lexer.TypeIdentifier)
+	}
+	d.Name = p.currentSymbol()
+
+	for {
+		if p.acceptOne(lexer.TypeTerminator) {
+			return d, nil
+		}
+
+		if p.acceptOne(lexer.TypeIdentifier) {
+			d.Arguments = append(d.Arguments, p.currentSymbol())
+			continue
+		}
+
+		if p.acceptOne(lexer.TypeString) {
+			p.backupToken()
+			arg, err := p.parseString()
+			if err != nil {
+				return nil, err
+			}
+			d.Arguments = append(d.Arguments, arg)
+			continue
+		}
+
+		return nil, p.errExpectedNext().Tokens(lexer.TypeIdentifier, lexer.TypeString, lexer.TypeTerminator)
+	}
+}
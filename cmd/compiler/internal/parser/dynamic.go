@@ -0,0 +1,196 @@
+package parser
+
+import (
+	"fmt"
+	"strconv"
+
+	"go.burian.dev/c4/cmd/compiler/internal/lexer"
+)
+
+// DynamicView shows an ordered sequence of interactions between elements,
+// scoped to a software system, a container, or everything with `*`
+type DynamicView struct {
+	baseView
+	ScopeId IdentifierString `json:"scope_id,omitempty"`
+	Steps   []*DynamicStep   `json:"steps,omitempty"`
+}
+
+type DynamicStep struct {
+	Order         string           `json:"order"`
+	SourceId      IdentifierString `json:"source_id"`
+	DestinationId IdentifierString `json:"destination_id"`
+	Description   string           `json:"description,omitempty"`
+	Technology    string           `json:"technology,omitempty"`
+
+	// resolved by the checker
+	Source       Entity        `json:"-"`
+	Destination  Entity        `json:"-"`
+	Relationship *Relationship `json:"-"`
+}
+
+// parses `dynamic <scope|*> [key] [description] { ... }`
+func (p *Parser) parseDynamicView(v *DynamicView) error {
+	if !p.acceptOne(lexer.TypeWildcard) {
+		if !p.acceptIdentifierString() {
+			return p.errExpectedNext().Tokens(lexer.TypeIdentifier, lexer.TypeWildcard)
+		}
+		v.ScopeId = p.claimHeldIdentifier()
+	}
+
+	err := p.parseShortDeclarationSeq(0,
+		&v.Key,
+		&v.Description,
+	)
+	if err != nil {
+		return fmt.Errorf("error parsing view short declaration:\n> %w", err)
+	}
+
+	if !p.acceptOne(lexer.TypeStartBlock) {
+		return p.errExpectedNext().Tokens(lexer.TypeStartBlock)
+	}
+
+	order := 0
+	return p.parseDynamicSteps(v, &order, true)
+}
+
+/*
+parses steps up to the end of the current block
+
+Steps are numbered in the order they're written unless given a number.
+Blocks are parallel sequences, each one numbered on from the step before
+the first of them, and the step after them carries on from the longest.
+
+	a -> b         // 1
+	{
+		b -> c     // 2
+		c -> d     // 3
+	}
+	{
+		b -> e     // 2
+	}
+	d -> a         // 4
+*/
+func (p *Parser) parseDynamicSteps(v *DynamicView, order *int, topLevel bool) error {
+	parallelStart, parallelEnd := -1, 0
+
+	for {
+		if p.acceptOne(lexer.TypeEndBlock) {
+			if parallelStart >= 0 {
+				*order = parallelEnd
+			}
+			return nil
+		}
+
+		if p.acceptOne(lexer.TypeTerminator) {
+			continue
+		}
+
+		if p.acceptOne(lexer.TypeStartBlock) {
+			if parallelStart < 0 {
+				parallelStart = *order
+			}
+			*order = parallelStart
+			if err := p.parseDynamicSteps(v, order, false); err != nil {
+				return fmt.Errorf("error parsing parallel sequence:\n> %w", err)
+			}
+			if *order > parallelEnd {
+				parallelEnd = *order
+			}
+			continue
+		}
+
+		if parallelStart >= 0 {
+			*order = parallelEnd
+			parallelStart, parallelEnd = -1, 0
+		}
+
+		err := p.statement(func() error {
+			if topLevel && p.acceptOne(lexer.TypeKeyword) {
+				switch p.currentKeyword() {
+				case KeywordDescription:
+					return p.parseSimpleValue("description", &v.Description)
+
+				case KeywordAutoLayout:
+					if v.AutoLayout != nil {
+						return ErrorForToken(p.currentToken, fmt.Errorf("illegal redeclaration of autoLayout"))
+					}
+					layout, err := p.parseAutoLayout()
+					if err != nil {
+						return fmt.Errorf("error parsing autoLayout:\n> %w", err)
+					}
+					v.AutoLayout = layout
+					return nil
+
+				default:
+					return p.errExpectedCurrent().Tokens(lexer.TypeIdentifier, lexer.TypeNumber, lexer.TypeStartBlock, lexer.TypeEndBlock).
+						Keywords(KeywordDescription, KeywordAutoLayout)
+				}
+			}
+
+			step, err := p.parseDynamicStep(order)
+			if err != nil {
+				return fmt.Errorf("error parsing dynamic view step:\n> %w", err)
+			}
+			v.Steps = append(v.Steps, step)
+			return nil
+		})
+		if err != nil {
+			return err
+		}
+	}
+}
+
+// parses `[order] a -> b [description] [technology]`
+func (p *Parser) parseDynamicStep(order *int) (*DynamicStep, error) {
+	step := new(DynamicStep)
+
+	if p.acceptOne(lexer.TypeNumber) {
+		p.declare(step)
+		step.Order = p.currentSymbol()
+
+		// only whole numbers move the implicit numbering along
+		if n, err := strconv.Atoi(step.Order); err == nil {
+			*order = n
+		}
+	}
+
+	if !p.acceptIdentifierString() {
+		return nil, p.errExpectedNext().Tokens(lexer.TypeIdentifier, lexer.TypeNumber, lexer.TypeStartBlock, lexer.TypeEndBlock)
+	}
+	step.SourceId = p.claimHeldIdentifier()
+
+	if step.Order == "" {
+		p.declare(step)
+		*order++
+		step.Order = strconv.Itoa(*order)
+	}
+
+	if !p.acceptOne(lexer.TypeRelationship) {
+		return nil, p.errExpectedNext().Tokens(lexer.TypeRelationship)
+	}
+
+	if !p.acceptIdentifierString() {
+		return nil, p.errExpectedNext().Tokens(lexer.TypeIdentifier)
+	}
+	step.DestinationId = p.claimHeldIdentifier()
+
+	err := p.parseShortDeclarationSeq(0,
+		&step.Description,
+		&step.Technology,
+	)
+	if err != nil {
+		return nil, fmt.Errorf("error parsing step short declaration:\n> %w", err)
+	}
+
+	if p.acceptOne(lexer.TypeEndBlock) {
+		// the block closes on the same line as its last step
+		p.backupToken()
+		return step, nil
+	}
+
+	if !p.acceptOne(lexer.TypeTerminator) {
+		return nil, p.errExpectedNext().Tokens(lexer.TypeTerminator, lexer.TypeEndBlock)
+	}
+
+	return step, nil
+}
@@ -2,6 +2,7 @@ package parser
 
 import (
 	"fmt"
+	"sort"
 	"strings"
 
 	"go.burian.dev/c4/cmd/compiler/internal/lexer"
@@ -10,10 +11,20 @@ import (
 type Entity interface {
 	Id() IdentifierString
 	SetId(IdentifierString)
+	SetFullyQualifiedId(IdentifierString)
 
 	SetGroup(string)
+
+	Parent() Entity
+	SetParent(Entity)
+
+	Base() *BaseEntity
 }
 
+// BaseEntity is the set of properties common to every entity, allowing
+// later compiler stages to inspect entities without knowing their type
+type BaseEntity = baseEntity
+
 type baseEntity struct {
 	childEntities
 	relationshipEntity
@@ -21,7 +32,7 @@ type baseEntity struct {
 	LocalId          IdentifierString `json:"local_id,omitempty"`
 	FullyQualifiedId IdentifierString `json:"fully_qualified_id,omitempty"`
 
-	Parent Entity `json:"parent,omitempty"`
+	parent Entity
 
 	Name         string            `json:"name,omitempty"`
 	Description  string            `json:"description,omitempty"`
@@ -58,9 +69,21 @@ func (b *baseEntity) Id() IdentifierString {
 func (b *baseEntity) SetId(id IdentifierString) {
 	b.LocalId = id
 }
+func (b *baseEntity) SetFullyQualifiedId(id IdentifierString) {
+	b.FullyQualifiedId = id
+}
 func (b *baseEntity) SetGroup(name string) {
 	b.Group = name
 }
+func (b *baseEntity) Parent() Entity {
+	return b.parent
+}
+func (b *baseEntity) SetParent(e Entity) {
+	b.parent = e
+}
+func (b *baseEntity) Base() *BaseEntity {
+	return b
+}
 
 type childEntities struct {
 	NamedEntities map[IdentifierString]Entity `json:"named_entities,omitempty"`
@@ -77,6 +100,31 @@ func (ch *childEntities) Add(e Entity) error {
 	return nil
 }
 
+// Children returns the named child entities ordered by their identifiers
+func (ch *childEntities) Children() []Entity {
+	ids := make([]IdentifierString, 0, len(ch.NamedEntities))
+	for id := range ch.NamedEntities {
+		ids = append(ids, id)
+	}
+	sort.Slice(ids, func(i, j int) bool { return ids[i] < ids[j] })
+
+	children := make([]Entity, len(ids))
+	for i := range ids {
+		children[i] = ch.NamedEntities[ids[i]]
+	}
+	return children
+}
+
+// AllRelationships returns every relationship declared in or beneath the
+// entity, in a stable order
+func (b *baseEntity) AllRelationships() []*Relationship {
+	rels := append([]*Relationship(nil), b.Relationships...)
+	for _, child := range b.Children() {
+		rels = append(rels, child.Base().AllRelationships()...)
+	}
+	return rels
+}
+
 func assignableKeywords(keys []Keyword) (onlyAssignable []Keyword) {
 	onlyAssignable = make([]Keyword, 0, len(keys))
 	allAssignable := []Keyword{
@@ -85,6 +133,11 @@ func assignableKeywords(keys []Keyword) (onlyAssignable []Keyword) {
 		KeywordContainer,
 		KeywordComponent,
 		KeywordGroup,
+		KeywordDeploymentEnvironment,
+		KeywordDeploymentNode,
+		KeywordInfrastructureNode,
+		KeywordContainerInstance,
+		KeywordSoftwareSystemInstance,
 	}
 	for _, candidate := range keys {
 		isAssignable := false
@@ -101,6 +154,10 @@ func assignableKeywords(keys []Keyword) (onlyAssignable []Keyword) {
 	return onlyAssignable
 }
 
+func declaresEntity(k Keyword) bool {
+	return k != KeywordGroup && len(assignableKeywords([]Keyword{k})) > 0
+}
+
 func (p *Parser) parseShortDeclarationSeq(must int, targets ...any) error {
 
 	finished := 0
@@ -163,6 +220,21 @@ parsing:
 }
 
 func (p *Parser) parseEntityBase(e *baseEntity, allowed ...Keyword) error {
+	for {
+		done := false
+		err := p.statement(func() (err error) {
+			done, err = p.parseEntityStatement(e, allowed)
+			return err
+		})
+		if err != nil || done {
+			return err
+		}
+	}
+}
+
+// parses one statement in the body of an entity, reporting when it reaches
+// something it doesn't handle and the caller must
+func (p *Parser) parseEntityStatement(e *baseEntity, allowed []Keyword) (done bool, err error) {
 
 	allowedKeyword := func(check Keyword, againt []Keyword) bool {
 		for i := range againt {
@@ -181,190 +253,238 @@ func (p *Parser) parseEntityBase(e *baseEntity, allowed ...Keyword) error {
 		// a relationship of a keyword
 		if p.acceptIdentifierString() {
 			if holdingName {
-				return p.errExpectedNext().Tokens(lexer.TypeRelationship).Keywords(assignableKeywords(allowed)...)
+				return false, p.errExpectedNext().Tokens(lexer.TypeRelationship).Keywords(assignableKeywords(allowed)...)
 			}
 
 			if err := p.parseEntityNameOrRelationship(e); err != nil {
-				return fmt.Errorf("error parsing entity:\n> %w", err)
+				return false, fmt.Errorf("error parsing entity:\n> %w", err)
 			}
 
 			if p.currentToken.Is(lexer.TypeAssignment) {
 				holdingName = true
+				continue
 			}
-			continue
+			return false, nil
 		}
 
 		if p.acceptOne(lexer.TypeRelationship) {
 			rel, err := p.parseRelationship("this")
 			if err != nil {
-				return fmt.Errorf("error parsing entity:\n> %w", err)
+				return false, fmt.Errorf("error parsing entity:\n> %w", err)
 			}
 			e.SetRelationship(rel)
-			holdingName = false
-			continue
+			return false, nil
 		}
 
 		if p.acceptOne(lexer.TypeKeyword) {
 			if !allowedKeyword(p.currentKeyword(), allowed) {
-				return p.errExpectedCurrent().Tokens(lexer.TypeIdentifier).Keywords(allowed...)
+				return false, p.errExpectedCurrent().Tokens(lexer.TypeIdentifier).Keywords(allowed...)
+			}
+
+			// entities declared without an identifier hold an empty one, so
+			// anonymous entities in their body can't claim the identifier of
+			// an assigned parent
+			if !holdingName && declaresEntity(p.currentKeyword()) {
+				p.holdIdentifierForAssignment("")
 			}
 
 			switch p.currentKeyword() {
 
 			case KeywordDescription:
 				if holdingName {
-					return p.errExpectedNext().Tokens(lexer.TypeRelationship).Keywords(assignableKeywords(allowed)...)
+					return false, p.errExpectedNext().Tokens(lexer.TypeRelationship).Keywords(assignableKeywords(allowed)...)
 				}
 				if e.Description != "" {
-					return fmt.Errorf("illegal redeclaration of description in body")
+					return false, fmt.Errorf("illegal redeclaration of description in body")
 				}
 				desc, err := p.parseOneOrMoreLineString()
 				if err != nil {
-					return err
+					return false, err
 				}
 				e.Description = desc
 
 				if !p.acceptOne(lexer.TypeTerminator) {
-					return p.errExpectedNext().Tokens(lexer.TypeTerminator)
+					return false, p.errExpectedNext().Tokens(lexer.TypeTerminator)
 				}
-				continue
+				return false, nil
 
 			case KeywordTags:
 				if holdingName {
-					return p.errExpectedNext().Tokens(lexer.TypeRelationship).Keywords(assignableKeywords(allowed)...)
+					return false, p.errExpectedNext().Tokens(lexer.TypeRelationship).Keywords(assignableKeywords(allowed)...)
 				}
 				if len(e.Tags) > 0 {
-					return fmt.Errorf("illegal redeclaratipn of tags in body")
+					return false, fmt.Errorf("illegal redeclaratipn of tags in body")
 				}
 				newTags, err := p.parseTags()
 				if err != nil {
-					return fmt.Errorf("error parsing entity:\n> %w", err)
+					return false, fmt.Errorf("error parsing entity:\n> %w", err)
 				}
 				e.Tags = append(e.Tags, newTags...)
 				if !p.acceptOne(lexer.TypeTerminator) {
-					return p.errExpectedNext().Tokens(lexer.TypeTerminator)
+					return false, p.errExpectedNext().Tokens(lexer.TypeTerminator)
 				}
-				continue
+				return false, nil
 
 			case KeywordProperties:
 				if holdingName {
-					return p.errExpectedNext().Tokens(lexer.TypeRelationship).Keywords(assignableKeywords(allowed)...)
+					return false, p.errExpectedNext().Tokens(lexer.TypeRelationship).Keywords(assignableKeywords(allowed)...)
 				}
 				if len(e.Properties) > 0 {
-					return fmt.Errorf("illegal dupluicate declaration of properties")
+					return false, fmt.Errorf("illegal dupluicate declaration of properties")
 				}
 				props, err := p.parseProperties()
 				if err != nil {
-					return fmt.Errorf("error parsing entity:\n> %w", err)
+					return false, fmt.Errorf("error parsing entity:\n> %w", err)
 				}
 				e.Properties = props
-				continue
+				return false, nil
 
 			case KeywordPerspectives:
 				if holdingName {
-					return p.errExpectedNext().Tokens(lexer.TypeRelationship).Keywords(assignableKeywords(allowed)...)
+					return false, p.errExpectedNext().Tokens(lexer.TypeRelationship).Keywords(assignableKeywords(allowed)...)
 				}
 				if len(e.Perspectives) > 0 {
-					return fmt.Errorf("illegal dupluicate declaration of perspectives")
+					return false, fmt.Errorf("illegal dupluicate declaration of perspectives")
 				}
 				props, err := p.parseProperties()
 				if err != nil {
-					return fmt.Errorf("error parsing entity:\n> %w", err)
+					return false, fmt.Errorf("error parsing entity:\n> %w", err)
 				}
 				e.Properties = props
-				continue
+				return false, nil
 
 			case KeywordThis:
 				if holdingName {
-					return p.errExpectedNext().Tokens(lexer.TypeRelationship).Keywords(assignableKeywords(allowed)...)
+					return false, p.errExpectedNext().Tokens(lexer.TypeRelationship).Keywords(assignableKeywords(allowed)...)
 				}
 				if !p.acceptOne(lexer.TypeRelationship) {
 					p.errExpectedNext().Tokens(lexer.TypeRelationship)
 				}
 				r, err := p.parseRelationship("this")
 				if err != nil {
-					return fmt.Errorf("error parsing entity:\n> %w", err)
+					return false, fmt.Errorf("error parsing entity:\n> %w", err)
 				}
 				e.SetRelationship(r)
-				continue
+				return false, nil
 
 			case KeywordPerson:
 				pers, err := p.parsePerson()
 				if err != nil {
-					return fmt.Errorf("error parsing entity:\n> %w", err)
+					return false, fmt.Errorf("error parsing entity:\n> %w", err)
 				}
 				p.assignIdentifier(pers)
-				holdingName = false
 				p.assignGroup(pers)
 				e.Add(pers)
-				continue
+				return false, nil
 
 			case KeywordSoftwareSystem:
 				ss, err := p.parseSoftwareSys()
 				if err != nil {
-					return fmt.Errorf("error parsing entity:\n> %w", err)
+					return false, fmt.Errorf("error parsing entity:\n> %w", err)
 				}
 				p.assignIdentifier(ss)
-				holdingName = false
 				p.assignGroup(ss)
 				e.Add(ss)
-				continue
+				return false, nil
 
 			case KeywordContainer:
 				cont, err := p.parseContainer()
 				if err != nil {
-					return fmt.Errorf("error parsing entity:\n> %w", err)
+					return false, fmt.Errorf("error parsing entity:\n> %w", err)
 				}
 				p.assignIdentifier(cont)
-				holdingName = false
 				p.assignGroup(cont)
 				e.Add(cont)
-				continue
+				return false, nil
 
 			case KeywordComponent:
 				comp, err := p.parseComponent()
 				if err != nil {
-					return fmt.Errorf("error parsing entity:\n> %w", err)
+					return false, fmt.Errorf("error parsing entity:\n> %w", err)
 				}
 				p.assignIdentifier(comp)
-				holdingName = false
 				p.assignGroup(comp)
 				e.Add(comp)
-				continue
+				return false, nil
+
+			case KeywordDeploymentEnvironment:
+				env, err := p.parseDeploymentEnvironment()
+				if err != nil {
+					return false, fmt.Errorf("error parsing entity:\n> %w", err)
+				}
+				p.assignIdentifier(env)
+				e.Add(env)
+				return false, nil
+
+			case KeywordDeploymentNode:
+				node, err := p.parseDeploymentNode()
+				if err != nil {
+					return false, fmt.Errorf("error parsing entity:\n> %w", err)
+				}
+				p.assignIdentifier(node)
+				e.Add(node)
+				return false, nil
+
+			case KeywordInfrastructureNode:
+				node, err := p.parseInfrastructureNode()
+				if err != nil {
+					return false, fmt.Errorf("error parsing entity:\n> %w", err)
+				}
+				p.assignIdentifier(node)
+				e.Add(node)
+				return false, nil
+
+			case KeywordContainerInstance:
+				inst, err := p.parseContainerInstance()
+				if err != nil {
+					return false, fmt.Errorf("error parsing entity:\n> %w", err)
+				}
+				p.assignIdentifier(inst)
+				e.Add(inst)
+				return false, nil
+
+			case KeywordSoftwareSystemInstance:
+				inst, err := p.parseSoftwareSystemInstance()
+				if err != nil {
+					return false, fmt.Errorf("error parsing entity:\n> %w", err)
+				}
+				p.assignIdentifier(inst)
+				e.Add(inst)
+				return false, nil
 
 			case KeywordTechnology:
 				if holdingName {
-					return p.errExpectedNext().Tokens(lexer.TypeRelationship).Keywords(assignableKeywords(allowed)...)
+					return false, p.errExpectedNext().Tokens(lexer.TypeRelationship).Keywords(assignableKeywords(allowed)...)
 				}
 				err := p.parseSimpleValue("technology", &e.Technology)
 				if err != nil {
-					return err
+					return false, err
 				}
-				continue
+				return false, nil
 
 			case KeywordUrl:
 				if holdingName {
-					return p.errExpectedNext().Tokens(lexer.TypeRelationship).Keywords(assignableKeywords(allowed)...)
+					return false, p.errExpectedNext().Tokens(lexer.TypeRelationship).Keywords(assignableKeywords(allowed)...)
 				}
 				err := p.parseSimpleValue("url", &e.Url)
 				if err != nil {
-					return err
+					return false, err
 				}
-				continue
+				return false, nil
 
 			case KeywordName:
 				if holdingName {
-					return p.errExpectedNext().Tokens(lexer.TypeRelationship).Keywords(assignableKeywords(allowed)...)
+					return false, p.errExpectedNext().Tokens(lexer.TypeRelationship).Keywords(assignableKeywords(allowed)...)
 				}
 				err := p.parseSimpleValue("name", &e.Name)
 				if err != nil {
-					return err
+					return false, err
 				}
-				continue
+				return false, nil
 
 			default:
 				if holdingName {
-					return p.errExpectedNext().Tokens(lexer.TypeRelationship).Keywords(assignableKeywords(allowed)...)
+					return false, p.errExpectedNext().Tokens(lexer.TypeRelationship).Keywords(assignableKeywords(allowed)...)
 				}
 
 				// default is an interesting case here
@@ -375,24 +495,24 @@ func (p *Parser) parseEntityBase(e *baseEntity, allowed ...Keyword) error {
 				// backup because we consumed the keyword getting
 				// into this switch
 				p.backupToken()
-				return nil
+				return true, nil
 			}
 
 		}
 
 		// all valid uses of an assigned name happen before here
 		if holdingName {
-			return p.errExpectedNext().Tokens(lexer.TypeRelationship).Keywords(assignableKeywords(allowed)...)
+			return false, p.errExpectedNext().Tokens(lexer.TypeRelationship).Keywords(assignableKeywords(allowed)...)
 		}
 
 		// empty declarations aren't an error, just odd
 		if p.acceptOne(lexer.TypeTerminator) {
-			continue
+			return false, nil
 		}
 
 		// not a token we know how to deal with
 		// also return to caller
-		return nil
+		return true, nil
 
 	}
 }
@@ -461,11 +581,11 @@ func (p *Parser) parseTags() ([]string, error) {
 
 			dirtyTags := strings.Split(tagStr, ",")
 			for i := range dirtyTags {
-				dirtyTags[i] = p.cleanString(dirtyTags[i])
+				dirtyTags[i] = cleanString(dirtyTags[i])
 			}
 			tags = append(tags, dirtyTags...)
 		} else {
-			tags = append(tags, p.cleanString(tagStr))
+			tags = append(tags, cleanString(tagStr))
 		}
 	}
 
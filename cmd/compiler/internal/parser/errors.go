@@ -45,6 +45,22 @@ func ErrorForToken(t *lexer.Token, e error) error {
 
 }
 
+// Errors is every error found in one run of the parser, in the order they
+// were found
+type Errors []error
+
+func (e Errors) Error() string {
+	msgs := make([]string, len(e))
+	for i := range e {
+		msgs[i] = e[i].Error()
+	}
+	return strings.Join(msgs, "\n")
+}
+
+func (e Errors) Unwrap() []error {
+	return e
+}
+
 type ExpectationError struct {
 	gotToken   *lexer.Token
 	gotKeyword Keyword
@@ -65,9 +81,10 @@ func (p *Parser) errExpectedCurrent() *ExpectationError {
 
 func (p *Parser) errExpectedNext() *ExpectationError {
 	p.nextToken()
-	t := p.currentToken
+	// keywords are read from the current token, so build the error first
+	ee := p.newExpectationErrFor(p.currentToken)
 	p.backupToken()
-	return p.newExpectationErrFor(t)
+	return ee
 }
 
 func (p *Parser) newExpectationErrFor(t *lexer.Token) *ExpectationError {
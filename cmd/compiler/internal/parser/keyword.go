@@ -12,7 +12,7 @@ const (
 	KeywordWorkspace = Keyword("workspace")
 	KeywordExtends   = Keyword("extends")
 	KeywordModel     = Keyword("model")
-	KeywordView      = Keyword("view")
+	KeywordViews     = Keyword("views")
 
 	KeywordPerson         = Keyword("person")
 	KeywordSoftwareSystem = Keyword("softwaresystem")
@@ -20,6 +20,13 @@ const (
 	KeywordComponent      = Keyword("component")
 	KeywordGroup          = Keyword("group")
 
+	KeywordDeploymentEnvironment  = Keyword("deploymentenvironment")
+	KeywordDeploymentNode         = Keyword("deploymentnode")
+	KeywordInfrastructureNode     = Keyword("infrastructurenode")
+	KeywordContainerInstance      = Keyword("containerinstance")
+	KeywordSoftwareSystemInstance = Keyword("softwaresysteminstance")
+	KeywordInstances              = Keyword("instances")
+
 	KeywordPerspectives = Keyword("perspectives")
 	KeywordTags         = Keyword("tags")
 	KeywordDescription  = Keyword("description")
@@ -29,47 +36,144 @@ const (
 	KeywordUrl          = Keyword("url")
 	KeywordThis         = Keyword("this")
 
-	KeywordStyle = Keyword("style")
+	KeywordStyle        = Keyword("style")
+	KeywordStyles       = Keyword("styles")
+	KeywordElement      = Keyword("element")
+	KeywordRelationship = Keyword("relationship")
+	KeywordTheme        = Keyword("theme")
+	KeywordThemes       = Keyword("themes")
+
+	KeywordSystemLandscape = Keyword("systemlandscape")
+	KeywordSystemContext   = Keyword("systemcontext")
+	KeywordInclude         = Keyword("include")
+	KeywordExclude         = Keyword("exclude")
+	KeywordAutoLayout      = Keyword("autolayout")
+	KeywordDynamic         = Keyword("dynamic")
+	KeywordDeployment      = Keyword("deployment")
 )
 
-func (p *Parser) currentKeyword() Keyword {
-	if !p.currentToken.Is(lexer.TypeKeyword) {
-		panic("fetch non-keyword")
+// keywords written in camel case, by their lower case
+var camelKeywords = map[Keyword]string{
+	KeywordSoftwareSystem:         "softwareSystem",
+	KeywordDeploymentEnvironment:  "deploymentEnvironment",
+	KeywordDeploymentNode:         "deploymentNode",
+	KeywordInfrastructureNode:     "infrastructureNode",
+	KeywordContainerInstance:      "containerInstance",
+	KeywordSoftwareSystemInstance: "softwareSystemInstance",
+	KeywordSystemLandscape:        "systemLandscape",
+	KeywordSystemContext:          "systemContext",
+	KeywordAutoLayout:             "autoLayout",
+}
+
+// Canonical gives the keyword as it's conventionally written
+func (k Keyword) Canonical() string {
+	if camel, ok := camelKeywords[k]; ok {
+		return camel
 	}
+	return string(k)
+}
 
-	key := Keyword(strings.ToLower(string(p.currentSymbol())))
-	return Keyword(key)
-	var valid bool
+var instanceKeywords = []Keyword{
+	KeywordDescription,
+	KeywordTags,
+	KeywordUrl,
+	KeywordProperties,
+	KeywordPerspectives,
+	KeywordThis,
+}
 
-	switch key {
-	case KeywordWorkspace:
-		fallthrough
-	case KeywordModel:
-		fallthrough
-	case KeywordSoftwareSystem:
-		fallthrough
-	case KeywordContainer:
-		fallthrough
-	case KeywordComponent:
-		fallthrough
-	case KeywordGroup:
-		fallthrough
-	case KeywordPerspectives:
-		fallthrough
-	case KeywordTags:
-		fallthrough
-	case KeywordDescription:
-		fallthrough
-	case KeywordThis:
-		fallthrough
-	case KeywordTechnology:
-		fallthrough
-	case KeywordUrl:
-		valid = true
-	}
+// BodyKeywords are the keywords allowed in the body of each block the
+// entity base parser reads, by the keyword that opens the block. Some are
+// left for the block's own parser to handle.
+var BodyKeywords = map[Keyword][]Keyword{
+	KeywordWorkspace: {
+		KeywordName,
+		KeywordDescription,
+		KeywordProperties,
+		KeywordModel,
+		KeywordViews,
+	},
+	KeywordModel: {
+		KeywordPerson,
+		KeywordSoftwareSystem,
+		KeywordThis,
+		KeywordDeploymentEnvironment,
+		KeywordGroup, // not handled by entity base
+	},
+	KeywordPerson: {
+		KeywordDescription,
+		KeywordTags,
+		KeywordUrl,
+		KeywordProperties,
+		KeywordPerspectives,
+		KeywordThis,
+	},
+	KeywordSoftwareSystem: {
+		KeywordContainer,
+		KeywordDescription,
+		KeywordTags,
+		KeywordUrl,
+		KeywordProperties,
+		KeywordPerspectives,
+		KeywordThis,
+		KeywordGroup, // unhandled by pase parser
+	},
+	KeywordContainer: {
+		KeywordComponent,
+		KeywordDescription,
+		KeywordTechnology,
+		KeywordTags,
+		KeywordUrl,
+		KeywordProperties,
+		KeywordPerspectives,
+		KeywordThis,
+	},
+	KeywordComponent: {
+		KeywordDescription,
+		KeywordTechnology,
+		KeywordTags,
+		KeywordUrl,
+		KeywordProperties,
+		KeywordPerspectives,
+		KeywordThis,
+	},
+	KeywordDeploymentEnvironment: {
+		KeywordDeploymentNode,
+		KeywordDescription,
+		KeywordProperties,
+		KeywordThis,
+	},
+	KeywordDeploymentNode: {
+		KeywordDeploymentNode,
+		KeywordInfrastructureNode,
+		KeywordContainerInstance,
+		KeywordSoftwareSystemInstance,
+		KeywordDescription,
+		KeywordTechnology,
+		KeywordTags,
+		KeywordUrl,
+		KeywordProperties,
+		KeywordPerspectives,
+		KeywordThis,
+		KeywordInstances, // unhandled by base parser
+	},
+	KeywordInfrastructureNode: {
+		KeywordDescription,
+		KeywordTechnology,
+		KeywordTags,
+		KeywordUrl,
+		KeywordProperties,
+		KeywordPerspectives,
+		KeywordThis,
+	},
+	KeywordContainerInstance:      instanceKeywords,
+	KeywordSoftwareSystemInstance: instanceKeywords,
+}
 
-	if !valid {
-		panic("invalid keyword: " + key)
+func (p *Parser) currentKeyword() Keyword {
+	if !p.currentToken.Is(lexer.TypeKeyword) {
+		panic("fetch non-keyword")
 	}
-	return Keyword(key)
+
+	return Keyword(strings.ToLower(p.currentSymbol()))
 }
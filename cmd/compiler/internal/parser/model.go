@@ -9,9 +9,10 @@ import (
 type Workspace struct {
 	baseEntity
 
-	Extends string `json:"extends,omitempty"`
-	Model   *Model `json:"model,omitempty"`
-	Views   *Views `json:"views,omitempty"`
+	Extends    string       `json:"extends,omitempty"`
+	Model      *Model       `json:"model,omitempty"`
+	Views      *Views       `json:"views,omitempty"`
+	Directives []*Directive `json:"directives,omitempty"`
 }
 
 type Model struct {
@@ -19,17 +20,15 @@ type Model struct {
 
 	People          []*Person         `json:"people,omitempty"`
 	SoftwareSystems []*SoftwareSystem `json:"software_systems,omitempty"`
+	Directives      []*Directive      `json:"directives,omitempty"`
 }
 
-type Views struct{}
-
 type SoftwareSystem struct {
 	baseEntity
 }
 
 type Container struct {
 	baseEntity
-	relationshipEntity
 }
 
 type Relationship struct {
@@ -37,7 +36,15 @@ type Relationship struct {
 	SourceId      IdentifierString `json:"source_id,omitempty"`
 	DestinationId IdentifierString `json:"destination_id,omitempty"`
 
+	// resolved by the checker
+	Source      Entity `json:"-"`
+	Destination Entity `json:"-"`
+
 	ImpliedBasedOn *Relationship `json:"implied_based_on,omitempty"`
+
+	// the relationship in the static model that a relationship between
+	// deployed instances was replicated from
+	LinkedTo *Relationship `json:"linked_to,omitempty"`
 }
 
 type Person struct {
@@ -50,24 +57,31 @@ type Component struct {
 
 func (p *Parser) runParse() (*Workspace, error) {
 
+	// pragmas before the workspace are left on lines of their own
+	for p.acceptOne(lexer.TypeTerminator) {
+	}
+
 	if !p.acceptOne(lexer.TypeKeyword) {
-		return nil, p.errExpectedNext().Keywords(KeywordWorkspace)
+		p.errs = append(p.errs, p.errExpectedNext().Keywords(KeywordWorkspace))
+		return nil, p.errs
 	}
 
 	work, err := p.parseWorkspace()
 	if err != nil {
-		return nil, err
+		p.errs = append(p.errs, err)
+	} else if !p.acceptOne(lexer.TypeEOF) {
+		p.errs = append(p.errs, p.errExpectedNext().Tokens(lexer.TypeEOF))
 	}
 
-	if !p.acceptOne(lexer.TypeEOF) {
-		return nil, p.errExpectedNext().Tokens(lexer.TypeEOF)
+	if len(p.errs) > 0 {
+		return nil, p.errs
 	}
-
 	return work, nil
 }
 
 func (p *Parser) parseWorkspace() (*Workspace, error) {
 	wk := new(Workspace)
+	p.declare(wk)
 	var err error
 
 	// looking for `extends <path>`
@@ -102,13 +116,7 @@ func (p *Parser) parseWorkspace() (*Workspace, error) {
 		return nil, p.errExpectedNext().Tokens(lexer.TypeStartBlock)
 	}
 
-	expectedKeywords := []Keyword{
-		KeywordName,
-		KeywordDescription,
-		KeywordProperties,
-		KeywordModel,
-		KeywordView,
-	}
+	expectedKeywords := BodyKeywords[KeywordWorkspace]
 
 	for {
 
@@ -127,41 +135,63 @@ func (p *Parser) parseWorkspace() (*Workspace, error) {
 			return nil, p.errExpectedNext().Tokens(lexer.TypeEndBlock).Keywords(expectedKeywords...)
 		}
 
-		if p.acceptOne(lexer.TypeKeyword) {
-			switch p.currentKeyword() {
-			case KeywordName:
-				wk.Name, err = p.parseString()
-				if err != nil {
-					return nil, p.errExpectedNext().Keywords(expectedKeywords...)
-				}
-				continue
+		err = p.statement(func() error {
+			return p.parseWorkspaceStatement(wk, expectedKeywords)
+		})
+		if err != nil {
+			return nil, err
+		}
+	}
+}
 
-			case KeywordModel:
-				if wk.Model != nil {
-					return nil, fmt.Errorf("invalid redefinition of model")
-				}
-				if wk.Model, err = p.parseModel(); err != nil {
-					return nil, fmt.Errorf("error parsing model in workspace definition:\n> %w", err)
-				}
-				continue
+// parses the statements in a workspace that the entity base parser leaves
+func (p *Parser) parseWorkspaceStatement(wk *Workspace, expectedKeywords []Keyword) error {
+	var err error
 
-			case KeywordView:
-				if wk.Views != nil {
-					return nil, fmt.Errorf("invalid redefinition of views")
-				}
+	if p.acceptOne(lexer.TypeDirective) {
+		d, err := p.parseDirective()
+		if err != nil {
+			return fmt.Errorf("error parsing workspace directive:\n> %w", err)
+		}
+		wk.Directives = append(wk.Directives, d)
+		return nil
+	}
 
-				if wk.Views, err = p.parseViews(); err != nil {
-					return nil, fmt.Errorf("error parsing views in workspace definition:\n> %w", err)
-				}
-				continue
+	if p.acceptOne(lexer.TypeKeyword) {
+		switch p.currentKeyword() {
+		case KeywordName:
+			wk.Name, err = p.parseString()
+			if err != nil {
+				return p.errExpectedNext().Keywords(expectedKeywords...)
+			}
+			return nil
 
-			default:
-				panic("unhandled keyword case " + p.currentKeyword())
+		case KeywordModel:
+			if wk.Model != nil {
+				return ErrorForToken(p.currentToken, fmt.Errorf("invalid redefinition of model"))
 			}
+			if wk.Model, err = p.parseModel(); err != nil {
+				return fmt.Errorf("error parsing model in workspace definition:\n> %w", err)
+			}
+			return nil
 
+		case KeywordViews:
+			if wk.Views != nil {
+				return ErrorForToken(p.currentToken, fmt.Errorf("invalid redefinition of views"))
+			}
+
+			if wk.Views, err = p.parseViews(); err != nil {
+				return fmt.Errorf("error parsing views in workspace definition:\n> %w", err)
+			}
+			return nil
+
+		default:
+			return p.errExpectedCurrent().Tokens(lexer.TypeEndBlock, lexer.TypeDirective).Keywords(expectedKeywords...)
 		}
 
 	}
+
+	return p.errExpectedNext().Tokens(lexer.TypeEndBlock, lexer.TypeDirective).Keywords(expectedKeywords...)
 }
 
 func (p *Parser) parseModel() (*Model, error) {
@@ -175,12 +205,7 @@ func (p *Parser) parseModel() (*Model, error) {
 
 	for {
 
-		err = p.parseEntityBase(&m.baseEntity,
-			KeywordPerson,
-			KeywordSoftwareSystem,
-			KeywordThis,
-			KeywordGroup, // not handled by entity base
-		)
+		err = p.parseEntityBase(&m.baseEntity, BodyKeywords[KeywordModel]...)
 		if err != nil {
 			return nil, fmt.Errorf("parsing model base definition:\n> %w", err)
 		}
@@ -189,32 +214,48 @@ func (p *Parser) parseModel() (*Model, error) {
 			return m, nil
 		}
 
-		expectedKeywords := []Keyword{KeywordGroup, KeywordPerson, KeywordSoftwareSystem}
-		if !p.acceptOne(lexer.TypeKeyword) {
-			return nil, p.errExpectedNext().Keywords(expectedKeywords...)
+		err = p.statement(func() error {
+			return p.parseModelStatement(m)
+		})
+		if err != nil {
+			return nil, err
 		}
+	}
+}
 
-		switch p.currentKeyword() {
+// parses the statements in a model that the entity base parser leaves
+func (p *Parser) parseModelStatement(m *Model) error {
+	if p.acceptOne(lexer.TypeDirective) {
+		d, err := p.parseDirective()
+		if err != nil {
+			return fmt.Errorf("error parsing model directive:\n> %w", err)
+		}
+		m.Directives = append(m.Directives, d)
+		return nil
+	}
 
-		case KeywordGroup:
-			if err = p.parseModelGroup(m); err != nil {
-				return nil, fmt.Errorf("error parsing model:\n> %w", err)
-			}
+	expectedKeywords := []Keyword{KeywordGroup, KeywordPerson, KeywordSoftwareSystem, KeywordDeploymentEnvironment}
+	if !p.acceptOne(lexer.TypeKeyword) {
+		return p.errExpectedNext().Keywords(expectedKeywords...)
+	}
 
-		default:
-			return nil, p.errExpectedNext().Keywords(expectedKeywords...)
+	switch p.currentKeyword() {
+
+	case KeywordGroup:
+		if err := p.parseModelGroup(m); err != nil {
+			return fmt.Errorf("error parsing model:\n> %w", err)
 		}
+		return nil
 
+	default:
+		return p.errExpectedNext().Keywords(expectedKeywords...)
 	}
 }
 
-func (p *Parser) parseViews() (*Views, error) {
-	return nil, fmt.Errorf("unimplemented")
-}
-
 func (p *Parser) parsePerson() (*Person, error) {
 
 	per := new(Person)
+	p.declare(per)
 
 	err := p.parseShortDeclarationSeq(1,
 		&per.Name,
@@ -233,14 +274,7 @@ func (p *Parser) parsePerson() (*Person, error) {
 		return nil, p.errExpectedNext().Tokens(lexer.TypeStartBlock)
 	}
 
-	err = p.parseEntityBase(&per.baseEntity,
-		KeywordDescription,
-		KeywordTags,
-		KeywordUrl,
-		KeywordProperties,
-		KeywordPerspectives,
-		KeywordThis,
-	)
+	err = p.parseEntityBase(&per.baseEntity, BodyKeywords[KeywordPerson]...)
 	if err != nil {
 		return nil, fmt.Errorf("error parsing person block declaration:\n> %w", err)
 	}
@@ -256,6 +290,7 @@ func (p *Parser) parsePerson() (*Person, error) {
 func (p *Parser) parseSoftwareSys() (*SoftwareSystem, error) {
 
 	ss := new(SoftwareSystem)
+	p.declare(ss)
 
 	err := p.parseShortDeclarationSeq(1,
 		&ss.Name,
@@ -278,16 +313,7 @@ func (p *Parser) parseSoftwareSys() (*SoftwareSystem, error) {
 	// handle for us, and it might come up at any time
 	for {
 
-		err = p.parseEntityBase(&ss.baseEntity,
-			KeywordContainer,
-			KeywordDescription,
-			KeywordTags,
-			KeywordUrl,
-			KeywordProperties,
-			KeywordPerspectives,
-			KeywordThis,
-			KeywordGroup, // unhandled by pase parser
-		)
+		err = p.parseEntityBase(&ss.baseEntity, BodyKeywords[KeywordSoftwareSystem]...)
 		if err != nil {
 			return nil, fmt.Errorf("error parsing softwaresystem body:\n> %w", err)
 		}
@@ -303,6 +329,8 @@ func (p *Parser) parseSoftwareSys() (*SoftwareSystem, error) {
 
 			return nil, fmt.Errorf("inimplemented: software system group")
 		}
+
+		return nil, p.errExpectedNext().Tokens(lexer.TypeEndBlock)
 	}
 }
 
@@ -345,40 +373,48 @@ func (p *Parser) parseModelGroup(m *Model) error {
 			return nil
 		}
 
-		if p.acceptIdentifierString() {
-			if !p.acceptOne(lexer.TypeAssignment) {
-				return p.errExpectedNext().Tokens(lexer.TypeAssignment)
+		err := p.statement(func() error {
+			if p.acceptIdentifierString() {
+				if !p.acceptOne(lexer.TypeAssignment) {
+					return p.errExpectedNext().Tokens(lexer.TypeAssignment)
+				}
+			} else {
+				p.holdIdentifierForAssignment("")
 			}
-		}
 
-		if !p.acceptOne(lexer.TypeKeyword) {
-			return p.errExpectedNext().Keywords(KeywordPerson, KeywordSoftwareSystem)
-		}
+			if !p.acceptOne(lexer.TypeKeyword) {
+				return p.errExpectedNext().Keywords(KeywordPerson, KeywordSoftwareSystem)
+			}
 
-		switch p.currentKeyword() {
+			switch p.currentKeyword() {
 
-		case KeywordPerson:
-			pers, err := p.parsePerson()
-			if err != nil {
-				return fmt.Errorf("error parsing person in model group %s:\n> %w", currentGroup, err)
-			}
-			pers.Group = currentGroup
-			p.assignIdentifier(pers)
-			m.People = append(m.People, pers)
-			m.Add(pers)
+			case KeywordPerson:
+				pers, err := p.parsePerson()
+				if err != nil {
+					return fmt.Errorf("error parsing person in model group %s:\n> %w", currentGroup, err)
+				}
+				pers.Group = currentGroup
+				p.assignIdentifier(pers)
+				m.People = append(m.People, pers)
+				m.Add(pers)
 
-		case KeywordSoftwareSystem:
-			ss, err := p.parseSoftwareSys()
-			if err != nil {
-				return fmt.Errorf("error parsing softwaresystem in model group %s:\n> %w", currentGroup, err)
-			}
-			ss.Group = currentGroup
-			p.assignIdentifier(ss)
-			m.Add(ss)
-			m.SoftwareSystems = append(m.SoftwareSystems, ss)
+			case KeywordSoftwareSystem:
+				ss, err := p.parseSoftwareSys()
+				if err != nil {
+					return fmt.Errorf("error parsing softwaresystem in model group %s:\n> %w", currentGroup, err)
+				}
+				ss.Group = currentGroup
+				p.assignIdentifier(ss)
+				m.Add(ss)
+				m.SoftwareSystems = append(m.SoftwareSystems, ss)
 
-		default:
-			return p.errExpectedNext().Keywords(KeywordPerson, KeywordSoftwareSystem)
+			default:
+				return p.errExpectedNext().Keywords(KeywordPerson, KeywordSoftwareSystem)
+			}
+			return nil
+		})
+		if err != nil {
+			return err
 		}
 	}
 }
@@ -420,6 +456,7 @@ func (p *Parser) parseSoftwareSysGroup(ss *SoftwareSystem) error {
 
 func (p *Parser) parseContainer() (*Container, error) {
 	c := new(Container)
+	p.declare(c)
 
 	err := p.parseShortDeclarationSeq(1,
 		&c.Name,
@@ -439,18 +476,7 @@ func (p *Parser) parseContainer() (*Container, error) {
 		return nil, p.errExpectedNext().Tokens(lexer.TypeStartBlock)
 	}
 
-	allowedContainerProps := []Keyword{
-		KeywordComponent,
-		KeywordDescription,
-		KeywordTechnology,
-		KeywordTags,
-		KeywordUrl,
-		KeywordProperties,
-		KeywordPerspectives,
-		KeywordThis,
-	}
-
-	if err := p.parseEntityBase(&c.baseEntity, allowedContainerProps...); err != nil {
+	if err := p.parseEntityBase(&c.baseEntity, BodyKeywords[KeywordContainer]...); err != nil {
 		return nil, fmt.Errorf("error parsing container:\n> %w", err)
 	}
 
@@ -464,6 +490,7 @@ func (p *Parser) parseContainer() (*Container, error) {
 
 func (p *Parser) parseComponent() (*Component, error) {
 	c := new(Component)
+	p.declare(c)
 
 	err := p.parseShortDeclarationSeq(1,
 		&c.Name,
@@ -483,17 +510,7 @@ func (p *Parser) parseComponent() (*Component, error) {
 		return nil, p.errExpectedNext().Tokens(lexer.TypeStartBlock)
 	}
 
-	allowedComponentProps := []Keyword{
-		KeywordDescription,
-		KeywordTechnology,
-		KeywordTags,
-		KeywordUrl,
-		KeywordProperties,
-		KeywordPerspectives,
-		KeywordThis,
-	}
-
-	if err := p.parseEntityBase(&c.baseEntity, allowedComponentProps...); err != nil {
+	if err := p.parseEntityBase(&c.baseEntity, BodyKeywords[KeywordComponent]...); err != nil {
 		return nil, fmt.Errorf("error parsing container:\n> %w", err)
 	}
 
@@ -507,6 +524,7 @@ func (p *Parser) parseComponent() (*Component, error) {
 func (p *Parser) parseRelationship(from IdentifierString) (*Relationship, error) {
 	r := new(Relationship)
 	r.SourceId = from
+	p.declare(r)
 
 	// relationships either target an identifier or "this"
 	if p.acceptIdentifierString() {
@@ -259,6 +259,407 @@ func TestParser_runParse(t *testing.T) {
 				},
 			},
 		},
+		{
+			name: "directives",
+			input: `
+				workspace {
+					!identifiers flat
+					model {
+						!custom 'string arg' other
+					}
+				}
+			`,
+			want: &Workspace{
+				Directives: []*Directive{
+					{Name: "identifiers", Arguments: []string{"flat"}},
+				},
+				Model: &Model{
+					Directives: []*Directive{
+						{Name: "custom", Arguments: []string{"string arg", "other"}},
+					},
+				},
+			},
+		},
+		{
+			name: "views",
+			input: `
+				workspace {
+					views {
+						systemLandscape 'landscape' {
+							include *
+							autoLayout
+						}
+						systemContext a 'context' 'the context of a' {
+							include * b
+							exclude ->c-> d-> ->e
+							exclude a -> f
+							autoLayout lr 100 50
+						}
+						container a {
+							include *
+						}
+						component a.api {
+							description 'components'
+						}
+					}
+				}
+			`,
+			want: &Workspace{
+				Views: &Views{
+					SystemLandscapeViews: []*SystemLandscapeView{
+						{
+							baseView: baseView{
+								Key:        "landscape",
+								Include:    []*ViewExpression{{Wildcard: true}},
+								AutoLayout: &AutoLayout{Direction: "tb", RankSeparation: 300, NodeSeparation: 300},
+							},
+						},
+					},
+					SystemContextViews: []*SystemContextView{
+						{
+							SoftwareSystemId: "a",
+							baseView: baseView{
+								Key:         "context",
+								Description: "the context of a",
+								Include:     []*ViewExpression{{Wildcard: true}, {Id: "b"}},
+								Exclude: []*ViewExpression{
+									{Id: "c", Incoming: true, Outgoing: true},
+									{Id: "d", Outgoing: true},
+									{Id: "e", Incoming: true},
+									{Id: "a", DestinationId: "f"},
+								},
+								AutoLayout: &AutoLayout{Direction: "lr", RankSeparation: 100, NodeSeparation: 50},
+							},
+						},
+					},
+					ContainerViews: []*ContainerView{
+						{
+							SoftwareSystemId: "a",
+							baseView: baseView{
+								Include: []*ViewExpression{{Wildcard: true}},
+							},
+						},
+					},
+					ComponentViews: []*ComponentView{
+						{
+							ContainerId: "a.api",
+							baseView: baseView{
+								Description: "components",
+							},
+						},
+					},
+				},
+			},
+		},
+		{
+			name: "view without scope",
+			input: `workspace {
+				views {
+					systemContext 'key' {}
+				}
+			}`,
+			wantErr: true,
+		},
+		{
+			name: "bad layout direction",
+			input: `workspace {
+				views {
+					systemLandscape {
+						autoLayout sideways
+					}
+				}
+			}`,
+			wantErr: true,
+		},
+		{
+			name: "deployment model",
+			input: `workspace {
+				model {
+					prod = deploymentEnvironment 'Prod' {
+						aws = deploymentNode 'AWS' 'cloud' {
+							region = deploymentNode 'eu-west-1' {
+								instances 3
+								lb = infrastructureNode 'load balancer' 'balances' 'elb'
+								containerInstance a.api 'blue'
+								softwareSystemInstance b
+							}
+						}
+						deploymentNode 'on-prem' 'racks' 'metal' 'tag' 2
+					}
+				}
+			}`,
+			want: &Workspace{
+				Model: &Model{
+					baseEntity: baseEntity{
+						childEntities: childEntities{
+							NamedEntities: map[IdentifierString]Entity{
+								"prod": &DeploymentEnvironment{
+									baseEntity{
+										LocalId: "prod",
+										Name:    "Prod",
+										childEntities: childEntities{
+											NamedEntities: map[IdentifierString]Entity{
+												"aws": &DeploymentNode{
+													baseEntity: baseEntity{
+														LocalId:     "aws",
+														Name:        "AWS",
+														Description: "cloud",
+														childEntities: childEntities{
+															NamedEntities: map[IdentifierString]Entity{
+																"region": &DeploymentNode{
+																	baseEntity: baseEntity{
+																		LocalId: "region",
+																		Name:    "eu-west-1",
+																		childEntities: childEntities{
+																			NamedEntities: map[IdentifierString]Entity{
+																				"lb": &InfrastructureNode{
+																					baseEntity{
+																						LocalId:     "lb",
+																						Name:        "load balancer",
+																						Description: "balances",
+																						Technology:  "elb",
+																					},
+																				},
+																				"_containerinstance00_a_api": &ContainerInstance{
+																					baseEntity: baseEntity{
+																						LocalId: "_containerinstance00_a_api",
+																						Tags:    []string{"blue"},
+																					},
+																					ContainerId: "a.api",
+																				},
+																				"_softwaresysteminstance01_b": &SoftwareSystemInstance{
+																					baseEntity: baseEntity{
+																						LocalId: "_softwaresysteminstance01_b",
+																					},
+																					SoftwareSystemId: "b",
+																				},
+																			},
+																		},
+																	},
+																	Instances: 3,
+																},
+															},
+														},
+													},
+													Instances: 1,
+												},
+												"_deploymentnode02_on-prem": &DeploymentNode{
+													baseEntity: baseEntity{
+														LocalId:     "_deploymentnode02_on-prem",
+														Name:        "on-prem",
+														Description: "racks",
+														Technology:  "metal",
+														Tags:        []string{"tag"},
+													},
+													Instances: 2,
+												},
+											},
+										},
+									},
+								},
+							},
+						},
+					},
+				},
+			},
+		},
+		{
+			name: "deployment node with no instances",
+			input: `workspace {
+				model {
+					deploymentEnvironment 'Prod' {
+						deploymentNode 'AWS' {
+							instances 0
+						}
+					}
+				}
+			}`,
+			wantErr: true,
+		},
+		{
+			name: "container instance outside of deployment node",
+			input: `workspace {
+				model {
+					deploymentEnvironment 'Prod' {
+						containerInstance api
+					}
+				}
+			}`,
+			wantErr: true,
+		},
+		{
+			name: "deployment views",
+			input: `workspace {
+				views {
+					deployment * prod 'all' {
+						include *
+						autoLayout lr
+					}
+					deployment a 'Production' {
+						include ->web1->
+					}
+				}
+			}`,
+			want: &Workspace{
+				Views: &Views{
+					DeploymentViews: []*DeploymentView{
+						{
+							Environment: "prod",
+							baseView: baseView{
+								Key:        "all",
+								Include:    []*ViewExpression{{Wildcard: true}},
+								AutoLayout: &AutoLayout{Direction: "lr", RankSeparation: 300, NodeSeparation: 300},
+							},
+						},
+						{
+							SoftwareSystemId: "a",
+							Environment:      "Production",
+							baseView: baseView{
+								Include: []*ViewExpression{{Id: "web1", Incoming: true, Outgoing: true}},
+							},
+						},
+					},
+				},
+			},
+		},
+		{
+			name: "deployment view without environment",
+			input: `workspace {
+				views {
+					deployment * {}
+				}
+			}`,
+			wantErr: true,
+		},
+		{
+			name: "styles",
+			input: `workspace {
+				views {
+					styles {
+						element 'Database' {
+							shape cylinder
+							background '#1168BD'
+							color '#fff'
+							opacity 0
+							description false
+						}
+						relationship 'async' { dashed true; thickness 2 }
+					}
+					styles {
+						element 'Person' { shape 'Person'; border dotted }
+					}
+				}
+			}`,
+			want: &Workspace{
+				Views: &Views{
+					Styles: &Styles{
+						Elements: []*ElementStyle{
+							{
+								Tag:         "Database",
+								Shape:       ShapeCylinder,
+								Background:  "#1168bd",
+								Color:       "#ffffff",
+								Opacity:     new(int),
+								Description: new(bool),
+							},
+							{Tag: "Person", Shape: ShapePerson, Border: BorderDotted},
+						},
+						Relationships: []*RelationshipStyle{
+							{Tag: "async", Dashed: &[]bool{true}[0], Thickness: 2},
+						},
+					},
+				},
+			},
+		},
+		{
+			name:    "unknown shape",
+			input:   "workspace {\nviews {\nstyles {\nelement 'x' { shape blob }\n}\n}\n}",
+			wantErr: true,
+		},
+		{
+			name:    "bad colour",
+			input:   "workspace {\nviews {\nstyles {\nelement 'x' { background '#12345' }\n}\n}\n}",
+			wantErr: true,
+		},
+		{
+			name:    "opacity out of range",
+			input:   "workspace {\nviews {\nstyles {\nrelationship 'x' { opacity 101 }\n}\n}\n}",
+			wantErr: true,
+		},
+		{
+			name:    "unknown style property",
+			input:   "workspace {\nviews {\nstyles {\nrelationship 'x' { shape box }\n}\n}\n}",
+			wantErr: true,
+		},
+		{
+			name:    "redefined style",
+			input:   "workspace {\nviews {\nstyles {\nelement 'x' { shape box }\nelement 'x' { color red }\n}\n}\n}",
+			wantErr: true,
+		},
+		{
+			name:    "redeclared style property",
+			input:   "workspace {\nviews {\nstyles {\nelement 'x' { shape box; shape circle }\n}\n}\n}",
+			wantErr: true,
+		},
+		{
+			name: "dynamic view",
+			input: `workspace {
+				views {
+					dynamic a 'flow' 'signing in' {
+						u -> a.web 'submits'
+						{
+							web -> api 'checks'
+							api -> db
+						}
+						{
+							web -> cache 'looks up' 'redis'
+						}
+						web -> u
+						7 u -> web
+						7.1 web -> api
+						web -> db
+						autoLayout lr
+					}
+					dynamic * {}
+				}
+			}`,
+			want: &Workspace{
+				Views: &Views{
+					DynamicViews: []*DynamicView{
+						{
+							ScopeId: "a",
+							baseView: baseView{
+								Key:         "flow",
+								Description: "signing in",
+								AutoLayout:  &AutoLayout{Direction: "lr", RankSeparation: 300, NodeSeparation: 300},
+							},
+							Steps: []*DynamicStep{
+								{Order: "1", SourceId: "u", DestinationId: "a.web", Description: "submits"},
+								{Order: "2", SourceId: "web", DestinationId: "api", Description: "checks"},
+								{Order: "3", SourceId: "api", DestinationId: "db"},
+								{Order: "2", SourceId: "web", DestinationId: "cache", Description: "looks up", Technology: "redis"},
+								{Order: "4", SourceId: "web", DestinationId: "u"},
+								{Order: "7", SourceId: "u", DestinationId: "web"},
+								{Order: "7.1", SourceId: "web", DestinationId: "api"},
+								{Order: "8", SourceId: "web", DestinationId: "db"},
+							},
+						},
+						{},
+					},
+				},
+			},
+		},
+		{
+			name: "dynamic step without destination",
+			input: `workspace {
+				views {
+					dynamic * {
+						a ->
+					}
+				}
+			}`,
+			wantErr: true,
+		},
 		{
 			name:    "multiline unacceptable description",
 			input:   "workspace 'foo' {\nproperties {\n'key' `values are\nnot allowed to be\nmulti-line`\n}\n}",
@@ -390,3 +791,321 @@ func TestParseInclude(t *testing.T) {
 	}
 
 }
+
+func TestParsePragmas(t *testing.T) {
+	sources := map[string]string{
+		"main.c4": `
+			workspace {
+				model {
+					#include 'people.c4'
+					#include? 'missing.c4'
+					#fetch 'https://example.com/systems.c4'
+					#fetch? 'https://example.com/missing.c4'
+				}
+			}
+		`,
+		"people.c4":                      `a = person 'a'`,
+		"https://example.com/systems.c4": `b = softwareSystem 'b'`,
+		"broken.c4": `
+			workspace {
+				model {
+					#include 'missing.c4'
+					#include 'https://example.com/systems.c4'
+					#fetch 'people.c4'
+					#include
+					c = person 'c'
+				}
+			}
+		`,
+	}
+
+	p := new(Parser)
+	deps := &mockDependencies{l: new(lexer.Lexer), sources: sources}
+	got, err := p.Run("main.c4", deps)
+	if err != nil {
+		t.Fatalf("Parser.Run() error = %v", err)
+	}
+	for _, id := range []IdentifierString{"a", "b"} {
+		if _, has := got.Model.NamedEntities[id]; !has {
+			t.Errorf("expected %s to be included", id)
+		}
+	}
+	if warnings := p.Warnings(); len(warnings) != 2 {
+		t.Errorf("expected a warning for each optional pragma left out, got %v", warnings)
+	}
+
+	_, err = p.Run("broken.c4", deps)
+	var errs Errors
+	if !errors.As(err, &errs) {
+		t.Fatalf("expected a list of errors, got %v", err)
+	}
+	var lines []int
+	for _, err := range errs {
+		var ce interface{ TokenAtError() *lexer.Token }
+		if errors.As(err, &ce) {
+			lines = append(lines, ce.TokenAtError().Positions().Start.Line)
+		}
+	}
+	if want := []int{4, 5, 6, 7}; !reflect.DeepEqual(lines, want) {
+		t.Errorf("got errors on lines %v, want %v:\n%s", lines, want, err)
+	}
+}
+
+func TestParseIncludeChain(t *testing.T) {
+	sources := map[string]string{
+		"main.c4": `
+			workspace {
+				model {
+					#include 'common.c4'
+				}
+			}
+		`,
+		"common.c4": `
+			a = person 'a'
+			#include 'main.c4'
+		`,
+		"deep.c4": `
+			workspace {
+				model {
+					#include 'one.c4'
+				}
+			}
+		`,
+		"alias.c4": `
+			workspace {
+				model {
+					#include 'other.c4'
+				}
+			}
+		`,
+		"other.c4": `#include 'file:///alias.c4'`,
+		"one.c4":   `#include 'two.c4'`,
+		"two.c4":   `#include 'three.c4'`,
+		"three.c4": `b = person 'b'`,
+	}
+
+	tests := []struct {
+		name     string
+		target   string
+		maxDepth int
+		want     string
+		wantAt   lexer.Position
+	}{
+		{
+			name:   "cycle",
+			target: "main.c4",
+			want:   "include cycle: main.c4 -> common.c4 -> main.c4",
+			wantAt: lexer.Position{File: "common.c4", Line: 3, Column: 3, ByteOffset: 22},
+		},
+		{
+			name:   "cycle through another name",
+			target: "alias.c4",
+			want:   "include cycle: alias.c4 -> other.c4 -> file:///alias.c4",
+			wantAt: lexer.Position{File: "other.c4", Line: 1, Column: 0, ByteOffset: 0},
+		},
+		{
+			name:     "too deep",
+			target:   "deep.c4",
+			maxDepth: 2,
+			want:     "includes are nested more than 2 deep: deep.c4 -> one.c4 -> two.c4 -> three.c4",
+			wantAt:   lexer.Position{File: "two.c4", Line: 1, Column: 0, ByteOffset: 0},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			p := &Parser{MaxIncludeDepth: tt.maxDepth}
+			_, err := p.Run(tt.target, &mockDependencies{l: new(lexer.Lexer), sources: sources})
+
+			var ce *CodeError
+			if !errors.As(err, &ce) {
+				t.Fatalf("expected an error at a token, got %v", err)
+			}
+			if ce.Unwrap().Error() != tt.want {
+				t.Errorf("got error %q, want %q", ce.Unwrap(), tt.want)
+			}
+			if got := ce.TokenAtError().Positions().Start; got != tt.wantAt {
+				t.Errorf("got error at %+v, want %+v", got, tt.wantAt)
+			}
+		})
+	}
+
+	// includes nested right up to the limit are fine
+	p := &Parser{MaxIncludeDepth: 3}
+	if _, err := p.Run("deep.c4", &mockDependencies{l: new(lexer.Lexer), sources: sources}); err != nil {
+		t.Errorf("unexpected error including to the maximum depth: %s", err)
+	}
+}
+
+func TestParseRecovery(t *testing.T) {
+	sources := map[string]string{
+		"main.c4": `
+			workspace {
+				model {
+					a = person {
+						description 'no name'
+					}
+					b = softwareSystem 'b' {
+						c = container 'c' {
+							url
+						}
+						d = container 'd'
+					}
+					a -> "b"
+					e = person 'e'
+				}
+				views {
+					systemContext b {
+						include *
+						autoLayout sideways
+					}
+					container
+				}
+			}
+		`,
+		"prelude.c4": `#fetch 'file:x' space {}`,
+		"styles.c4": `
+			styles {
+				element 'a' {
+					shape blob
+					color #fff
+				}
+				element 'b' {
+					border
+				}
+			}
+		`,
+	}
+
+	tests := []struct {
+		name   string
+		run    func(p *Parser, deps Provider) error
+		target string
+		want   []int
+	}{
+		{
+			name: "workspace",
+			run: func(p *Parser, deps Provider) error {
+				_, err := p.Run("main.c4", deps)
+				return err
+			},
+			target: "main.c4",
+			want:   []int{4, 9, 13, 19, 21},
+		},
+		{
+			name: "before the workspace",
+			run: func(p *Parser, deps Provider) error {
+				_, err := p.Run("prelude.c4", deps)
+				return err
+			},
+			target: "prelude.c4",
+			want:   []int{1, 1},
+		},
+		{
+			name: "theme",
+			run: func(p *Parser, deps Provider) error {
+				_, err := p.RunTheme("styles.c4", deps)
+				return err
+			},
+			target: "styles.c4",
+			want:   []int{4, 5, 8},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := tt.run(new(Parser), &mockDependencies{l: new(lexer.Lexer), sources: sources})
+
+			var errs Errors
+			if !errors.As(err, &errs) {
+				t.Fatalf("expected a list of errors, got %v", err)
+			}
+
+			var lines []int
+			for _, err := range errs {
+				var ce interface{ TokenAtError() *lexer.Token }
+				if !errors.As(err, &ce) {
+					t.Errorf("error isn't at a token: %s", err)
+					continue
+				}
+				lines = append(lines, ce.TokenAtError().Positions().Start.Line)
+			}
+			if !reflect.DeepEqual(lines, tt.want) {
+				t.Errorf("got errors on lines %v, want %v:\n%s", lines, tt.want, err)
+			}
+		})
+	}
+}
+
+func TestStyles_ElementStyle(t *testing.T) {
+	styles := &Styles{
+		Elements: []*ElementStyle{
+			{Tag: "Element", Shape: ShapeRoundedBox, Background: "#ffffff"},
+			{Tag: "Database", Shape: ShapeCylinder},
+			{Tag: "Container", Background: "#438dd5", Color: "#ffffff"},
+		},
+	}
+
+	container := &Container{baseEntity{Tags: []string{"Database"}}}
+	got := styles.ElementStyle(TagsOf(container))
+	want := &ElementStyle{Shape: ShapeCylinder, Background: "#438dd5", Color: "#ffffff"}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("got style %+v, want %+v", got, want)
+	}
+
+	instance := &ContainerInstance{Container: container}
+	if got := TagsOf(instance); !reflect.DeepEqual(got, []string{"Element", "Container", "Database", "Container Instance"}) {
+		t.Errorf("got instance tags %v", got)
+	}
+}
+
+func TestParseStructurizrTheme(t *testing.T) {
+	tests := []struct {
+		name    string
+		input   string
+		wantErr bool
+	}{
+		{
+			name:  "valid",
+			input: `{"elements": [{"tag": "Database", "shape": "Cylinder", "background": "#1168BD"}], "relationships": [{"tag": "async", "dashed": true}]}`,
+		},
+		{name: "not json", input: `styles {}`, wantErr: true},
+		{name: "missing tag", input: `{"elements": [{"shape": "Box"}]}`, wantErr: true},
+		{name: "bad shape", input: `{"elements": [{"tag": "x", "shape": "Blob"}]}`, wantErr: true},
+		{name: "bad colour", input: `{"relationships": [{"tag": "x", "color": "red-ish"}]}`, wantErr: true},
+		{name: "bad range", input: `{"elements": [{"tag": "x", "strokeWidth": 11}]}`, wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := ParseStructurizrTheme([]byte(tt.input))
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("ParseStructurizrTheme() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if err != nil {
+				t.Log(err)
+			}
+		})
+	}
+}
+
+func TestMultilineString(t *testing.T) {
+	tests := []struct {
+		name  string
+		input string
+		want  string
+	}{
+		{name: "single line", input: `'a string'`, want: "a string"},
+		{name: "first line inline", input: "`first\n\t\tsecond\n\t\tthird`", want: "first\nsecond\nthird"},
+		{name: "indented", input: "`\n\t\tfirst\n\t\t  second\n\t\tthird\n\t`", want: "first\n  second\nthird"},
+		{name: "closing quote indented", input: "`\n        first\n        second\n    `", want: "first\nsecond"},
+		{name: "no indent", input: "`\n\nfirst\nsecond`", want: "first\nsecond"},
+		{name: "empty", input: "`\n\t\n`", want: ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := MultilineString(tt.input); got != tt.want {
+				t.Errorf("got %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
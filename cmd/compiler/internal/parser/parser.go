@@ -3,13 +3,22 @@ package parser
 import (
 	"bytes"
 	"fmt"
+	"io"
 	"path"
 	"strings"
+	"unicode"
 
 	"go.burian.dev/c4/cmd/compiler/internal/lexer"
 )
 
+// DefaultMaxIncludeDepth is how deeply includes may be nested, unless a
+// parser is given its own limit
+const DefaultMaxIncludeDepth = 32
+
 type Parser struct {
+	// how deeply #include and #fetch may be nested, or the default if 0
+	MaxIncludeDepth int
+
 	code *bytes.Reader
 
 	currentToken  *lexer.Token
@@ -23,13 +32,20 @@ type Parser struct {
 
 	provider Provider
 
-	tokenStreamStack   []*streamState
-	currentTokenStream lexer.TokenStream
-}
+	declarations map[any]*lexer.Token
 
-type streamState struct {
-	stream   lexer.TokenStream
-	previous *lexer.Token
+	// streams with an #include or #fetch in progress, and the names of the
+	// files being read, from the target down to the current stream
+	tokenStreamStack   []lexer.TokenStream
+	currentTokenStream lexer.TokenStream
+	includes           []string
+
+	// how many blocks the tokens read so far are in
+	depth int
+	// errors in statements that were skipped to carry on parsing
+	errs Errors
+	// problems that didn't stop parsing, like optional includes left out
+	warnings Errors
 }
 
 type Provider interface {
@@ -38,25 +54,65 @@ type Provider interface {
 }
 
 func (p *Parser) Run(target string, deps Provider) (*Workspace, error) {
+	if err := p.begin(target, deps); err != nil {
+		return nil, err
+	}
+	return p.runParse()
+}
+
+// RunTheme parses a theme, which is either a file of nothing but styles
+// blocks, or a JSON theme from the original DSL's tooling
+func (p *Parser) RunTheme(target string, deps Provider) (*Styles, error) {
+	data, err := deps.GetSourceFor(target)
+	if err != nil {
+		return nil, err
+	}
+
+	var first [1]byte
+	for {
+		if _, err := data.Read(first[:]); err != nil || !unicode.IsSpace(rune(first[0])) {
+			break
+		}
+	}
+	if first[0] == '{' {
+		json, err := io.ReadAll(io.NewSectionReader(data, 0, data.Size()))
+		if err != nil {
+			return nil, err
+		}
+		return ParseStructurizrTheme(json)
+	}
+
+	if err := p.begin(target, deps); err != nil {
+		return nil, err
+	}
+	return p.runParseStyles()
+}
+
+func (p *Parser) begin(target string, deps Provider) error {
 
 	p.provider = deps
 
 	tokens, err := deps.GetTokenStreamFor(target)
 	if err != nil {
-		return nil, err
+		return err
 	}
 	p.currentTokenStream = tokens
+	p.tokenStreamStack = nil
+	p.includes = []string{target}
 
 	data, err := deps.GetSourceFor(target)
 	if err != nil {
-		return nil, err
+		return err
 	}
 	p.code = data
 	p.currentFile = target
 
 	p.currentToken = &lexer.Token{}
+	p.depth = 0
+	p.errs = nil
+	p.warnings = nil
 
-	return p.runParse()
+	return nil
 }
 
 func (p *Parser) currentSymbol() string {
@@ -92,53 +148,100 @@ func (p *Parser) nextToken() *lexer.Token {
 	p.previousToken = p.currentToken
 	p.currentToken = p.currentTokenStream.NextToken()
 
-	// pragma directives are trapped by the parser
-	// and not returned to the model
-	if p.currentToken.Is(lexer.TypePragma) {
-		switch p.currentSymbol() {
-		case "#include":
-			oldPrev := p.previousToken
-			file, err := p.parseString()
-			if err != nil {
-				panic("failed to process #include pragma: need file argument")
-			}
-
-			// load the new inlcuded stream
-			newStream, err := p.provider.GetTokenStreamFor(file)
-			if err != nil {
-				panic("could not fetch named token stream to include: " + err.Error())
-			}
-
-			newNext := newStream.NextToken()
-
-			// push the current token source onto the stack
-			ss := &streamState{
-				stream:   p.currentTokenStream,
-				previous: oldPrev,
-			}
-			p.tokenStreamStack = append(p.tokenStreamStack, ss)
-
-			p.currentTokenStream = newStream
-			p.currentToken = newNext
-			return p.currentToken
+	for {
+		// pragma directives are trapped by the parser
+		// and not returned to the model
+		if p.currentToken.Is(lexer.TypePragma) && p.pragma() {
+			continue
+		}
+		if p.currentToken.Is(lexer.TypeEOF) && len(p.tokenStreamStack) > 0 {
+			p.currentTokenStream = p.tokenStreamStack[len(p.tokenStreamStack)-1]
+			p.tokenStreamStack = p.tokenStreamStack[:len(p.tokenStreamStack)-1]
+			p.includes = p.includes[:len(p.includes)-1]
+			p.currentToken = p.currentTokenStream.NextToken()
+			continue
 		}
+		break
 	}
 
-	for p.currentToken.Is(lexer.TypeEOF) && len(p.tokenStreamStack) > 0 {
-		popState := p.tokenStreamStack[len(p.tokenStreamStack)-1]
-		p.currentTokenStream = popState.stream
-		p.currentToken = p.currentTokenStream.NextToken()
-		p.previousToken = popState.previous
-		p.tokenStreamStack = p.tokenStreamStack[:len(p.tokenStreamStack)-1]
+	p.enterOrLeaveBlock(p.currentToken, 1)
+	return p.currentToken
+}
+
+// keeps track of the depth of blocks as tokens are read, or unread with a
+// direction of -1
+func (p *Parser) enterOrLeaveBlock(t *lexer.Token, direction int) {
+	switch {
+	case t.Is(lexer.TypeStartBlock):
+		p.depth += direction
+	case t.Is(lexer.TypeEndBlock):
+		p.depth -= direction
 	}
+}
 
-	return p.currentToken
+// statement parses one statement in a block. If it fails, the error is
+// recorded and the rest of the statement is skipped, up to its terminator or
+// the end of its block, so the statements after it are still parsed. The
+// error is only returned if the file ends before the statement does, since
+// then there's nothing left to carry on with.
+func (p *Parser) statement(parse func() error) error {
+	depth, held := p.depth, len(p.heldIds)
+
+	err := parse()
+	if err == nil {
+		return nil
+	}
+
+	// identifiers held by the statement will never be claimed
+	if len(p.heldIds) > held {
+		p.heldIds = p.heldIds[:held]
+	}
+
+	for {
+		tok := p.nextToken()
+		if tok.Is(lexer.TypeEOF) {
+			p.backupToken()
+			return err
+		}
+		// the end of the block the statement is in
+		if p.depth < depth {
+			p.backupToken()
+			break
+		}
+		if p.depth == depth && tok.Is(lexer.TypeTerminator, lexer.TypeEndBlock) {
+			break
+		}
+	}
+
+	p.errs = append(p.errs, err)
+	return nil
+}
+
+// records the current token as the declaration site of x
+func (p *Parser) declare(x any) {
+	if p.declarations == nil {
+		p.declarations = make(map[any]*lexer.Token)
+	}
+	p.declarations[x] = p.currentToken
+}
+
+// Warnings returns the problems found in the last run that weren't bad
+// enough to be errors
+func (p *Parser) Warnings() []error {
+	return p.warnings
+}
+
+// DeclarationOf returns the token at which the given entity, relationship,
+// or other parsed object was declared, or nil if it is unknown
+func (p *Parser) DeclarationOf(x any) *lexer.Token {
+	return p.declarations[x]
 }
 
 func (p *Parser) backupToken() {
 	if p.previousToken == nil {
 		panic("attempt to double backup tokens")
 	}
+	p.enterOrLeaveBlock(p.currentToken, -1)
 	p.currentToken = p.previousToken
 	p.previousToken = nil
 	p.currentTokenStream.BackupToken()
@@ -168,10 +271,11 @@ func (p *Parser) claimHeldIdentifier() IdentifierString {
 
 func (p *Parser) assignIdentifier(e Entity) {
 	if len(p.heldIds) > 0 {
-		id := p.heldIds[len(p.heldIds)-1]
-		p.heldIds = p.heldIds[:len(p.heldIds)-1]
-		e.SetId(id)
-		return
+		id := p.claimHeldIdentifier()
+		if id != "" {
+			e.SetId(id)
+			return
+		}
 	}
 
 	typeName := ""
@@ -189,11 +293,28 @@ func (p *Parser) assignIdentifier(e Entity) {
 	case *Component:
 		typeName = "component"
 		fallback = obj.Name
+	case *DeploymentEnvironment:
+		typeName = "deploymentenvironment"
+		fallback = obj.Name
+	case *DeploymentNode:
+		typeName = "deploymentnode"
+		fallback = obj.Name
+	case *InfrastructureNode:
+		typeName = "infrastructurenode"
+		fallback = obj.Name
+	case *ContainerInstance:
+		typeName = "containerinstance"
+		fallback = string(obj.ContainerId)
+	case *SoftwareSystemInstance:
+		typeName = "softwaresysteminstance"
+		fallback = string(obj.SoftwareSystemId)
 	}
+	// names like 'eu-west-1.example.com' would otherwise look like
+	// hierarchical references
 	id := fmt.Sprintf("_%s%02d_%s", typeName, p.currentUniqueId,
 		strings.ToLower(
 			strings.TrimSpace(
-				strings.ReplaceAll(fallback, " ", "_"),
+				strings.NewReplacer(" ", "_", ".", "_").Replace(fallback),
 			),
 		),
 	)
@@ -1 +1,128 @@
 package parser
+
+import (
+	"fmt"
+	"net/url"
+	"strings"
+
+	"go.burian.dev/c4/cmd/compiler/internal/lexer"
+	"go.burian.dev/c4/cmd/compiler/internal/loader"
+)
+
+const (
+	pragmaInclude         = "#include"
+	pragmaIncludeOptional = "#include?"
+	pragmaFetch           = "#fetch"
+	pragmaFetchOptional   = "#fetch?"
+)
+
+// pragma handles the pragma at the current token. The token after it, or
+// the first token of whatever it included, is left as the current token.
+// Unknown pragmas aren't handled, and are left for the model to find
+// unexpected.
+//
+// Problems are recorded rather than returned, and parsing carries on from
+// after the pragma, since nothing in the model was expecting it anyway.
+func (p *Parser) pragma() bool {
+	pragma := p.currentToken
+	name := p.currentSymbol()
+	switch name {
+	case pragmaInclude, pragmaIncludeOptional, pragmaFetch, pragmaFetchOptional:
+	default:
+		return false
+	}
+
+	p.currentToken = p.currentTokenStream.NextToken()
+	if !p.currentToken.Is(lexer.TypeString) {
+		p.errs = append(p.errs, ErrorForToken(pragma, fmt.Errorf("%s needs a string of what to load", name)))
+		return true
+	}
+	argument := p.currentToken
+	target := p.currentSymbol()
+
+	// after a problem, parsing carries on from the token after the argument
+	fail := func(err error, optional bool) {
+		if optional {
+			p.warnings = append(p.warnings, ErrorForToken(argument, err))
+		} else {
+			p.errs = append(p.errs, ErrorForToken(argument, err))
+		}
+		p.currentToken = p.currentTokenStream.NextToken()
+	}
+
+	if strings.ContainsRune(target, '\n') {
+		fail(fmt.Errorf("multiline string not allowed in this context"), false)
+		return true
+	}
+	target = cleanString(target)
+
+	if err := checkPragmaTarget(name, target); err != nil {
+		fail(err, false)
+		return true
+	}
+
+	if err := p.checkIncludeChain(target); err != nil {
+		p.errs = append(p.errs, ErrorForToken(pragma, err))
+		p.currentToken = p.currentTokenStream.NextToken()
+		return true
+	}
+
+	// a source that can't be had is only a problem if it wasn't optional,
+	// but one that's there must still make sense
+	if _, err := p.provider.GetSourceFor(target); err != nil {
+		fail(fmt.Errorf("error loading %s:\n> %w", target, err), strings.HasSuffix(name, "?"))
+		return true
+	}
+	stream, err := p.provider.GetTokenStreamFor(target)
+	if err != nil {
+		fail(fmt.Errorf("error lexing %s:\n> %w", target, err), false)
+		return true
+	}
+
+	p.tokenStreamStack = append(p.tokenStreamStack, p.currentTokenStream)
+	p.includes = append(p.includes, target)
+	p.currentTokenStream = stream
+	p.currentToken = stream.NextToken()
+	return true
+}
+
+// checkPragmaTarget makes sure fetches are of sources on the web, and
+// includes are of anything else, like local files or other schemes the
+// loader handles
+func checkPragmaTarget(pragma, target string) error {
+	u, err := url.Parse(target)
+	web := err == nil && (strings.EqualFold(u.Scheme, "https") || strings.EqualFold(u.Scheme, "http"))
+
+	switch pragma {
+	case pragmaInclude, pragmaIncludeOptional:
+		if web {
+			return fmt.Errorf("%s doesn't fetch from the web, use #fetch for %s", pragma, target)
+		}
+	case pragmaFetch, pragmaFetchOptional:
+		if !web {
+			return fmt.Errorf("%s needs an https:// URL, use #include for other sources", pragma)
+		}
+	}
+	return nil
+}
+
+// checkIncludeChain makes sure including the target won't include a file in
+// itself, or nest includes too deeply. Sources are compared by the name the
+// loader knows them by, however their URIs are written.
+func (p *Parser) checkIncludeChain(target string) error {
+	chain := strings.Join(append(append([]string{}, p.includes...), target), " -> ")
+	for _, name := range p.includes {
+		if loader.Canonical(name) == loader.Canonical(target) {
+			return fmt.Errorf("include cycle: %s", chain)
+		}
+	}
+
+	max := p.MaxIncludeDepth
+	if max == 0 {
+		max = DefaultMaxIncludeDepth
+	}
+	if len(p.includes) > max {
+		return fmt.Errorf("includes are nested more than %d deep: %s", max, chain)
+	}
+	return nil
+}
@@ -20,7 +20,8 @@ func (p *Parser) acceptIdentifierString() bool {
 	str := strings.Map(func(r rune) rune {
 		if (r >= 'a' && r <= 'z') ||
 			(r >= 'A' && r <= 'Z') ||
-			r == '_' || r == '-' {
+			(r >= '0' && r <= '9') ||
+			r == '_' || r == '-' || r == '.' {
 			return r
 		}
 		cut = true
@@ -44,10 +45,10 @@ func (p *Parser) parseString() (string, error) {
 	if strings.ContainsRune(str, '\n') {
 		return "", ErrorForToken(p.currentToken, fmt.Errorf("multiline string not allowed in this context"))
 	}
-	return p.cleanString(str), nil
+	return cleanString(str), nil
 }
 
-func (p *Parser) cleanString(s string) string {
+func cleanString(s string) string {
 	s = strings.Trim(s, "\"'`\n\t\r")
 	return s
 }
@@ -76,10 +77,15 @@ func (p *Parser) parseOneOrMoreLineString() (string, error) {
 	if !p.acceptOne(lexer.TypeString) {
 		return "", p.errExpectedNext().Tokens(lexer.TypeString)
 	}
-	str := string(p.currentSymbol())
+	return MultilineString(p.currentSymbol()), nil
+}
+
+// MultilineString gives the value of a quoted string that may run over
+// several lines, with the indentation common to its lines removed
+func MultilineString(str string) string {
 	lines := strings.Split(str, "\n")
 	if len(lines) < 2 {
-		return str, nil
+		return cleanString(str)
 	}
 
 	notSpace := func(r rune) bool { return !unicode.IsSpace(r) }
@@ -95,7 +101,7 @@ func (p *Parser) parseOneOrMoreLineString() (string, error) {
 
 	if firstCharacterLine < 0 {
 		// there are none, it's a giant empty string?
-		return "", nil
+		return ""
 	}
 
 	// now find the first one that has a whitespace prefix
@@ -125,16 +131,16 @@ func (p *Parser) parseOneOrMoreLineString() (string, error) {
 	// if there is none, just quit
 	if prefixLine == -1 {
 		// but at least drop the first empty lines
-		return strings.Join(lines[firstCharacterLine:], "\n"), nil
+		return cleanString(strings.Join(lines[firstCharacterLine:], "\n"))
 	}
 
 	// chop the prefix off everything
 	prefix := lines[prefixLine][:prefixIndex]
-	for i := range lines[firstCharacterLine:] {
+	for i := firstCharacterLine; i < len(lines); i++ {
 		lines[i] = strings.TrimPrefix(lines[i], prefix)
 	}
 
-	// join it all back together
+	// join it all back together, without the indentation of a closing quote
 	str = strings.Join(lines[firstCharacterLine:], "\n")
-	return p.cleanString(str), nil
+	return strings.TrimRightFunc(cleanString(str), unicode.IsSpace)
 }
@@ -0,0 +1,613 @@
+package parser
+
+import (
+	"fmt"
+	"strings"
+
+	"go.burian.dev/c4/cmd/compiler/internal/lexer"
+)
+
+// Styles are applied to elements and relationships by their tags. Where
+// several styles apply to one element, the styles of later tags win.
+type Styles struct {
+	Elements      []*ElementStyle      `json:"elements,omitempty"`
+	Relationships []*RelationshipStyle `json:"relationships,omitempty"`
+
+	// sources of styles to be merged beneath these ones by the checker
+	Themes []string `json:"themes,omitempty"`
+}
+
+type ElementStyle struct {
+	Tag string `json:"tag"`
+
+	Shape       string `json:"shape,omitempty"`
+	Icon        string `json:"icon,omitempty"`
+	Width       int    `json:"width,omitempty"`
+	Height      int    `json:"height,omitempty"`
+	Background  string `json:"background,omitempty"`
+	Color       string `json:"color,omitempty"`
+	Stroke      string `json:"stroke,omitempty"`
+	StrokeWidth int    `json:"stroke_width,omitempty"`
+	FontSize    int    `json:"font_size,omitempty"`
+	Border      string `json:"border,omitempty"`
+	Opacity     *int   `json:"opacity,omitempty"`
+	Metadata    *bool  `json:"metadata,omitempty"`
+	Description *bool  `json:"description,omitempty"`
+}
+
+type RelationshipStyle struct {
+	Tag string `json:"tag"`
+
+	Thickness int    `json:"thickness,omitempty"`
+	Color     string `json:"color,omitempty"`
+	Dashed    *bool  `json:"dashed,omitempty"`
+	Routing   string `json:"routing,omitempty"`
+	FontSize  int    `json:"font_size,omitempty"`
+	Width     int    `json:"width,omitempty"`
+	Position  *int   `json:"position,omitempty"`
+	Opacity   *int   `json:"opacity,omitempty"`
+}
+
+const (
+	ShapeBox                   = "Box"
+	ShapeRoundedBox            = "RoundedBox"
+	ShapeCircle                = "Circle"
+	ShapeEllipse               = "Ellipse"
+	ShapeHexagon               = "Hexagon"
+	ShapeDiamond               = "Diamond"
+	ShapeCylinder              = "Cylinder"
+	ShapeBucket                = "Bucket"
+	ShapePipe                  = "Pipe"
+	ShapePerson                = "Person"
+	ShapeRobot                 = "Robot"
+	ShapeFolder                = "Folder"
+	ShapeWebBrowser            = "WebBrowser"
+	ShapeWindow                = "Window"
+	ShapeTerminal              = "Terminal"
+	ShapeShell                 = "Shell"
+	ShapeMobileDevicePortrait  = "MobileDevicePortrait"
+	ShapeMobileDeviceLandscape = "MobileDeviceLandscape"
+	ShapeComponent             = "Component"
+)
+
+var knownShapes = []string{
+	ShapeBox, ShapeRoundedBox, ShapeCircle, ShapeEllipse, ShapeHexagon,
+	ShapeDiamond, ShapeCylinder, ShapeBucket, ShapePipe, ShapePerson,
+	ShapeRobot, ShapeFolder, ShapeWebBrowser, ShapeWindow, ShapeTerminal,
+	ShapeShell, ShapeMobileDevicePortrait, ShapeMobileDeviceLandscape,
+	ShapeComponent,
+}
+
+const (
+	BorderSolid  = "solid"
+	BorderDashed = "dashed"
+	BorderDotted = "dotted"
+
+	RoutingDirect     = "direct"
+	RoutingOrthogonal = "orthogonal"
+	RoutingCurved     = "curved"
+)
+
+// the basic CSS colours, which may be used by name
+var namedColours = map[string]string{
+	"black":   "#000000",
+	"silver":  "#c0c0c0",
+	"gray":    "#808080",
+	"grey":    "#808080",
+	"white":   "#ffffff",
+	"maroon":  "#800000",
+	"red":     "#ff0000",
+	"purple":  "#800080",
+	"fuchsia": "#ff00ff",
+	"green":   "#008000",
+	"lime":    "#00ff00",
+	"olive":   "#808000",
+	"yellow":  "#ffff00",
+	"navy":    "#000080",
+	"blue":    "#0000ff",
+	"teal":    "#008080",
+	"aqua":    "#00ffff",
+	"orange":  "#ffa500",
+}
+
+// TagsOf returns the tags styles are applied to an entity or relationship
+// by, in order of precedence: the implicit tags of its type, then its own.
+// Instances are styled as what they deploy, and then by their own tags.
+func TagsOf(x any) []string {
+	var tags []string
+	switch e := x.(type) {
+	case *Person:
+		tags = []string{"Element", "Person"}
+	case *SoftwareSystem:
+		tags = []string{"Element", "Software System"}
+	case *Container:
+		tags = []string{"Element", "Container"}
+	case *Component:
+		tags = []string{"Element", "Component"}
+	case *DeploymentNode:
+		tags = []string{"Element", "Deployment Node"}
+	case *InfrastructureNode:
+		tags = []string{"Element", "Infrastructure Node"}
+	case *ContainerInstance:
+		if e.Container != nil {
+			tags = TagsOf(e.Container)
+		}
+		tags = append(tags, "Container Instance")
+	case *SoftwareSystemInstance:
+		if e.SoftwareSystem != nil {
+			tags = TagsOf(e.SoftwareSystem)
+		}
+		tags = append(tags, "Software System Instance")
+	case *Relationship:
+		tags = []string{"Relationship"}
+	}
+
+	if b, ok := x.(interface{ Base() *BaseEntity }); ok {
+		tags = append(tags, b.Base().Tags...)
+	}
+	return tags
+}
+
+// ElementStyle combines every element style for the given tags, with later
+// tags taking precedence
+func (s *Styles) ElementStyle(tags []string) *ElementStyle {
+	merged := new(ElementStyle)
+	if s == nil {
+		return merged
+	}
+	for _, tag := range tags {
+		for _, style := range s.Elements {
+			if style.Tag == tag {
+				merged.overlay(style)
+			}
+		}
+	}
+	return merged
+}
+
+// RelationshipStyle combines every relationship style for the given tags,
+// with later tags taking precedence
+func (s *Styles) RelationshipStyle(tags []string) *RelationshipStyle {
+	merged := new(RelationshipStyle)
+	if s == nil {
+		return merged
+	}
+	for _, tag := range tags {
+		for _, style := range s.Relationships {
+			if style.Tag == tag {
+				merged.overlay(style)
+			}
+		}
+	}
+	return merged
+}
+
+// Merge layers the other styles over these ones. Styles for a tag that
+// already has one are combined, with the other style's properties winning.
+func (s *Styles) Merge(o *Styles) {
+	for _, style := range o.Elements {
+		merged := false
+		for _, existing := range s.Elements {
+			if existing.Tag == style.Tag {
+				existing.overlay(style)
+				merged = true
+				break
+			}
+		}
+		if !merged {
+			copied := *style
+			s.Elements = append(s.Elements, &copied)
+		}
+	}
+
+	for _, style := range o.Relationships {
+		merged := false
+		for _, existing := range s.Relationships {
+			if existing.Tag == style.Tag {
+				existing.overlay(style)
+				merged = true
+				break
+			}
+		}
+		if !merged {
+			copied := *style
+			s.Relationships = append(s.Relationships, &copied)
+		}
+	}
+}
+
+// sets every property the other style sets
+func (s *ElementStyle) overlay(o *ElementStyle) {
+	overlayString(&s.Shape, o.Shape)
+	overlayString(&s.Icon, o.Icon)
+	overlayInt(&s.Width, o.Width)
+	overlayInt(&s.Height, o.Height)
+	overlayString(&s.Background, o.Background)
+	overlayString(&s.Color, o.Color)
+	overlayString(&s.Stroke, o.Stroke)
+	overlayInt(&s.StrokeWidth, o.StrokeWidth)
+	overlayInt(&s.FontSize, o.FontSize)
+	overlayString(&s.Border, o.Border)
+	if o.Opacity != nil {
+		s.Opacity = o.Opacity
+	}
+	if o.Metadata != nil {
+		s.Metadata = o.Metadata
+	}
+	if o.Description != nil {
+		s.Description = o.Description
+	}
+}
+
+// sets every property the other style sets
+func (s *RelationshipStyle) overlay(o *RelationshipStyle) {
+	overlayInt(&s.Thickness, o.Thickness)
+	overlayString(&s.Color, o.Color)
+	if o.Dashed != nil {
+		s.Dashed = o.Dashed
+	}
+	overlayString(&s.Routing, o.Routing)
+	overlayInt(&s.FontSize, o.FontSize)
+	overlayInt(&s.Width, o.Width)
+	if o.Position != nil {
+		s.Position = o.Position
+	}
+	if o.Opacity != nil {
+		s.Opacity = o.Opacity
+	}
+}
+
+func overlayString(s *string, o string) {
+	if o != "" {
+		*s = o
+	}
+}
+
+func overlayInt(i *int, o int) {
+	if o != 0 {
+		*i = o
+	}
+}
+
+// parses `styles { element 'tag' { ... } relationship 'tag' { ... } }`
+func (p *Parser) parseStyles(s *Styles) error {
+	if !p.acceptOne(lexer.TypeStartBlock) {
+		return p.errExpectedNext().Tokens(lexer.TypeStartBlock)
+	}
+
+	expectedKeywords := []Keyword{KeywordElement, KeywordRelationship, KeywordTheme, KeywordThemes}
+
+	for {
+		if p.acceptOne(lexer.TypeEndBlock) {
+			return nil
+		}
+
+		if p.acceptOne(lexer.TypeTerminator) {
+			continue
+		}
+
+		err := p.statement(func() error {
+			if !p.acceptOne(lexer.TypeKeyword) {
+				return p.errExpectedNext().Tokens(lexer.TypeEndBlock).Keywords(expectedKeywords...)
+			}
+
+			switch p.currentKeyword() {
+
+			case KeywordElement:
+				style := new(ElementStyle)
+				p.declare(style)
+				if err := p.parseElementStyle(style); err != nil {
+					return fmt.Errorf("error parsing element style:\n> %w", err)
+				}
+				for _, existing := range s.Elements {
+					if existing.Tag == style.Tag {
+						return ErrorForToken(p.DeclarationOf(style), fmt.Errorf("illegal redefinition of element style %s", style.Tag))
+					}
+				}
+				s.Elements = append(s.Elements, style)
+
+			case KeywordRelationship:
+				style := new(RelationshipStyle)
+				p.declare(style)
+				if err := p.parseRelationshipStyle(style); err != nil {
+					return fmt.Errorf("error parsing relationship style:\n> %w", err)
+				}
+				for _, existing := range s.Relationships {
+					if existing.Tag == style.Tag {
+						return ErrorForToken(p.DeclarationOf(style), fmt.Errorf("illegal redefinition of relationship style %s", style.Tag))
+					}
+				}
+				s.Relationships = append(s.Relationships, style)
+
+			case KeywordTheme, KeywordThemes:
+				if err := p.parseThemes(s); err != nil {
+					return fmt.Errorf("error parsing themes:\n> %w", err)
+				}
+
+			default:
+				return p.errExpectedCurrent().Tokens(lexer.TypeEndBlock).Keywords(expectedKeywords...)
+			}
+			return nil
+		})
+		if err != nil {
+			return err
+		}
+	}
+}
+
+func (p *Parser) parseElementStyle(s *ElementStyle) error {
+	var err error
+	if s.Tag, err = p.parseString(); err != nil {
+		return fmt.Errorf("error parsing style tag:\n> %w", err)
+	}
+
+	return p.parseStyleProperties(func(name string) error {
+		switch name {
+		case "shape":
+			return p.parseStyleShape(&s.Shape)
+		case "icon":
+			return p.parseStyleString(&s.Icon)
+		case "width":
+			return p.parseStyleInt(&s.Width, 1, -1)
+		case "height":
+			return p.parseStyleInt(&s.Height, 1, -1)
+		case "background":
+			return p.parseStyleColour(&s.Background)
+		case "color", "colour":
+			return p.parseStyleColour(&s.Color)
+		case "stroke":
+			return p.parseStyleColour(&s.Stroke)
+		case "strokewidth":
+			return p.parseStyleInt(&s.StrokeWidth, 1, 10)
+		case "fontsize":
+			return p.parseStyleInt(&s.FontSize, 1, -1)
+		case "border":
+			return p.parseStyleChoice(&s.Border, BorderSolid, BorderDashed, BorderDotted)
+		case "opacity":
+			s.Opacity = new(int)
+			return p.parseStyleInt(s.Opacity, 0, 100)
+		case "metadata":
+			s.Metadata = new(bool)
+			return p.parseStyleBool(s.Metadata)
+		case "description":
+			s.Description = new(bool)
+			return p.parseStyleBool(s.Description)
+		}
+		return ErrorForToken(p.currentToken, fmt.Errorf("unknown element style property %s", name))
+	})
+}
+
+func (p *Parser) parseRelationshipStyle(s *RelationshipStyle) error {
+	var err error
+	if s.Tag, err = p.parseString(); err != nil {
+		return fmt.Errorf("error parsing style tag:\n> %w", err)
+	}
+
+	return p.parseStyleProperties(func(name string) error {
+		switch name {
+		case "thickness":
+			return p.parseStyleInt(&s.Thickness, 1, -1)
+		case "color", "colour":
+			return p.parseStyleColour(&s.Color)
+		case "dashed":
+			s.Dashed = new(bool)
+			return p.parseStyleBool(s.Dashed)
+		case "routing":
+			return p.parseStyleChoice(&s.Routing, RoutingDirect, RoutingOrthogonal, RoutingCurved)
+		case "fontsize":
+			return p.parseStyleInt(&s.FontSize, 1, -1)
+		case "width":
+			return p.parseStyleInt(&s.Width, 1, -1)
+		case "position":
+			s.Position = new(int)
+			return p.parseStyleInt(s.Position, 0, 100)
+		case "opacity":
+			s.Opacity = new(int)
+			return p.parseStyleInt(s.Opacity, 0, 100)
+		}
+		return ErrorForToken(p.currentToken, fmt.Errorf("unknown relationship style property %s", name))
+	})
+}
+
+// reads `name value` pairs up to the end of the block. Property names aren't
+// keywords, so that they don't take identifiers away from the model, but
+// some of them, like description, happen to be keywords anyway.
+func (p *Parser) parseStyleProperties(parseProperty func(name string) error) error {
+	if !p.acceptOne(lexer.TypeStartBlock) {
+		return p.errExpectedNext().Tokens(lexer.TypeStartBlock)
+	}
+
+	seen := make(map[string]bool)
+
+	for {
+		if p.acceptOne(lexer.TypeEndBlock) {
+			return nil
+		}
+
+		if p.acceptOne(lexer.TypeTerminator) {
+			continue
+		}
+
+		err := p.statement(func() error {
+			if !p.acceptOne(lexer.TypeIdentifier) && !p.acceptOne(lexer.TypeKeyword) {
+				return p.errExpectedNext().Tokens(lexer.TypeIdentifier, lexer.TypeEndBlock)
+			}
+
+			name := strings.ToLower(p.currentSymbol())
+			if seen[name] {
+				return ErrorForToken(p.currentToken, fmt.Errorf("illegal redeclaration of %s in style", name))
+			}
+			seen[name] = true
+
+			if err := parseProperty(name); err != nil {
+				return err
+			}
+
+			if p.acceptOne(lexer.TypeEndBlock) {
+				p.backupToken()
+				return nil
+			}
+
+			if !p.acceptOne(lexer.TypeTerminator) {
+				return p.errExpectedNext().Tokens(lexer.TypeTerminator)
+			}
+			return nil
+		})
+		if err != nil {
+			return err
+		}
+	}
+}
+
+// style values are written as strings or bare words, some of which, like
+// person, happen to be keywords
+func (p *Parser) parseStyleString(target *string) error {
+	if p.acceptOne(lexer.TypeIdentifier) || p.acceptOne(lexer.TypeKeyword) {
+		*target = p.currentSymbol()
+		return nil
+	}
+	if !p.acceptOne(lexer.TypeString) {
+		return p.errExpectedNext().Tokens(lexer.TypeString, lexer.TypeIdentifier)
+	}
+	p.backupToken()
+	str, err := p.parseString()
+	if err != nil {
+		return err
+	}
+	*target = str
+	return nil
+}
+
+func (p *Parser) parseStyleShape(target *string) error {
+	var shape string
+	if err := p.parseStyleString(&shape); err != nil {
+		return err
+	}
+	known, err := matchShape(shape)
+	if err != nil {
+		return ErrorForToken(p.currentToken, err)
+	}
+	*target = known
+	return nil
+}
+
+func (p *Parser) parseStyleChoice(target *string, choices ...string) error {
+	var choice string
+	if err := p.parseStyleString(&choice); err != nil {
+		return err
+	}
+	known, err := matchChoice(choice, choices...)
+	if err != nil {
+		return ErrorForToken(p.currentToken, err)
+	}
+	*target = known
+	return nil
+}
+
+func matchShape(shape string) (string, error) {
+	for _, known := range knownShapes {
+		if strings.EqualFold(shape, known) {
+			return known, nil
+		}
+	}
+	return "", fmt.Errorf("unknown shape %s: expected one of %s", shape, strings.Join(knownShapes, ", "))
+}
+
+func matchChoice(choice string, choices ...string) (string, error) {
+	for _, known := range choices {
+		if strings.EqualFold(choice, known) {
+			return known, nil
+		}
+	}
+	return "", fmt.Errorf("unknown value %s: expected one of %s", choice, strings.Join(choices, ", "))
+}
+
+// colours are normalized to lowercase #rrggbb
+func (p *Parser) parseStyleColour(target *string) error {
+	var colour string
+	if err := p.parseStyleString(&colour); err != nil {
+		return err
+	}
+	normalized, err := matchColour(colour)
+	if err != nil {
+		return ErrorForToken(p.currentToken, err)
+	}
+	*target = normalized
+	return nil
+}
+
+func matchColour(colour string) (string, error) {
+	normalized, ok := NormalizeColour(colour)
+	if !ok {
+		return "", fmt.Errorf("invalid colour %s: expected #rgb, #rrggbb, or a colour name", colour)
+	}
+	return normalized, nil
+}
+
+// NormalizeColour converts a hex or named colour to lowercase #rrggbb
+func NormalizeColour(colour string) (string, bool) {
+	colour = strings.ToLower(colour)
+	if hex, named := namedColours[colour]; named {
+		return hex, true
+	}
+
+	if !strings.HasPrefix(colour, "#") {
+		return "", false
+	}
+	digits := colour[1:]
+	for _, r := range digits {
+		if !(r >= '0' && r <= '9') && !(r >= 'a' && r <= 'f') {
+			return "", false
+		}
+	}
+
+	switch len(digits) {
+	case 3:
+		return string([]byte{'#', digits[0], digits[0], digits[1], digits[1], digits[2], digits[2]}), true
+	case 6:
+		return colour, true
+	}
+	return "", false
+}
+
+// max is ignored when negative
+func (p *Parser) parseStyleInt(target *int, min, max int) error {
+	if !p.acceptOne(lexer.TypeNumber) {
+		return p.errExpectedNext().Tokens(lexer.TypeNumber)
+	}
+	n, err := p.currentInt()
+	if err != nil {
+		return err
+	}
+	if err := checkRange(n, min, max); err != nil {
+		return ErrorForToken(p.currentToken, err)
+	}
+	*target = n
+	return nil
+}
+
+// max is ignored when negative
+func checkRange(n, min, max int) error {
+	if n < min || (max >= 0 && n > max) {
+		if max < 0 {
+			return fmt.Errorf("%d is out of range: must be at least %d", n, min)
+		}
+		return fmt.Errorf("%d is out of range: must be between %d and %d", n, min, max)
+	}
+	return nil
+}
+
+func (p *Parser) parseStyleBool(target *bool) error {
+	if !p.acceptOne(lexer.TypeIdentifier) {
+		return p.errExpectedNext().Tokens(lexer.TypeIdentifier)
+	}
+	switch strings.ToLower(p.currentSymbol()) {
+	case "true":
+		*target = true
+	case "false":
+		*target = false
+	default:
+		return ErrorForToken(p.currentToken, fmt.Errorf("expected true or false"))
+	}
+	return nil
+}
@@ -0,0 +1,281 @@
+package parser
+
+import (
+	"encoding/json"
+	"fmt"
+
+	"go.burian.dev/c4/cmd/compiler/internal/lexer"
+)
+
+// parses the sources after `theme` or `themes`
+func (p *Parser) parseThemes(s *Styles) error {
+	single := p.currentKeyword() == KeywordTheme
+	if p.DeclarationOf(s) == nil {
+		// theme errors are reported at the first theme statement
+		p.declare(s)
+	}
+
+	var themes []string
+	for p.acceptOne(lexer.TypeString) {
+		p.backupToken()
+		theme, err := p.parseString()
+		if err != nil {
+			return err
+		}
+		themes = append(themes, theme)
+	}
+
+	if len(themes) == 0 {
+		return p.errExpectedNext().Tokens(lexer.TypeString)
+	}
+	if single && len(themes) > 1 {
+		return ErrorForToken(p.currentToken, fmt.Errorf("theme takes one source, use themes for several"))
+	}
+	s.Themes = append(s.Themes, themes...)
+
+	if p.acceptOne(lexer.TypeEndBlock) {
+		p.backupToken()
+		return nil
+	}
+	if !p.acceptOne(lexer.TypeTerminator) {
+		return p.errExpectedNext().Tokens(lexer.TypeString, lexer.TypeTerminator)
+	}
+	return nil
+}
+
+func (p *Parser) runParseStyles() (*Styles, error) {
+	s := new(Styles)
+
+	for {
+		if p.acceptOne(lexer.TypeEOF) {
+			if len(p.errs) > 0 {
+				return nil, p.errs
+			}
+			return s, nil
+		}
+
+		if p.acceptOne(lexer.TypeTerminator) {
+			continue
+		}
+
+		err := p.statement(func() error {
+			if !p.acceptOne(lexer.TypeKeyword) || p.currentKeyword() != KeywordStyles {
+				return p.errExpectedNext().Tokens(lexer.TypeEOF).Keywords(KeywordStyles)
+			}
+
+			if err := p.parseStyles(s); err != nil {
+				return fmt.Errorf("error parsing styles:\n> %w", err)
+			}
+			return nil
+		})
+		if err != nil {
+			return nil, append(p.errs, err)
+		}
+	}
+}
+
+// the theme format of the original DSL's tooling
+type structurizrTheme struct {
+	Name          string                         `json:"name"`
+	Description   string                         `json:"description"`
+	Elements      []structurizrElementStyle      `json:"elements"`
+	Relationships []structurizrRelationshipStyle `json:"relationships"`
+}
+
+type structurizrElementStyle struct {
+	Tag         string `json:"tag"`
+	Shape       string `json:"shape"`
+	Icon        string `json:"icon"`
+	Width       int    `json:"width"`
+	Height      int    `json:"height"`
+	Background  string `json:"background"`
+	Color       string `json:"color"`
+	Colour      string `json:"colour"`
+	Stroke      string `json:"stroke"`
+	StrokeWidth int    `json:"strokeWidth"`
+	FontSize    int    `json:"fontSize"`
+	Border      string `json:"border"`
+	Opacity     *int   `json:"opacity"`
+	Metadata    *bool  `json:"metadata"`
+	Description *bool  `json:"description"`
+}
+
+type structurizrRelationshipStyle struct {
+	Tag       string `json:"tag"`
+	Thickness int    `json:"thickness"`
+	Color     string `json:"color"`
+	Colour    string `json:"colour"`
+	Dashed    *bool  `json:"dashed"`
+	Style     string `json:"style"`
+	Routing   string `json:"routing"`
+	FontSize  int    `json:"fontSize"`
+	Width     int    `json:"width"`
+	Position  *int   `json:"position"`
+	Opacity   *int   `json:"opacity"`
+}
+
+// ParseStructurizrTheme reads a JSON theme as published for the original
+// DSL, validating it the same as styles written in the DSL
+func ParseStructurizrTheme(data []byte) (*Styles, error) {
+	var theme structurizrTheme
+	if err := json.Unmarshal(data, &theme); err != nil {
+		return nil, fmt.Errorf("invalid theme: %w", err)
+	}
+
+	s := new(Styles)
+
+	for _, in := range theme.Elements {
+		out, err := in.convert()
+		if err != nil {
+			return nil, fmt.Errorf("invalid theme: element style %s: %w", in.Tag, err)
+		}
+		s.Merge(&Styles{Elements: []*ElementStyle{out}})
+	}
+
+	for _, in := range theme.Relationships {
+		out, err := in.convert()
+		if err != nil {
+			return nil, fmt.Errorf("invalid theme: relationship style %s: %w", in.Tag, err)
+		}
+		s.Merge(&Styles{Relationships: []*RelationshipStyle{out}})
+	}
+
+	return s, nil
+}
+
+func (in *structurizrElementStyle) convert() (*ElementStyle, error) {
+	out := &ElementStyle{
+		Tag:         in.Tag,
+		Icon:        in.Icon,
+		Opacity:     in.Opacity,
+		Metadata:    in.Metadata,
+		Description: in.Description,
+	}
+	var err error
+
+	if in.Tag == "" {
+		return nil, fmt.Errorf("missing tag")
+	}
+	if in.Shape != "" {
+		if out.Shape, err = matchShape(in.Shape); err != nil {
+			return nil, err
+		}
+	}
+	if in.Border != "" {
+		if out.Border, err = matchChoice(in.Border, BorderSolid, BorderDashed, BorderDotted); err != nil {
+			return nil, err
+		}
+	}
+	if in.Color == "" {
+		in.Color = in.Colour
+	}
+
+	for _, c := range []struct {
+		in  string
+		out *string
+	}{
+		{in.Background, &out.Background},
+		{in.Color, &out.Color},
+		{in.Stroke, &out.Stroke},
+	} {
+		if c.in == "" {
+			continue
+		}
+		if *c.out, err = matchColour(c.in); err != nil {
+			return nil, err
+		}
+	}
+
+	for _, n := range []struct {
+		in       int
+		out      *int
+		min, max int
+	}{
+		{in.Width, &out.Width, 1, -1},
+		{in.Height, &out.Height, 1, -1},
+		{in.StrokeWidth, &out.StrokeWidth, 1, 10},
+		{in.FontSize, &out.FontSize, 1, -1},
+	} {
+		if n.in == 0 {
+			continue
+		}
+		if err := checkRange(n.in, n.min, n.max); err != nil {
+			return nil, err
+		}
+		*n.out = n.in
+	}
+
+	if in.Opacity != nil {
+		if err := checkRange(*in.Opacity, 0, 100); err != nil {
+			return nil, err
+		}
+	}
+
+	return out, nil
+}
+
+func (in *structurizrRelationshipStyle) convert() (*RelationshipStyle, error) {
+	out := &RelationshipStyle{
+		Tag:      in.Tag,
+		Dashed:   in.Dashed,
+		Position: in.Position,
+		Opacity:  in.Opacity,
+	}
+	var err error
+
+	if in.Tag == "" {
+		return nil, fmt.Errorf("missing tag")
+	}
+
+	// newer themes replace dashed with a line style
+	if in.Style != "" {
+		style, err := matchChoice(in.Style, BorderSolid, BorderDashed, BorderDotted)
+		if err != nil {
+			return nil, err
+		}
+		dashed := style != BorderSolid
+		out.Dashed = &dashed
+	}
+
+	if in.Routing != "" {
+		if out.Routing, err = matchChoice(in.Routing, RoutingDirect, RoutingOrthogonal, RoutingCurved); err != nil {
+			return nil, err
+		}
+	}
+	if in.Color == "" {
+		in.Color = in.Colour
+	}
+	if in.Color != "" {
+		if out.Color, err = matchColour(in.Color); err != nil {
+			return nil, err
+		}
+	}
+
+	for _, n := range []struct {
+		in  int
+		out *int
+	}{
+		{in.Thickness, &out.Thickness},
+		{in.FontSize, &out.FontSize},
+		{in.Width, &out.Width},
+	} {
+		if n.in == 0 {
+			continue
+		}
+		if err := checkRange(n.in, 1, -1); err != nil {
+			return nil, err
+		}
+		*n.out = n.in
+	}
+
+	for _, n := range []*int{in.Position, in.Opacity} {
+		if n == nil {
+			continue
+		}
+		if err := checkRange(*n, 0, 100); err != nil {
+			return nil, err
+		}
+	}
+
+	return out, nil
+}
@@ -0,0 +1,422 @@
+package parser
+
+import (
+	"fmt"
+	"strconv"
+
+	"go.burian.dev/c4/cmd/compiler/internal/lexer"
+)
+
+type Views struct {
+	SystemLandscapeViews []*SystemLandscapeView `json:"system_landscape_views,omitempty"`
+	SystemContextViews   []*SystemContextView   `json:"system_context_views,omitempty"`
+	ContainerViews       []*ContainerView       `json:"container_views,omitempty"`
+	ComponentViews       []*ComponentView       `json:"component_views,omitempty"`
+	DynamicViews         []*DynamicView         `json:"dynamic_views,omitempty"`
+	DeploymentViews      []*DeploymentView      `json:"deployment_views,omitempty"`
+
+	Styles *Styles `json:"styles,omitempty"`
+}
+
+type View interface {
+	Base() *BaseView
+}
+
+// BaseView is the set of properties common to every view
+type BaseView = baseView
+
+type baseView struct {
+	Key         string `json:"key,omitempty"`
+	Description string `json:"description,omitempty"`
+
+	Include    []*ViewExpression `json:"include,omitempty"`
+	Exclude    []*ViewExpression `json:"exclude,omitempty"`
+	AutoLayout *AutoLayout       `json:"auto_layout,omitempty"`
+
+	// filled by the checker once the include and exclude expressions are
+	// evaluated against the model
+	Scope         Entity             `json:"-"`
+	Elements      []Entity           `json:"-"`
+	ElementIds    []IdentifierString `json:"elements,omitempty"`
+	Relationships []*Relationship    `json:"relationships,omitempty"`
+}
+
+func (v *baseView) Base() *BaseView {
+	return v
+}
+
+type SystemLandscapeView struct {
+	baseView
+}
+
+type SystemContextView struct {
+	baseView
+	SoftwareSystemId IdentifierString `json:"software_system_id"`
+}
+
+type ContainerView struct {
+	baseView
+	SoftwareSystemId IdentifierString `json:"software_system_id"`
+}
+
+type ComponentView struct {
+	baseView
+	ContainerId IdentifierString `json:"container_id"`
+}
+
+// ViewExpression selects elements or relationships to include in or
+// exclude from a view
+//
+//	include *        everything the view would show by default
+//	include a        the element a
+//	include ->a->    a and everything it has relationships with
+//	include ->a      a and everything with relationships to it
+//	include a->      a and everything it has relationships to
+//	exclude a -> b   relationships from a to b
+type ViewExpression struct {
+	Wildcard bool             `json:"wildcard,omitempty"`
+	Id       IdentifierString `json:"id,omitempty"`
+
+	Incoming bool `json:"incoming,omitempty"`
+	Outgoing bool `json:"outgoing,omitempty"`
+
+	DestinationId IdentifierString `json:"destination_id,omitempty"`
+}
+
+type AutoLayout struct {
+	Direction      string `json:"direction"`
+	RankSeparation int    `json:"rank_separation"`
+	NodeSeparation int    `json:"node_separation"`
+}
+
+const (
+	LayoutTopBottom = "tb"
+	LayoutBottomTop = "bt"
+	LayoutLeftRight = "lr"
+	LayoutRightLeft = "rl"
+
+	defaultLayoutSeparation = 300
+)
+
+// All returns every view in a stable order
+func (v *Views) All() []View {
+	var all []View
+	for _, view := range v.SystemLandscapeViews {
+		all = append(all, view)
+	}
+	for _, view := range v.SystemContextViews {
+		all = append(all, view)
+	}
+	for _, view := range v.ContainerViews {
+		all = append(all, view)
+	}
+	for _, view := range v.ComponentViews {
+		all = append(all, view)
+	}
+	for _, view := range v.DynamicViews {
+		all = append(all, view)
+	}
+	for _, view := range v.DeploymentViews {
+		all = append(all, view)
+	}
+	return all
+}
+
+func (p *Parser) parseViews() (*Views, error) {
+	v := new(Views)
+
+	if !p.acceptOne(lexer.TypeStartBlock) {
+		return nil, p.errExpectedNext().Tokens(lexer.TypeStartBlock)
+	}
+
+	expectedKeywords := []Keyword{
+		KeywordSystemLandscape,
+		KeywordSystemContext,
+		KeywordContainer,
+		KeywordComponent,
+		KeywordDynamic,
+		KeywordDeployment,
+		KeywordStyles,
+		KeywordTheme,
+		KeywordThemes,
+	}
+
+	for {
+		if p.acceptOne(lexer.TypeEndBlock) {
+			return v, nil
+		}
+
+		if p.acceptOne(lexer.TypeTerminator) {
+			continue
+		}
+
+		err := p.statement(func() error {
+			if !p.acceptOne(lexer.TypeKeyword) {
+				return p.errExpectedNext().Tokens(lexer.TypeEndBlock).Keywords(expectedKeywords...)
+			}
+
+			switch p.currentKeyword() {
+
+			case KeywordSystemLandscape:
+				view := new(SystemLandscapeView)
+				p.declare(view)
+				if err := p.parseView(&view.baseView, nil); err != nil {
+					return fmt.Errorf("error parsing system landscape view:\n> %w", err)
+				}
+				v.SystemLandscapeViews = append(v.SystemLandscapeViews, view)
+
+			case KeywordSystemContext:
+				view := new(SystemContextView)
+				p.declare(view)
+				if err := p.parseView(&view.baseView, &view.SoftwareSystemId); err != nil {
+					return fmt.Errorf("error parsing system context view:\n> %w", err)
+				}
+				v.SystemContextViews = append(v.SystemContextViews, view)
+
+			case KeywordContainer:
+				view := new(ContainerView)
+				p.declare(view)
+				if err := p.parseView(&view.baseView, &view.SoftwareSystemId); err != nil {
+					return fmt.Errorf("error parsing container view:\n> %w", err)
+				}
+				v.ContainerViews = append(v.ContainerViews, view)
+
+			case KeywordComponent:
+				view := new(ComponentView)
+				p.declare(view)
+				if err := p.parseView(&view.baseView, &view.ContainerId); err != nil {
+					return fmt.Errorf("error parsing component view:\n> %w", err)
+				}
+				v.ComponentViews = append(v.ComponentViews, view)
+
+			case KeywordDynamic:
+				view := new(DynamicView)
+				p.declare(view)
+				if err := p.parseDynamicView(view); err != nil {
+					return fmt.Errorf("error parsing dynamic view:\n> %w", err)
+				}
+				v.DynamicViews = append(v.DynamicViews, view)
+
+			case KeywordDeployment:
+				view := new(DeploymentView)
+				p.declare(view)
+				if err := p.parseDeploymentView(view); err != nil {
+					return fmt.Errorf("error parsing deployment view:\n> %w", err)
+				}
+				v.DeploymentViews = append(v.DeploymentViews, view)
+
+			case KeywordStyles:
+				if v.Styles == nil {
+					v.Styles = new(Styles)
+				}
+				if err := p.parseStyles(v.Styles); err != nil {
+					return fmt.Errorf("error parsing styles:\n> %w", err)
+				}
+
+			case KeywordTheme, KeywordThemes:
+				if v.Styles == nil {
+					v.Styles = new(Styles)
+				}
+				if err := p.parseThemes(v.Styles); err != nil {
+					return fmt.Errorf("error parsing themes:\n> %w", err)
+				}
+
+			default:
+				return p.errExpectedCurrent().Tokens(lexer.TypeEndBlock).Keywords(expectedKeywords...)
+			}
+			return nil
+		})
+		if err != nil {
+			return nil, err
+		}
+	}
+}
+
+// parses `[scope] [key] [description] { ... }` for all static view types
+// scope is nil for views that don't have one
+func (p *Parser) parseView(v *baseView, scope *IdentifierString) error {
+	if scope != nil {
+		if !p.acceptIdentifierString() {
+			return p.errExpectedNext().Tokens(lexer.TypeIdentifier)
+		}
+		*scope = p.claimHeldIdentifier()
+	}
+
+	err := p.parseShortDeclarationSeq(0,
+		&v.Key,
+		&v.Description,
+	)
+	if err != nil {
+		return fmt.Errorf("error parsing view short declaration:\n> %w", err)
+	}
+
+	if !p.acceptOne(lexer.TypeStartBlock) {
+		return p.errExpectedNext().Tokens(lexer.TypeStartBlock)
+	}
+
+	return p.parseViewBody(v)
+}
+
+func (p *Parser) parseViewBody(v *baseView) error {
+	expectedKeywords := []Keyword{
+		KeywordDescription,
+		KeywordInclude,
+		KeywordExclude,
+		KeywordAutoLayout,
+	}
+
+	for {
+		if p.acceptOne(lexer.TypeEndBlock) {
+			return nil
+		}
+
+		if p.acceptOne(lexer.TypeTerminator) {
+			continue
+		}
+
+		err := p.statement(func() error {
+			if !p.acceptOne(lexer.TypeKeyword) {
+				return p.errExpectedNext().Tokens(lexer.TypeEndBlock).Keywords(expectedKeywords...)
+			}
+
+			switch p.currentKeyword() {
+
+			case KeywordDescription:
+				if err := p.parseSimpleValue("description", &v.Description); err != nil {
+					return err
+				}
+
+			case KeywordInclude:
+				exprs, err := p.parseViewExpressions()
+				if err != nil {
+					return fmt.Errorf("error parsing include:\n> %w", err)
+				}
+				v.Include = append(v.Include, exprs...)
+
+			case KeywordExclude:
+				exprs, err := p.parseViewExpressions()
+				if err != nil {
+					return fmt.Errorf("error parsing exclude:\n> %w", err)
+				}
+				v.Exclude = append(v.Exclude, exprs...)
+
+			case KeywordAutoLayout:
+				if v.AutoLayout != nil {
+					return ErrorForToken(p.currentToken, fmt.Errorf("illegal redeclaration of autoLayout"))
+				}
+				layout, err := p.parseAutoLayout()
+				if err != nil {
+					return fmt.Errorf("error parsing autoLayout:\n> %w", err)
+				}
+				v.AutoLayout = layout
+
+			default:
+				return p.errExpectedCurrent().Tokens(lexer.TypeEndBlock).Keywords(expectedKeywords...)
+			}
+			return nil
+		})
+		if err != nil {
+			return err
+		}
+	}
+}
+
+// parses one or more expressions up to the end of the statement
+func (p *Parser) parseViewExpressions() ([]*ViewExpression, error) {
+	var exprs []*ViewExpression
+
+	for {
+		if p.acceptOne(lexer.TypeTerminator) {
+			if len(exprs) == 0 {
+				p.backupToken()
+				return nil, p.errExpectedNext().Tokens(lexer.TypeWildcard, lexer.TypeIdentifier, lexer.TypeRelationship)
+			}
+			return exprs, nil
+		}
+
+		if len(exprs) > 0 && p.acceptOne(lexer.TypeEndBlock) {
+			p.backupToken()
+			return exprs, nil
+		}
+
+		expr := new(ViewExpression)
+
+		switch {
+
+		case p.acceptOne(lexer.TypeWildcard):
+			p.declare(expr)
+			expr.Wildcard = true
+
+		case p.acceptOne(lexer.TypeRelationship):
+			// ->a or ->a->
+			p.declare(expr)
+			expr.Incoming = true
+			if !p.acceptIdentifierString() {
+				return nil, p.errExpectedNext().Tokens(lexer.TypeIdentifier)
+			}
+			expr.Id = p.claimHeldIdentifier()
+			expr.Outgoing = p.acceptOne(lexer.TypeRelationship)
+
+		case p.acceptIdentifierString():
+			// a, a-> or a -> b
+			p.declare(expr)
+			expr.Id = p.claimHeldIdentifier()
+			if p.acceptOne(lexer.TypeRelationship) {
+				if p.acceptIdentifierString() {
+					expr.DestinationId = p.claimHeldIdentifier()
+				} else {
+					expr.Outgoing = true
+				}
+			}
+
+		default:
+			return nil, p.errExpectedNext().Tokens(lexer.TypeWildcard, lexer.TypeIdentifier, lexer.TypeRelationship, lexer.TypeTerminator)
+		}
+
+		exprs = append(exprs, expr)
+	}
+}
+
+// parses `autoLayout [direction] [rankSeparation] [nodeSeparation]`
+func (p *Parser) parseAutoLayout() (*AutoLayout, error) {
+	layout := &AutoLayout{
+		Direction:      LayoutTopBottom,
+		RankSeparation: defaultLayoutSeparation,
+		NodeSeparation: defaultLayoutSeparation,
+	}
+
+	if p.acceptOne(lexer.TypeIdentifier) {
+		switch dir := p.currentSymbol(); dir {
+		case LayoutTopBottom, LayoutBottomTop, LayoutLeftRight, LayoutRightLeft:
+			layout.Direction = dir
+		default:
+			return nil, ErrorForToken(p.currentToken, fmt.Errorf("unknown layout direction %s: expected one of tb, bt, lr, or rl", dir))
+		}
+	}
+
+	for _, separation := range []*int{&layout.RankSeparation, &layout.NodeSeparation} {
+		if !p.acceptOne(lexer.TypeNumber) {
+			break
+		}
+		n, err := p.currentInt()
+		if err != nil {
+			return nil, err
+		}
+		*separation = n
+	}
+
+	if !p.acceptOne(lexer.TypeTerminator) {
+		return nil, p.errExpectedNext().Tokens(lexer.TypeNumber, lexer.TypeTerminator)
+	}
+
+	return layout, nil
+}
+
+// reads the current number token as a non-negative integer
+func (p *Parser) currentInt() (int, error) {
+	n, err := strconv.Atoi(p.currentSymbol())
+	if err != nil {
+		return 0, ErrorForToken(p.currentToken, fmt.Errorf("expected a whole number"))
+	}
+	return n, nil
+}
@@ -0,0 +1,290 @@
+package plantuml
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"strings"
+
+	"go.burian.dev/c4/cmd/compiler/internal/diagram"
+	"go.burian.dev/c4/cmd/compiler/internal/parser"
+)
+
+// Extension is the file extension of rendered views
+const Extension = ".puml"
+
+// Render writes a checked view as a C4-PlantUML diagram, using the styles
+// of the workspace for its element and relationship tags
+func Render(out io.Writer, w *parser.Workspace, view parser.View) error {
+	var styles *parser.Styles
+	if w.Views != nil {
+		styles = w.Views.Styles
+	}
+
+	r := &renderer{
+		w:      bufio.NewWriter(out),
+		view:   view,
+		styles: styles,
+
+		aliases: new(diagram.Aliases),
+	}
+
+	r.render()
+	return r.w.Flush()
+}
+
+type renderer struct {
+	w      *bufio.Writer
+	view   parser.View
+	styles *parser.Styles
+
+	aliases *diagram.Aliases
+}
+
+func (r *renderer) render() {
+	base := r.view.Base()
+
+	r.printf("@startuml %s\n", diagram.Alias(parser.IdentifierString(base.Key)))
+	r.printf("!include <C4/%s>\n\n", library(r.view))
+
+	title := base.Description
+	if title == "" {
+		title = base.Key
+	}
+	r.printf("title %s\n\n", strings.ReplaceAll(title, "\n", " "))
+
+	if base.AutoLayout != nil {
+		// C4-PlantUML can only lay out towards the bottom or the right, and
+		// has no reversed layouts, so rl and bt are drawn as lr and tb
+		switch base.AutoLayout.Direction {
+		case parser.LayoutLeftRight, parser.LayoutRightLeft:
+			r.printf("LAYOUT_LEFT_RIGHT()\n\n")
+		default:
+			r.printf("LAYOUT_TOP_DOWN()\n\n")
+		}
+	}
+
+	if r.renderTags() {
+		r.printf("\n")
+	}
+
+	calls := &diagram.Calls{Aliases: r.aliases, Element: r.element, Quote: quote}
+	for _, g := range diagram.GroupElements(base.Elements) {
+		calls.WriteGroup(r.w, g, "")
+	}
+
+	if dynamic, ok := r.view.(*parser.DynamicView); ok {
+		if len(dynamic.Steps) > 0 {
+			r.printf("\n")
+		}
+		for _, step := range dynamic.Steps {
+			params := []string{
+				quote(step.Order),
+				r.aliases.Of(step.Source.Id()),
+				r.aliases.Of(step.Destination.Id()),
+				quote(step.Description),
+				quote(step.Technology),
+			}
+			r.printf("RelIndex(%s%s)\n", diagram.Args(params...), r.tagsParam(r.relationshipTags(step.Relationship)))
+		}
+	} else {
+		if len(base.Relationships) > 0 {
+			r.printf("\n")
+		}
+		for _, rel := range base.Relationships {
+			params := []string{
+				r.aliases.Of(rel.Source.Id()),
+				r.aliases.Of(rel.Destination.Id()),
+				quote(rel.Description),
+				quote(rel.Technology),
+			}
+			r.printf("Rel(%s%s)\n", diagram.Args(params...), r.tagsParam(r.relationshipTags(rel)))
+		}
+	}
+
+	r.printf("@enduml\n")
+}
+
+// tags are only passed through to C4-PlantUML where there is a style for
+// them, which keeps the output readable
+func (r *renderer) renderTags() bool {
+	if r.styles == nil {
+		return false
+	}
+
+	usedElementTags := make(map[string]bool)
+	for _, e := range r.view.Base().Elements {
+		for _, tag := range r.elementTags(e) {
+			usedElementTags[tag] = true
+		}
+	}
+	usedRelationshipTags := make(map[string]bool)
+	for _, rel := range r.view.Base().Relationships {
+		for _, tag := range r.relationshipTags(rel) {
+			usedRelationshipTags[tag] = true
+		}
+	}
+
+	rendered := false
+	for _, style := range r.styles.Elements {
+		if !usedElementTags[style.Tag] {
+			continue
+		}
+		params := []string{quote(style.Tag)}
+		if style.Background != "" {
+			params = append(params, "$bgColor="+quote(style.Background))
+		}
+		if style.Color != "" {
+			params = append(params, "$fontColor="+quote(style.Color))
+		}
+		if style.Stroke != "" {
+			params = append(params, "$borderColor="+quote(style.Stroke))
+		}
+		switch style.Shape {
+		case parser.ShapeRoundedBox:
+			params = append(params, "$shape=RoundedBoxShape()")
+		case parser.ShapeHexagon:
+			params = append(params, "$shape=EightSidedShape()")
+		}
+		switch style.Border {
+		case parser.BorderDashed:
+			params = append(params, "$borderStyle=DashedLine()")
+		case parser.BorderDotted:
+			params = append(params, "$borderStyle=DottedLine()")
+		}
+		r.printf("AddElementTag(%s)\n", strings.Join(params, ", "))
+		rendered = true
+	}
+
+	for _, style := range r.styles.Relationships {
+		if !usedRelationshipTags[style.Tag] {
+			continue
+		}
+		params := []string{quote(style.Tag)}
+		if style.Color != "" {
+			params = append(params, "$textColor="+quote(style.Color), "$lineColor="+quote(style.Color))
+		}
+		if style.Dashed != nil && *style.Dashed {
+			params = append(params, "$lineStyle=DashedLine()")
+		}
+		r.printf("AddRelTag(%s)\n", strings.Join(params, ", "))
+		rendered = true
+	}
+
+	return rendered
+}
+
+func (r *renderer) elementTags(e parser.Entity) []string {
+	if r.styles == nil {
+		return nil
+	}
+	var styled []string
+	for _, tag := range parser.TagsOf(e) {
+		for _, style := range r.styles.Elements {
+			if style.Tag == tag {
+				styled = appendUnique(styled, tag)
+			}
+		}
+	}
+	return styled
+}
+
+func (r *renderer) relationshipTags(rel *parser.Relationship) []string {
+	if r.styles == nil {
+		return nil
+	}
+	var styled []string
+	for _, tag := range parser.TagsOf(rel) {
+		for _, style := range r.styles.Relationships {
+			if style.Tag == tag {
+				styled = appendUnique(styled, tag)
+			}
+		}
+	}
+	return styled
+}
+
+func (r *renderer) tagsParam(tags []string) string {
+	if len(tags) == 0 {
+		return ""
+	}
+	return ", $tags=" + quote(strings.Join(tags, "+"))
+}
+
+// element returns the C4-PlantUML macro call for a single element
+func (r *renderer) element(e parser.Entity) string {
+	b := e.Base()
+	id := r.aliases.Of(e.Id())
+	shape := r.styles.ElementStyle(parser.TagsOf(e)).Shape
+
+	var call string
+	switch obj := e.(type) {
+	case *parser.Person:
+		call = "Person(" + diagram.Args(id, quote(b.Name), quote(b.Description))
+
+	case *parser.SoftwareSystem:
+		call = diagram.Variant("System", shape) + "(" + diagram.Args(id, quote(b.Name), quote(b.Description))
+
+	case *parser.Container:
+		call = diagram.Variant("Container", shape) + "(" + diagram.Args(id, quote(b.Name), quote(b.Technology), quote(b.Description))
+
+	case *parser.Component:
+		call = diagram.Variant("Component", shape) + "(" + diagram.Args(id, quote(b.Name), quote(b.Technology), quote(b.Description))
+
+	case *parser.DeploymentNode:
+		name := b.Name
+		if obj.Instances > 1 {
+			name = fmt.Sprintf("%s (x%d)", name, obj.Instances)
+		}
+		call = "Deployment_Node(" + diagram.Args(id, quote(name), quote(b.Technology), quote(b.Description))
+
+	case *parser.InfrastructureNode:
+		call = "Node(" + diagram.Args(id, quote(b.Name), quote(b.Technology), quote(b.Description))
+
+	case *parser.ContainerInstance:
+		deployed := obj.Container.Base()
+		call = diagram.Variant("Container", shape) + "(" + diagram.Args(id, quote(deployed.Name), quote(deployed.Technology), quote(deployed.Description))
+
+	case *parser.SoftwareSystemInstance:
+		deployed := obj.SoftwareSystem.Base()
+		call = diagram.Variant("System", shape) + "(" + diagram.Args(id, quote(deployed.Name), quote(deployed.Description))
+
+	default:
+		call = "System(" + diagram.Args(id, quote(b.Name), quote(b.Description))
+	}
+
+	return call + r.tagsParam(r.elementTags(e)) + ")"
+}
+
+func library(view parser.View) string {
+	switch view.(type) {
+	case *parser.SystemLandscapeView, *parser.SystemContextView:
+		return "C4_Context"
+	case *parser.ContainerView:
+		return "C4_Container"
+	case *parser.ComponentView:
+		return "C4_Component"
+	case *parser.DynamicView:
+		return "C4_Dynamic"
+	case *parser.DeploymentView:
+		return "C4_Deployment"
+	}
+	return "C4_Context"
+}
+
+func (r *renderer) printf(format string, a ...any) {
+	fmt.Fprintf(r.w, format, a...)
+}
+
+func quote(s string) string {
+	return diagram.Quote(s, `\n`)
+}
+
+func appendUnique(list []string, s string) []string {
+	for _, existing := range list {
+		if existing == s {
+			return list
+		}
+	}
+	return append(list, s)
+}
@@ -0,0 +1,58 @@
+package plantuml
+
+import (
+	"strings"
+	"testing"
+
+	"go.burian.dev/c4/cmd/compiler/internal/parser"
+)
+
+func TestRender(t *testing.T) {
+	u := &parser.Person{}
+	u.SetId("u")
+	u.Name = `The "user"`
+
+	ss := &parser.SoftwareSystem{}
+	ss.SetId("a")
+	ss.Name = "a"
+
+	api := &parser.Container{}
+	api.SetId("api")
+	api.SetFullyQualifiedId("a.api")
+	api.SetParent(ss)
+	api.Name = "api"
+	api.Description = "line one\nline two"
+
+	rel := &parser.Relationship{Source: u, Destination: api}
+	rel.Description = "uses"
+
+	view := &parser.ContainerView{}
+	view.Key = "a/containers"
+	view.AutoLayout = &parser.AutoLayout{Direction: parser.LayoutBottomTop}
+	view.Elements = []parser.Entity{u, api}
+	view.Relationships = []*parser.Relationship{rel}
+
+	buf := new(strings.Builder)
+	if err := Render(buf, &parser.Workspace{}, view); err != nil {
+		t.Fatalf("unexpected render error: %s", err)
+	}
+
+	want := `@startuml a_containers
+!include <C4/C4_Container>
+
+title a/containers
+
+LAYOUT_TOP_DOWN()
+
+Person(u, "The 'user'")
+System_Boundary(a, "a") {
+    Container(a_api, "api", "", "line one\nline two")
+}
+
+Rel(u, a_api, "uses")
+@enduml
+`
+	if got := buf.String(); got != want {
+		t.Errorf("got\n%s\nwant\n%s", got, want)
+	}
+}
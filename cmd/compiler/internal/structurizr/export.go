@@ -0,0 +1,400 @@
+package structurizr
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"sort"
+	"strconv"
+	"strings"
+
+	"go.burian.dev/c4/cmd/compiler/internal/parser"
+)
+
+// Export writes a checked workspace as a Structurizr workspace document.
+// Elements and relationships are numbered in the order they appear in the
+// model, so exporting the same workspace always gives the same document.
+func Export(out io.Writer, w *parser.Workspace) error {
+	e := &exporter{
+		ids:       make(map[any]string),
+		instances: make(map[string]int),
+	}
+
+	doc := &workspace{
+		Name:          w.Name,
+		Description:   w.Description,
+		Model:         new(model),
+		Views:         new(views),
+		Configuration: new(configuration),
+	}
+
+	if w.Model != nil {
+		e.number(w.Model)
+		doc.Model = e.model(w.Model)
+	}
+	if w.Views != nil {
+		v, err := e.views(w.Views)
+		if err != nil {
+			return err
+		}
+		doc.Views = v
+	}
+
+	enc := json.NewEncoder(out)
+	enc.SetIndent("", "  ")
+	return enc.Encode(doc)
+}
+
+type exporter struct {
+	// the numeric identifiers of every element and relationship
+	ids map[any]string
+
+	// the instances of each container or software system deployed in each
+	// environment so far, which Structurizr numbers from 1
+	instances map[string]int
+}
+
+// number gives every element and relationship in the model its identifier
+// in the order they're written, elements first so that relationships can
+// be added to them in any order
+func (e *exporter) number(m *parser.Model) {
+	var walk func(x parser.Entity)
+	walk = func(x parser.Entity) {
+		e.ids[x] = strconv.Itoa(len(e.ids) + 1)
+		for _, child := range x.Base().Children() {
+			walk(child)
+		}
+	}
+
+	people, systems, environments := partition(m)
+	for _, x := range append(people, systems...) {
+		walk(x)
+	}
+	for _, env := range environments {
+		// environments are only a name on the nodes in them
+		for _, node := range env.Base().Children() {
+			walk(node)
+		}
+	}
+
+	// relationships are written with their sources
+	rels := m.AllRelationships()
+	order := func(r *parser.Relationship) int {
+		n, _ := strconv.Atoi(e.ids[r.Source])
+		return n
+	}
+	sort.SliceStable(rels, func(i, j int) bool { return order(rels[i]) < order(rels[j]) })
+	for _, r := range rels {
+		e.ids[r] = strconv.Itoa(len(e.ids) + 1)
+	}
+}
+
+// partition splits the top of the model into the lists Structurizr keeps
+// them in
+func partition(m *parser.Model) (people, systems, environments []parser.Entity) {
+	for _, x := range m.Children() {
+		switch x.(type) {
+		case *parser.Person:
+			people = append(people, x)
+		case *parser.SoftwareSystem:
+			systems = append(systems, x)
+		case *parser.DeploymentEnvironment:
+			environments = append(environments, x)
+		}
+	}
+	return people, systems, environments
+}
+
+func (e *exporter) model(m *parser.Model) *model {
+	out := new(model)
+
+	// Structurizr keeps relationships with their source, wherever in the
+	// model they were declared
+	bySource := make(map[parser.Entity][]*relationship)
+	for _, r := range m.AllRelationships() {
+		bySource[r.Source] = append(bySource[r.Source], e.relationship(r))
+	}
+
+	people, systems, environments := partition(m)
+
+	for _, x := range people {
+		out.People = append(out.People, e.element(x, bySource))
+	}
+
+	for _, x := range systems {
+		system := e.element(x, bySource)
+		for _, c := range x.Base().Children() {
+			container := e.element(c, bySource)
+			for _, k := range c.Base().Children() {
+				container.Components = append(container.Components, e.element(k, bySource))
+			}
+			system.Containers = append(system.Containers, container)
+		}
+		out.SoftwareSystems = append(out.SoftwareSystems, system)
+	}
+
+	for _, env := range environments {
+		for _, node := range env.Base().Children() {
+			out.DeploymentNodes = append(out.DeploymentNodes, e.deploymentNode(node, environmentName(env.(*parser.DeploymentEnvironment)), bySource))
+		}
+	}
+
+	return out
+}
+
+func (e *exporter) element(x parser.Entity, bySource map[parser.Entity][]*relationship) *element {
+	b := x.Base()
+	return &element{
+		Id:            e.ids[x],
+		Tags:          joinTags(x, b.Tags),
+		Name:          b.Name,
+		Description:   b.Description,
+		Technology:    b.Technology,
+		Url:           b.Url,
+		Group:         b.Group,
+		Properties:    properties(x),
+		Perspectives:  perspectives(b.Perspectives),
+		Relationships: bySource[x],
+	}
+}
+
+func (e *exporter) deploymentNode(x parser.Entity, environment string, bySource map[parser.Entity][]*relationship) *deploymentNode {
+	node := &deploymentNode{element: *e.element(x, bySource), Environment: environment}
+	if obj, ok := x.(*parser.DeploymentNode); ok {
+		node.Instances = count(obj.Instances)
+		if node.Instances == 0 {
+			node.Instances = 1
+		}
+	}
+
+	for _, child := range x.Base().Children() {
+		switch obj := child.(type) {
+		case *parser.DeploymentNode:
+			node.Children = append(node.Children, e.deploymentNode(obj, environment, bySource))
+
+		case *parser.InfrastructureNode:
+			node.InfrastructureNodes = append(node.InfrastructureNodes, e.deploymentNode(obj, environment, bySource))
+
+		case *parser.ContainerInstance:
+			inst := e.instance(obj, environment, bySource)
+			inst.ContainerId = e.ids[obj.Container]
+			inst.InstanceId = e.nextInstance(inst.ContainerId, environment)
+			node.ContainerInstances = append(node.ContainerInstances, inst)
+
+		case *parser.SoftwareSystemInstance:
+			inst := e.instance(obj, environment, bySource)
+			inst.SoftwareSystemId = e.ids[obj.SoftwareSystem]
+			inst.InstanceId = e.nextInstance(inst.SoftwareSystemId, environment)
+			node.SoftwareSystemInstances = append(node.SoftwareSystemInstances, inst)
+		}
+	}
+	return node
+}
+
+func (e *exporter) instance(x parser.Entity, environment string, bySource map[parser.Entity][]*relationship) *instance {
+	return &instance{
+		Id:            e.ids[x],
+		Tags:          joinTags(x, x.Base().Tags),
+		Environment:   environment,
+		Properties:    properties(x),
+		Relationships: bySource[x],
+	}
+}
+
+func (e *exporter) nextInstance(deployed, environment string) int {
+	key := environment + "/" + deployed
+	e.instances[key]++
+	return e.instances[key]
+}
+
+func (e *exporter) relationship(r *parser.Relationship) *relationship {
+	out := &relationship{
+		Id:            e.ids[r],
+		Tags:          joinTags(r, r.Tags),
+		SourceId:      e.ids[r.Source],
+		DestinationId: e.ids[r.Destination],
+		Description:   r.Description,
+		Technology:    r.Technology,
+		Url:           r.Url,
+		Properties:    r.Properties,
+	}
+	switch {
+	case r.ImpliedBasedOn != nil:
+		out.LinkedRelationshipId = e.ids[r.ImpliedBasedOn]
+	case r.LinkedTo != nil:
+		out.LinkedRelationshipId = e.ids[r.LinkedTo]
+	}
+	return out
+}
+
+func (e *exporter) views(v *parser.Views) (*views, error) {
+	out := new(views)
+
+	for _, x := range v.SystemLandscapeViews {
+		out.SystemLandscapeViews = append(out.SystemLandscapeViews, e.view(x))
+	}
+	for _, x := range v.SystemContextViews {
+		view := e.view(x)
+		view.SoftwareSystemId = e.ids[x.Scope]
+		out.SystemContextViews = append(out.SystemContextViews, view)
+	}
+	for _, x := range v.ContainerViews {
+		view := e.view(x)
+		view.SoftwareSystemId = e.ids[x.Scope]
+		out.ContainerViews = append(out.ContainerViews, view)
+	}
+	for _, x := range v.ComponentViews {
+		view := e.view(x)
+		view.ContainerId = e.ids[x.Scope]
+		out.ComponentViews = append(out.ComponentViews, view)
+	}
+	for _, x := range v.DynamicViews {
+		view := e.view(x)
+		if x.Scope != nil {
+			view.ElementId = e.ids[x.Scope]
+		}
+
+		// every step is drawn as its own relationship in a dynamic view
+		view.Relationships = nil
+		for _, step := range x.Steps {
+			if step.Relationship == nil {
+				return nil, fmt.Errorf("error exporting view %s: step %s has not been checked", x.Key, step.Order)
+			}
+			view.Relationships = append(view.Relationships, &relationshipView{
+				Id:          e.ids[step.Relationship],
+				Order:       step.Order,
+				Description: step.Description,
+			})
+		}
+		out.DynamicViews = append(out.DynamicViews, view)
+	}
+	for _, x := range v.DeploymentViews {
+		view := e.view(x)
+		if x.Scope != nil {
+			view.SoftwareSystemId = e.ids[x.Scope]
+		}
+		view.Environment = x.Environment
+		if x.DeploymentEnvironment != nil {
+			view.Environment = environmentName(x.DeploymentEnvironment)
+		}
+		out.DeploymentViews = append(out.DeploymentViews, view)
+	}
+
+	if v.Styles != nil {
+		out.Configuration = &viewConfiguration{
+			Styles: exportStyles(v.Styles),
+			Themes: v.Styles.Themes,
+		}
+	}
+
+	return out, nil
+}
+
+func (e *exporter) view(v parser.View) *view {
+	base := v.Base()
+	out := &view{
+		Key:         base.Key,
+		Description: base.Description,
+	}
+
+	if layout := base.AutoLayout; layout != nil {
+		out.AutomaticLayout = &automaticLayout{
+			Implementation: "Graphviz",
+			RankDirection:  rankDirections[layout.Direction],
+			RankSeparation: layout.RankSeparation,
+			NodeSeparation: layout.NodeSeparation,
+		}
+	}
+
+	for _, x := range base.Elements {
+		out.Elements = append(out.Elements, &elementView{Id: e.ids[x]})
+	}
+	for _, r := range base.Relationships {
+		out.Relationships = append(out.Relationships, &relationshipView{Id: e.ids[r]})
+	}
+	sort.SliceStable(out.Relationships, func(i, j int) bool {
+		a, _ := strconv.Atoi(out.Relationships[i].Id)
+		b, _ := strconv.Atoi(out.Relationships[j].Id)
+		return a < b
+	})
+	return out
+}
+
+func exportStyles(s *parser.Styles) *styles {
+	if len(s.Elements) == 0 && len(s.Relationships) == 0 {
+		return nil
+	}
+
+	out := new(styles)
+	for _, style := range s.Elements {
+		out.Elements = append(out.Elements, &elementStyle{
+			Tag:         style.Tag,
+			Shape:       style.Shape,
+			Icon:        style.Icon,
+			Width:       style.Width,
+			Height:      style.Height,
+			Background:  style.Background,
+			Color:       style.Color,
+			Stroke:      style.Stroke,
+			StrokeWidth: style.StrokeWidth,
+			FontSize:    style.FontSize,
+			Border:      capitalise(style.Border),
+			Opacity:     style.Opacity,
+			Metadata:    style.Metadata,
+			Description: style.Description,
+		})
+	}
+	for _, style := range s.Relationships {
+		out.Relationships = append(out.Relationships, &relationshipStyle{
+			Tag:       style.Tag,
+			Thickness: style.Thickness,
+			Color:     style.Color,
+			Dashed:    style.Dashed,
+			Routing:   capitalise(style.Routing),
+			FontSize:  style.FontSize,
+			Width:     style.Width,
+			Position:  style.Position,
+			Opacity:   style.Opacity,
+		})
+	}
+	return out
+}
+
+// properties adds the identifier of an element to its own properties, the
+// same as Structurizr's DSL does. Identifiers the parser made up for
+// anonymous elements are left out.
+func properties(x parser.Entity) map[string]string {
+	props := make(map[string]string, len(x.Base().Properties)+1)
+	for k, v := range x.Base().Properties {
+		props[k] = v
+	}
+	if !strings.HasPrefix(string(x.Base().LocalId), "_") {
+		props[identifierProperty] = string(x.Id())
+	}
+	if len(props) == 0 {
+		return nil
+	}
+	return props
+}
+
+func perspectives(p map[string]string) []*perspective {
+	names := make([]string, 0, len(p))
+	for name := range p {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	var out []*perspective
+	for _, name := range names {
+		out = append(out, &perspective{Name: name, Description: p[name]})
+	}
+	return out
+}
+
+// deployment nodes refer to their environment by name
+func environmentName(env *parser.DeploymentEnvironment) string {
+	if env.Name != "" {
+		return env.Name
+	}
+	return string(env.Id())
+}
@@ -0,0 +1,552 @@
+package structurizr
+
+import (
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"strings"
+	"unicode"
+
+	"go.burian.dev/c4/cmd/compiler/internal/parser"
+)
+
+// Import reads a Structurizr workspace document into a workspace as the
+// parser would have produced it, ready to be checked.
+//
+// Elements keep the identifiers Structurizr's DSL recorded for them, or
+// are named after themselves otherwise. Relationships Structurizr implied or
+// replicated between instances are left for the checker to recreate, and
+// views include exactly the elements they list.
+func Import(source []byte) (*parser.Workspace, error) {
+	doc := new(workspace)
+	if err := json.Unmarshal(source, doc); err != nil {
+		return nil, fmt.Errorf("error reading Structurizr workspace:\n> %w", err)
+	}
+
+	im := &importer{
+		elements:      make(map[string]parser.IdentifierString),
+		relationships: make(map[string]*relationship),
+	}
+
+	w := new(parser.Workspace)
+	w.Name = doc.Name
+	w.Description = doc.Description
+
+	if doc.Model != nil {
+		m, err := im.model(doc.Model)
+		if err != nil {
+			return nil, fmt.Errorf("error importing model:\n> %w", err)
+		}
+		w.Model = m
+	}
+
+	if doc.Views != nil {
+		v, err := im.views(doc.Views)
+		if err != nil {
+			return nil, fmt.Errorf("error importing views:\n> %w", err)
+		}
+		w.Views = v
+	}
+
+	return w, nil
+}
+
+type importer struct {
+	// the fully qualified identifier given to each element, by its
+	// Structurizr identifier
+	elements map[string]parser.IdentifierString
+
+	// every relationship in the document, by its Structurizr identifier
+	relationships map[string]*relationship
+
+	// in the order they're found, to be resolved once every element has
+	// its identifier
+	pending []*relationship
+}
+
+// siblings are the identifiers already used beneath one parent
+type siblings map[parser.IdentifierString]bool
+
+func (im *importer) model(doc *model) (*parser.Model, error) {
+	m := new(parser.Model)
+	top := make(siblings)
+
+	for _, p := range doc.People {
+		person := new(parser.Person)
+		if err := im.element(m, person, p, "", top); err != nil {
+			return nil, err
+		}
+	}
+
+	for _, s := range doc.SoftwareSystems {
+		system := new(parser.SoftwareSystem)
+		if err := im.element(m, system, s, "", top); err != nil {
+			return nil, err
+		}
+
+		containers := make(siblings)
+		for _, c := range s.Containers {
+			container := new(parser.Container)
+			if err := im.element(system, container, c, system.LocalId, containers); err != nil {
+				return nil, err
+			}
+
+			components := make(siblings)
+			for _, k := range c.Components {
+				component := new(parser.Component)
+				if err := im.element(container, component, k, qualify(system.LocalId, container.LocalId), components); err != nil {
+					return nil, err
+				}
+			}
+		}
+	}
+
+	// environments are only a name on the deployment nodes in them
+	environments := make(map[string]*parser.DeploymentEnvironment)
+	envNodes := make(map[*parser.DeploymentEnvironment]siblings)
+	for _, n := range doc.DeploymentNodes {
+		name := n.Environment
+		if name == "" {
+			name = "Default"
+		}
+		env, has := environments[name]
+		if !has {
+			env = new(parser.DeploymentEnvironment)
+			env.Name = name
+			env.SetId(unique(environmentIdentifier(n, name), "", top))
+			if err := m.Add(env); err != nil {
+				return nil, err
+			}
+			environments[name] = env
+			envNodes[env] = make(siblings)
+		}
+
+		if err := im.deploymentNode(env, n, env.LocalId, envNodes[env]); err != nil {
+			return nil, err
+		}
+	}
+
+	for _, r := range im.pending {
+		if r.LinkedRelationshipId != "" {
+			continue
+		}
+
+		rel := new(parser.Relationship)
+		var has bool
+		if rel.SourceId, has = im.elements[r.SourceId]; !has {
+			return nil, fmt.Errorf("relationship %s is from unknown element %s", r.Id, r.SourceId)
+		}
+		if rel.DestinationId, has = im.elements[r.DestinationId]; !has {
+			return nil, fmt.Errorf("relationship %s is to unknown element %s", r.Id, r.DestinationId)
+		}
+		rel.Description = r.Description
+		rel.Technology = r.Technology
+		rel.Url = r.Url
+		rel.Tags = splitTags(rel, r.Tags)
+		rel.Properties = r.Properties
+		m.Relationships = append(m.Relationships, rel)
+	}
+
+	return m, nil
+}
+
+// element fills in an entity from its Structurizr element and adds it to
+// its parent, qualifying its identifier with the parent's
+func (im *importer) element(parent parser.ParentEntity, x parser.Entity, doc *element, parentId parser.IdentifierString, used siblings) error {
+	b := x.Base()
+	b.Name = doc.Name
+	b.Description = doc.Description
+	b.Technology = doc.Technology
+	b.Url = doc.Url
+	b.Group = doc.Group
+	b.Tags = splitTags(x, doc.Tags)
+	b.Properties, b.LocalId = withoutIdentifier(doc.Properties, doc.Name, doc.Id, used)
+	for _, p := range doc.Perspectives {
+		if b.Perspectives == nil {
+			b.Perspectives = make(map[string]string)
+		}
+		b.Perspectives[p.Name] = p.Description
+	}
+
+	return im.add(parent, x, doc.Id, parentId, doc.Relationships)
+}
+
+func (im *importer) deploymentNode(parent parser.ParentEntity, doc *deploymentNode, parentId parser.IdentifierString, used siblings) error {
+	node := new(parser.DeploymentNode)
+	if err := im.element(parent, node, &doc.element, parentId, used); err != nil {
+		return err
+	}
+	node.Instances = int(doc.Instances)
+	if node.Instances == 1 {
+		node.Instances = 0
+	}
+
+	id := qualify(parentId, node.LocalId)
+	children := make(siblings)
+
+	for _, child := range doc.Children {
+		if err := im.deploymentNode(node, child, id, children); err != nil {
+			return err
+		}
+	}
+
+	for _, infra := range doc.InfrastructureNodes {
+		if err := im.element(node, new(parser.InfrastructureNode), &infra.element, id, children); err != nil {
+			return err
+		}
+	}
+
+	// what instances deploy is in the static model, which is imported
+	// before any deployment nodes
+	for _, inst := range doc.ContainerInstances {
+		ci := new(parser.ContainerInstance)
+		if err := im.instance(node, ci, inst, inst.ContainerId, id, children); err != nil {
+			return err
+		}
+	}
+	for _, inst := range doc.SoftwareSystemInstances {
+		si := new(parser.SoftwareSystemInstance)
+		if err := im.instance(node, si, inst, inst.SoftwareSystemId, id, children); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func (im *importer) instance(parent parser.ParentEntity, x parser.Entity, doc *instance, deployed string, parentId parser.IdentifierString, used siblings) error {
+	target, has := im.elements[deployed]
+	if !has {
+		return fmt.Errorf("instance %s deploys unknown element %s", doc.Id, deployed)
+	}
+
+	switch inst := x.(type) {
+	case *parser.ContainerInstance:
+		inst.ContainerId = target
+	case *parser.SoftwareSystemInstance:
+		inst.SoftwareSystemId = target
+	}
+
+	b := x.Base()
+	b.Tags = splitTags(x, doc.Tags)
+	b.Properties, b.LocalId = withoutIdentifier(doc.Properties, string(local(target)), doc.Id, used)
+
+	return im.add(parent, x, doc.Id, parentId, doc.Relationships)
+}
+
+func (im *importer) add(parent parser.ParentEntity, x parser.Entity, docId string, parentId parser.IdentifierString, rels []*relationship) error {
+	if _, has := im.elements[docId]; has {
+		return fmt.Errorf("duplicate element identifier %s", docId)
+	}
+	im.elements[docId] = qualify(parentId, x.Base().LocalId)
+
+	for _, r := range rels {
+		if _, has := im.relationships[r.Id]; has {
+			return fmt.Errorf("duplicate relationship identifier %s", r.Id)
+		}
+		im.relationships[r.Id] = r
+		im.pending = append(im.pending, r)
+	}
+
+	return parent.Add(x)
+}
+
+func (im *importer) views(doc *views) (*parser.Views, error) {
+	v := new(parser.Views)
+
+	for _, d := range doc.SystemLandscapeViews {
+		view := new(parser.SystemLandscapeView)
+		if err := im.view(view.Base(), d); err != nil {
+			return nil, err
+		}
+		v.SystemLandscapeViews = append(v.SystemLandscapeViews, view)
+	}
+
+	for _, d := range doc.SystemContextViews {
+		view := new(parser.SystemContextView)
+		if err := im.view(view.Base(), d); err != nil {
+			return nil, err
+		}
+		id, err := im.scope(d, d.SoftwareSystemId)
+		if err != nil {
+			return nil, err
+		}
+		view.SoftwareSystemId = id
+		v.SystemContextViews = append(v.SystemContextViews, view)
+	}
+
+	for _, d := range doc.ContainerViews {
+		view := new(parser.ContainerView)
+		if err := im.view(view.Base(), d); err != nil {
+			return nil, err
+		}
+		id, err := im.scope(d, d.SoftwareSystemId)
+		if err != nil {
+			return nil, err
+		}
+		view.SoftwareSystemId = id
+		v.ContainerViews = append(v.ContainerViews, view)
+	}
+
+	for _, d := range doc.ComponentViews {
+		view := new(parser.ComponentView)
+		if err := im.view(view.Base(), d); err != nil {
+			return nil, err
+		}
+		id, err := im.scope(d, d.ContainerId)
+		if err != nil {
+			return nil, err
+		}
+		view.ContainerId = id
+		v.ComponentViews = append(v.ComponentViews, view)
+	}
+
+	for _, d := range doc.DynamicViews {
+		view, err := im.dynamicView(d)
+		if err != nil {
+			return nil, err
+		}
+		v.DynamicViews = append(v.DynamicViews, view)
+	}
+
+	for _, d := range doc.DeploymentViews {
+		view := new(parser.DeploymentView)
+		if err := im.view(view.Base(), d); err != nil {
+			return nil, err
+		}
+		if d.SoftwareSystemId != "" {
+			id, err := im.scope(d, d.SoftwareSystemId)
+			if err != nil {
+				return nil, err
+			}
+			view.SoftwareSystemId = id
+		}
+		view.Environment = d.Environment
+		if view.Environment == "" {
+			view.Environment = "Default"
+		}
+		v.DeploymentViews = append(v.DeploymentViews, view)
+	}
+
+	if doc.Configuration != nil {
+		v.Styles = importStyles(doc.Configuration)
+	}
+
+	return v, nil
+}
+
+// view fills in what's common to every view, including each of the
+// elements it lists
+func (im *importer) view(base *parser.BaseView, doc *view) error {
+	base.Key = doc.Key
+	base.Description = doc.Description
+
+	if layout := doc.AutomaticLayout; layout != nil {
+		base.AutoLayout = &parser.AutoLayout{
+			Direction:      parser.LayoutTopBottom,
+			RankSeparation: layout.RankSeparation,
+			NodeSeparation: layout.NodeSeparation,
+		}
+		for direction, name := range rankDirections {
+			if name == layout.RankDirection {
+				base.AutoLayout.Direction = direction
+			}
+		}
+	}
+
+	for _, e := range doc.Elements {
+		id, has := im.elements[e.Id]
+		if !has {
+			return fmt.Errorf("view %s shows unknown element %s", doc.Key, e.Id)
+		}
+		base.Include = append(base.Include, &parser.ViewExpression{Id: id})
+	}
+	return nil
+}
+
+func (im *importer) scope(doc *view, id string) (parser.IdentifierString, error) {
+	scope, has := im.elements[id]
+	if !has {
+		return "", fmt.Errorf("view %s is scoped to unknown element %s", doc.Key, id)
+	}
+	return scope, nil
+}
+
+// the steps of dynamic views are the relationships they list, in order
+func (im *importer) dynamicView(doc *view) (*parser.DynamicView, error) {
+	view := new(parser.DynamicView)
+	view.Key = doc.Key
+	view.Description = doc.Description
+	if err := im.view(view.Base(), doc); err != nil {
+		return nil, err
+	}
+	// the elements of a dynamic view follow from its steps
+	view.Include = nil
+
+	if doc.ElementId != "" {
+		id, err := im.scope(doc, doc.ElementId)
+		if err != nil {
+			return nil, err
+		}
+		view.ScopeId = id
+	}
+
+	for i, rv := range doc.Relationships {
+		r, has := im.relationships[rv.Id]
+		if !has {
+			return nil, fmt.Errorf("view %s shows unknown relationship %s", doc.Key, rv.Id)
+		}
+
+		step := &parser.DynamicStep{
+			Order:         rv.Order,
+			SourceId:      im.elements[r.SourceId],
+			DestinationId: im.elements[r.DestinationId],
+			Description:   rv.Description,
+		}
+		if step.Order == "" {
+			step.Order = strconv.Itoa(i + 1)
+		}
+		view.Steps = append(view.Steps, step)
+	}
+
+	return view, nil
+}
+
+func importStyles(doc *viewConfiguration) *parser.Styles {
+	s := new(parser.Styles)
+
+	s.Themes = append(s.Themes, doc.Themes...)
+	if doc.Theme != "" {
+		s.Themes = append(s.Themes, doc.Theme)
+	}
+
+	if doc.Styles == nil {
+		return s
+	}
+
+	for _, style := range doc.Styles.Elements {
+		s.Elements = append(s.Elements, &parser.ElementStyle{
+			Tag:         style.Tag,
+			Shape:       style.Shape,
+			Icon:        style.Icon,
+			Width:       style.Width,
+			Height:      style.Height,
+			Background:  style.Background,
+			Color:       style.Color,
+			Stroke:      style.Stroke,
+			StrokeWidth: style.StrokeWidth,
+			FontSize:    style.FontSize,
+			Border:      strings.ToLower(style.Border),
+			Opacity:     style.Opacity,
+			Metadata:    style.Metadata,
+			Description: style.Description,
+		})
+	}
+
+	for _, style := range doc.Styles.Relationships {
+		dashed := style.Dashed
+		if dashed == nil && style.Style != "" {
+			// newer workspaces give a line style instead
+			isDashed := style.Style != "Solid"
+			dashed = &isDashed
+		}
+		s.Relationships = append(s.Relationships, &parser.RelationshipStyle{
+			Tag:       style.Tag,
+			Thickness: style.Thickness,
+			Color:     style.Color,
+			Dashed:    dashed,
+			Routing:   strings.ToLower(style.Routing),
+			FontSize:  style.FontSize,
+			Width:     style.Width,
+			Position:  style.Position,
+			Opacity:   style.Opacity,
+		})
+	}
+
+	return s
+}
+
+// withoutIdentifier picks the identifier of an element, preferring the
+// one its DSL identifier property gives it, and returns its properties
+// without that property
+func withoutIdentifier(props map[string]string, name, docId string, used siblings) (map[string]string, parser.IdentifierString) {
+	dsl := props[identifierProperty]
+
+	var rest map[string]string
+	for k, v := range props {
+		if k == identifierProperty {
+			continue
+		}
+		if rest == nil {
+			rest = make(map[string]string)
+		}
+		rest[k] = v
+	}
+
+	return rest, unique(identifierFor(name, dsl), docId, used)
+}
+
+// identifierFor makes an identifier from the last part of a DSL
+// identifier, or from a name written in camel case
+func identifierFor(name, dsl string) parser.IdentifierString {
+	if dsl != "" {
+		return local(parser.IdentifierString(dsl))
+	}
+
+	var b strings.Builder
+	upper := false
+	for _, r := range name {
+		if !(r < unicode.MaxASCII && (unicode.IsLetter(r) || unicode.IsDigit(r))) {
+			upper = b.Len() > 0
+			continue
+		}
+		switch {
+		case b.Len() == 0:
+			r = unicode.ToLower(r)
+		case upper:
+			r = unicode.ToUpper(r)
+		}
+		upper = false
+		b.WriteRune(r)
+	}
+	return parser.IdentifierString(b.String())
+}
+
+// Structurizr has no identifiers for environments, but the DSL identifiers
+// of the nodes in them start with the environment's
+func environmentIdentifier(node *deploymentNode, name string) parser.IdentifierString {
+	dsl := node.Properties[identifierProperty]
+	if i := strings.IndexByte(dsl, '.'); i > 0 {
+		return parser.IdentifierString(dsl[:i])
+	}
+	return identifierFor(name, "")
+}
+
+// unique qualifies an identifier with the element's Structurizr identifier
+// where it's empty, starts with a number, or has already been used
+func unique(id parser.IdentifierString, docId string, used siblings) parser.IdentifierString {
+	if id == "" || unicode.IsDigit(rune(id[0])) || used[id] {
+		id = "element" + parser.IdentifierString(docId)
+	}
+	base := id
+	for n := 2; used[id]; n++ {
+		id = base + parser.IdentifierString(strconv.Itoa(n))
+	}
+	used[id] = true
+	return id
+}
+
+func qualify(parent, id parser.IdentifierString) parser.IdentifierString {
+	if parent == "" {
+		return id
+	}
+	return parent + "." + id
+}
+
+// local is the last part of a qualified identifier
+func local(id parser.IdentifierString) parser.IdentifierString {
+	if i := strings.LastIndexByte(string(id), '.'); i >= 0 {
+		return id[i+1:]
+	}
+	return id
+}
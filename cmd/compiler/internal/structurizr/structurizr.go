@@ -0,0 +1,278 @@
+package structurizr
+
+import (
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"go.burian.dev/c4/cmd/compiler/internal/parser"
+)
+
+// Extension is the file extension of Structurizr workspaces
+const Extension = ".json"
+
+// identifierProperty holds the DSL identifier of an element, which
+// Structurizr's own DSL writes into the workspaces it exports
+const identifierProperty = "structurizr.dsl.identifier"
+
+// The types below follow Structurizr's workspace JSON schema, covering the
+// parts of it that have a counterpart in a c4 workspace
+
+type workspace struct {
+	Name          string         `json:"name,omitempty"`
+	Description   string         `json:"description,omitempty"`
+	Model         *model         `json:"model"`
+	Views         *views         `json:"views"`
+	Configuration *configuration `json:"configuration"`
+}
+
+type configuration struct {
+	Scope string `json:"scope,omitempty"`
+}
+
+type model struct {
+	People          []*element        `json:"people,omitempty"`
+	SoftwareSystems []*element        `json:"softwareSystems,omitempty"`
+	DeploymentNodes []*deploymentNode `json:"deploymentNodes,omitempty"`
+}
+
+// element is any of a person, software system, container, or component,
+// each of which only uses the fields that apply to it
+type element struct {
+	Id           string            `json:"id"`
+	Tags         string            `json:"tags,omitempty"`
+	Name         string            `json:"name"`
+	Description  string            `json:"description,omitempty"`
+	Technology   string            `json:"technology,omitempty"`
+	Url          string            `json:"url,omitempty"`
+	Group        string            `json:"group,omitempty"`
+	Properties   map[string]string `json:"properties,omitempty"`
+	Perspectives []*perspective    `json:"perspectives,omitempty"`
+
+	Relationships []*relationship `json:"relationships,omitempty"`
+
+	Containers []*element `json:"containers,omitempty"`
+	Components []*element `json:"components,omitempty"`
+}
+
+type perspective struct {
+	Name        string `json:"name"`
+	Description string `json:"description"`
+}
+
+type deploymentNode struct {
+	element
+	Environment string `json:"environment"`
+	Instances   count  `json:"instances,omitempty"`
+
+	Children                []*deploymentNode `json:"children,omitempty"`
+	InfrastructureNodes     []*deploymentNode `json:"infrastructureNodes,omitempty"`
+	ContainerInstances      []*instance       `json:"containerInstances,omitempty"`
+	SoftwareSystemInstances []*instance       `json:"softwareSystemInstances,omitempty"`
+}
+
+type instance struct {
+	Id               string            `json:"id"`
+	Tags             string            `json:"tags,omitempty"`
+	ContainerId      string            `json:"containerId,omitempty"`
+	SoftwareSystemId string            `json:"softwareSystemId,omitempty"`
+	InstanceId       int               `json:"instanceId"`
+	Environment      string            `json:"environment"`
+	Properties       map[string]string `json:"properties,omitempty"`
+
+	Relationships []*relationship `json:"relationships,omitempty"`
+}
+
+type relationship struct {
+	Id            string `json:"id"`
+	Tags          string `json:"tags,omitempty"`
+	SourceId      string `json:"sourceId"`
+	DestinationId string `json:"destinationId"`
+	Description   string `json:"description,omitempty"`
+	Technology    string `json:"technology,omitempty"`
+	Url           string `json:"url,omitempty"`
+
+	// set on relationships Structurizr creates itself, either implied by
+	// another one or replicated between deployed instances
+	LinkedRelationshipId string `json:"linkedRelationshipId,omitempty"`
+
+	Properties map[string]string `json:"properties,omitempty"`
+}
+
+type views struct {
+	SystemLandscapeViews []*view `json:"systemLandscapeViews,omitempty"`
+	SystemContextViews   []*view `json:"systemContextViews,omitempty"`
+	ContainerViews       []*view `json:"containerViews,omitempty"`
+	ComponentViews       []*view `json:"componentViews,omitempty"`
+	DynamicViews         []*view `json:"dynamicViews,omitempty"`
+	DeploymentViews      []*view `json:"deploymentViews,omitempty"`
+
+	Configuration *viewConfiguration `json:"configuration,omitempty"`
+}
+
+type view struct {
+	Key              string `json:"key"`
+	Description      string `json:"description,omitempty"`
+	SoftwareSystemId string `json:"softwareSystemId,omitempty"`
+	ContainerId      string `json:"containerId,omitempty"`
+	ElementId        string `json:"elementId,omitempty"`
+	Environment      string `json:"environment,omitempty"`
+
+	AutomaticLayout *automaticLayout `json:"automaticLayout,omitempty"`
+
+	Elements      []*elementView      `json:"elements,omitempty"`
+	Relationships []*relationshipView `json:"relationships,omitempty"`
+}
+
+type automaticLayout struct {
+	Implementation string `json:"implementation"`
+	RankDirection  string `json:"rankDirection"`
+	RankSeparation int    `json:"rankSeparation"`
+	NodeSeparation int    `json:"nodeSeparation"`
+	EdgeSeparation int    `json:"edgeSeparation"`
+	Vertices       bool   `json:"vertices"`
+}
+
+type elementView struct {
+	Id string `json:"id"`
+}
+
+type relationshipView struct {
+	Id          string `json:"id"`
+	Order       string `json:"order,omitempty"`
+	Description string `json:"description,omitempty"`
+}
+
+type viewConfiguration struct {
+	Styles *styles  `json:"styles,omitempty"`
+	Themes []string `json:"themes,omitempty"`
+
+	// older workspaces have a single theme
+	Theme string `json:"theme,omitempty"`
+}
+
+type styles struct {
+	Elements      []*elementStyle      `json:"elements,omitempty"`
+	Relationships []*relationshipStyle `json:"relationships,omitempty"`
+}
+
+type elementStyle struct {
+	Tag         string `json:"tag"`
+	Shape       string `json:"shape,omitempty"`
+	Icon        string `json:"icon,omitempty"`
+	Width       int    `json:"width,omitempty"`
+	Height      int    `json:"height,omitempty"`
+	Background  string `json:"background,omitempty"`
+	Color       string `json:"color,omitempty"`
+	Stroke      string `json:"stroke,omitempty"`
+	StrokeWidth int    `json:"strokeWidth,omitempty"`
+	FontSize    int    `json:"fontSize,omitempty"`
+	Border      string `json:"border,omitempty"`
+	Opacity     *int   `json:"opacity,omitempty"`
+	Metadata    *bool  `json:"metadata,omitempty"`
+	Description *bool  `json:"description,omitempty"`
+}
+
+type relationshipStyle struct {
+	Tag       string `json:"tag"`
+	Thickness int    `json:"thickness,omitempty"`
+	Color     string `json:"color,omitempty"`
+	Dashed    *bool  `json:"dashed,omitempty"`
+	Style     string `json:"style,omitempty"`
+	Routing   string `json:"routing,omitempty"`
+	FontSize  int    `json:"fontSize,omitempty"`
+	Width     int    `json:"width,omitempty"`
+	Position  *int   `json:"position,omitempty"`
+	Opacity   *int   `json:"opacity,omitempty"`
+}
+
+// count is written as a number, but newer versions of Structurizr write
+// the instances of deployment nodes as strings
+type count int
+
+func (c *count) UnmarshalJSON(data []byte) error {
+	var n int
+	if err := json.Unmarshal(data, &n); err == nil {
+		*c = count(n)
+		return nil
+	}
+
+	var s string
+	if err := json.Unmarshal(data, &s); err != nil {
+		return fmt.Errorf("instances must be a number: %w", err)
+	}
+	if s == "" {
+		*c = 0
+		return nil
+	}
+	n, err := strconv.Atoi(s)
+	if err != nil {
+		return fmt.Errorf("unsupported instances %q: only a fixed number of instances is supported", s)
+	}
+	*c = count(n)
+	return nil
+}
+
+// implicitTags are the tags Structurizr gives everything of a type, which
+// c4 workspaces leave implicit
+func implicitTags(x any) []string {
+	switch x.(type) {
+	case *parser.Person:
+		return []string{"Element", "Person"}
+	case *parser.SoftwareSystem:
+		return []string{"Element", "Software System"}
+	case *parser.Container:
+		return []string{"Element", "Container"}
+	case *parser.Component:
+		return []string{"Element", "Component"}
+	case *parser.DeploymentNode:
+		return []string{"Element", "Deployment Node"}
+	case *parser.InfrastructureNode:
+		return []string{"Element", "Infrastructure Node"}
+	case *parser.ContainerInstance:
+		return []string{"Container Instance"}
+	case *parser.SoftwareSystemInstance:
+		return []string{"Software System Instance"}
+	case *parser.Relationship:
+		return []string{"Relationship"}
+	}
+	return nil
+}
+
+func joinTags(x any, tags []string) string {
+	return strings.Join(append(implicitTags(x), tags...), ",")
+}
+
+// splitTags returns the tags of x that aren't implied by its type
+func splitTags(x any, tags string) []string {
+	implicit := make(map[string]bool)
+	for _, tag := range implicitTags(x) {
+		implicit[tag] = true
+	}
+
+	var own []string
+	for _, tag := range strings.Split(tags, ",") {
+		tag = strings.TrimSpace(tag)
+		if tag == "" || implicit[tag] {
+			continue
+		}
+		own = append(own, tag)
+	}
+	return own
+}
+
+var rankDirections = map[string]string{
+	parser.LayoutTopBottom: "TopBottom",
+	parser.LayoutBottomTop: "BottomTop",
+	parser.LayoutLeftRight: "LeftRight",
+	parser.LayoutRightLeft: "RightLeft",
+}
+
+// Structurizr capitalises the values of borders and routing
+func capitalise(s string) string {
+	if s == "" {
+		return s
+	}
+	return strings.ToUpper(s[:1]) + s[1:]
+}
@@ -0,0 +1,251 @@
+package structurizr
+
+import (
+	"bytes"
+	"reflect"
+	"testing"
+
+	"go.burian.dev/c4/cmd/compiler/internal/checker"
+	"go.burian.dev/c4/cmd/compiler/internal/parser"
+)
+
+// a workspace as Structurizr writes it, without any DSL identifiers
+const bank = `{
+  "name": "Big Bank",
+  "model": {
+    "people": [
+      {
+        "id": "1",
+        "tags": "Element,Person,Customer",
+        "name": "Personal Banking Customer",
+        "relationships": [
+          {"id": "10", "tags": "Relationship", "sourceId": "1", "destinationId": "3", "description": "Uses", "technology": "https"},
+          {"id": "11", "tags": "Relationship", "sourceId": "1", "destinationId": "2", "description": "Uses", "technology": "https", "linkedRelationshipId": "10"}
+        ]
+      }
+    ],
+    "softwareSystems": [
+      {
+        "id": "2",
+        "tags": "Element,Software System",
+        "name": "Internet Banking System",
+        "group": "Big Bank plc",
+        "properties": {"owner": "web team"},
+        "containers": [
+          {
+            "id": "3",
+            "tags": "Element,Container",
+            "name": "Web Application",
+            "technology": "Java",
+            "relationships": [
+              {"id": "12", "tags": "Relationship,Async", "sourceId": "3", "destinationId": "4", "description": "Reads from", "technology": "JDBC"}
+            ]
+          },
+          {"id": "4", "tags": "Element,Container,Database", "name": "Database", "technology": "Oracle"}
+        ]
+      }
+    ],
+    "deploymentNodes": [
+      {
+        "id": "5",
+        "tags": "Element,Deployment Node",
+        "name": "Big Bank plc",
+        "environment": "Live",
+        "instances": "2",
+        "containerInstances": [
+          {
+            "id": "6",
+            "tags": "Container Instance",
+            "containerId": "3",
+            "instanceId": 1,
+            "environment": "Live",
+            "relationships": [
+              {"id": "13", "sourceId": "6", "destinationId": "7", "description": "Reads from", "technology": "JDBC", "linkedRelationshipId": "12"}
+            ]
+          },
+          {"id": "7", "tags": "Container Instance", "containerId": "4", "instanceId": 1, "environment": "Live"}
+        ]
+      }
+    ]
+  },
+  "views": {
+    "containerViews": [
+      {
+        "key": "Containers",
+        "softwareSystemId": "2",
+        "automaticLayout": {"implementation": "Graphviz", "rankDirection": "LeftRight", "rankSeparation": 200, "nodeSeparation": 100},
+        "elements": [{"id": "1"}, {"id": "3"}, {"id": "4"}],
+        "relationships": [{"id": "10"}, {"id": "12"}]
+      }
+    ],
+    "dynamicViews": [
+      {
+        "key": "SignIn",
+        "elementId": "2",
+        "relationships": [
+          {"id": "10", "order": "1", "description": "Signs in"},
+          {"id": "12", "order": "2"}
+        ]
+      }
+    ],
+    "deploymentViews": [
+      {"key": "LiveDeployment", "environment": "Live", "elements": [{"id": "5"}, {"id": "6"}, {"id": "7"}]}
+    ],
+    "configuration": {
+      "styles": {
+        "elements": [{"tag": "Database", "shape": "Cylinder", "border": "Dashed"}],
+        "relationships": [{"tag": "Async", "style": "Dashed", "routing": "Orthogonal"}]
+      }
+    }
+  }
+}`
+
+func importChecked(t *testing.T, doc []byte) *parser.Workspace {
+	t.Helper()
+
+	w, err := Import(doc)
+	if err != nil {
+		t.Fatalf("unexpected import error: %s", err)
+	}
+	if err := new(checker.Checker).CheckWorkspaces([]*parser.Workspace{w}, nil); err != nil {
+		t.Fatalf("unexpected check error: %s", err)
+	}
+	return w
+}
+
+func TestImport(t *testing.T) {
+	w := importChecked(t, []byte(bank))
+
+	var ids []parser.IdentifierString
+	var walk func(e parser.Entity)
+	walk = func(e parser.Entity) {
+		ids = append(ids, e.Id())
+		for _, child := range e.Base().Children() {
+			walk(child)
+		}
+	}
+	for _, e := range w.Model.Children() {
+		walk(e)
+	}
+	wantIds := []parser.IdentifierString{
+		"internetBankingSystem",
+		"internetBankingSystem.database",
+		"internetBankingSystem.webApplication",
+		"live",
+		"live.bigBankPlc",
+		"live.bigBankPlc.database",
+		"live.bigBankPlc.webApplication",
+		"personalBankingCustomer",
+	}
+	if !reflect.DeepEqual(ids, wantIds) {
+		t.Errorf("got identifiers %v, want %v", ids, wantIds)
+	}
+
+	system := w.Model.NamedEntities["internetBankingSystem"].Base()
+	if system.Group != "Big Bank plc" || system.Properties["owner"] != "web team" {
+		t.Errorf("system lost its group or properties: %+v", system)
+	}
+	customer := w.Model.NamedEntities["personalBankingCustomer"].Base()
+	if !reflect.DeepEqual(customer.Tags, []string{"Customer"}) {
+		t.Errorf("got customer tags %v, want only its own", customer.Tags)
+	}
+
+	// implied and replicated relationships are recreated by the checker
+	// rather than imported
+	var declared, implied, replicated int
+	for _, r := range w.Model.AllRelationships() {
+		switch {
+		case r.ImpliedBasedOn != nil:
+			implied++
+		case r.LinkedTo != nil:
+			replicated++
+		default:
+			declared++
+		}
+	}
+	if declared != 2 || implied != 1 || replicated != 1 {
+		t.Errorf("got %d declared, %d implied, and %d replicated relationships, want 2, 1, and 1", declared, implied, replicated)
+	}
+
+	node := w.Model.NamedEntities["live"].Base().NamedEntities["bigBankPlc"].(*parser.DeploymentNode)
+	if node.Instances != 2 {
+		t.Errorf("got %d instances, want 2", node.Instances)
+	}
+
+	containers := w.Views.ContainerViews[0]
+	if want := (&parser.AutoLayout{Direction: parser.LayoutLeftRight, RankSeparation: 200, NodeSeparation: 100}); !reflect.DeepEqual(containers.AutoLayout, want) {
+		t.Errorf("got layout %+v, want %+v", containers.AutoLayout, want)
+	}
+	if len(containers.Elements) != 3 || len(containers.Relationships) != 2 {
+		t.Errorf("got %d elements and %d relationships in the container view, want 3 and 2", len(containers.Elements), len(containers.Relationships))
+	}
+
+	steps := w.Views.DynamicViews[0].Steps
+	if len(steps) != 2 || steps[0].Description != "Signs in" || steps[1].Description != "Reads from" {
+		t.Errorf("got unexpected dynamic steps %+v", steps)
+	}
+
+	if got := len(w.Views.DeploymentViews[0].Elements); got != 3 {
+		t.Errorf("got %d elements in the deployment view, want 3", got)
+	}
+
+	styles := w.Views.Styles
+	if styles.Elements[0].Border != parser.BorderDashed {
+		t.Errorf("got border %s, want %s", styles.Elements[0].Border, parser.BorderDashed)
+	}
+	if rel := styles.Relationships[0]; rel.Dashed == nil || !*rel.Dashed || rel.Routing != parser.RoutingOrthogonal {
+		t.Errorf("got relationship style %+v, want dashed and orthogonal", rel)
+	}
+}
+
+func TestImportErrors(t *testing.T) {
+	tests := []struct {
+		name string
+		doc  string
+	}{
+		{
+			name: "not json",
+			doc:  `workspace {}`,
+		},
+		{
+			name: "unknown relationship destination",
+			doc:  `{"model": {"people": [{"id": "1", "name": "a", "relationships": [{"id": "2", "sourceId": "1", "destinationId": "3"}]}]}}`,
+		},
+		{
+			name: "unknown view element",
+			doc:  `{"model": {}, "views": {"systemLandscapeViews": [{"key": "a", "elements": [{"id": "1"}]}]}}`,
+		},
+		{
+			name: "ranged instances",
+			doc:  `{"model": {"deploymentNodes": [{"id": "1", "name": "a", "environment": "Live", "instances": "1..N"}]}}`,
+		},
+		{
+			name: "duplicate identifiers",
+			doc:  `{"model": {"people": [{"id": "1", "name": "a"}, {"id": "1", "name": "b"}]}}`,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := Import([]byte(tt.doc)); err == nil {
+				t.Errorf("expected an import error")
+			}
+		})
+	}
+}
+
+func TestExportRoundTrip(t *testing.T) {
+	first := new(bytes.Buffer)
+	if err := Export(first, importChecked(t, []byte(bank))); err != nil {
+		t.Fatalf("unexpected export error: %s", err)
+	}
+
+	second := new(bytes.Buffer)
+	if err := Export(second, importChecked(t, first.Bytes())); err != nil {
+		t.Fatalf("unexpected export error: %s", err)
+	}
+
+	if first.String() != second.String() {
+		t.Errorf("export changed after a round trip\nfirst:\n%s\nsecond:\n%s", first, second)
+	}
+}
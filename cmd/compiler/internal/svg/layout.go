@@ -0,0 +1,717 @@
+package svg
+
+import (
+	"math"
+	"sort"
+
+	"go.burian.dev/c4/cmd/compiler/internal/diagram"
+	"go.burian.dev/c4/cmd/compiler/internal/parser"
+)
+
+// The layout is a layered (Sugiyama) layout. Cycles are broken by reversing
+// edges, nodes are assigned to layers by their longest path from a source,
+// long edges are split by dummy nodes so every edge spans one layer, and
+// the order within layers is settled by repeated barycentre sweeps.
+//
+// Everything is calculated along a rank axis, which layers are stacked
+// along, and a cross axis, which the nodes of a layer are spread along,
+// and only mapped to x and y for the direction of the view at the end.
+
+const (
+	// the padding between a boundary and what's inside it
+	boundaryPadding = 40
+	// the extra space at the bottom of a boundary for its label
+	boundaryLabelHeight = 60
+
+	sweeps = 8
+)
+
+type point struct {
+	x, y float64
+}
+
+type rect struct {
+	x, y, width, height float64
+}
+
+func (r rect) centre() point {
+	return point{r.x + r.width/2, r.y + r.height/2}
+}
+
+type node struct {
+	// nil for the dummy nodes of long edges
+	element parser.Entity
+	box     *box
+
+	// the boundaries the node is drawn in, outermost first
+	groups []parser.Entity
+
+	layer    int
+	position int
+	up, down []*node
+
+	// the size and centre of the node along the cross and rank axes
+	crossSize, rankSize float64
+	cross, rank         float64
+
+	// where the node is drawn, once the layout is mapped to the view's
+	// direction
+	bounds rect
+}
+
+type edge struct {
+	from, to *node
+	label    string
+	rel      *parser.Relationship
+
+	orthogonal bool
+
+	// edges are reversed to break cycles, and drawn the other way around
+	reversed bool
+	dummies  []*node
+
+	// edges between boundaries aren't part of the layered layout, and
+	// are drawn between wherever their ends are placed
+	floatingFrom, floatingTo parser.Entity
+
+	points []point
+}
+
+type boundary struct {
+	entity  parser.Entity
+	nodes   []*node
+	members []*boundary
+
+	// true for boundaries around elements whose parent isn't in the view,
+	// rather than elements drawn with their children inside
+	implied bool
+
+	bounds rect
+}
+
+type layout struct {
+	direction string
+	nodeSep   float64
+	rankSep   float64
+
+	nodes      []*node
+	layers     [][]*node
+	edges      []*edge
+	boundaries []*boundary
+
+	nodeOf     map[parser.Entity]*node
+	boundaryOf map[parser.Entity]*boundary
+}
+
+func newLayout(direction string, nodeSep, rankSep float64) *layout {
+	return &layout{
+		direction:  direction,
+		nodeSep:    nodeSep,
+		rankSep:    rankSep,
+		nodeOf:     make(map[parser.Entity]*node),
+		boundaryOf: make(map[parser.Entity]*boundary),
+	}
+}
+
+// addGroups adds the elements of a view to the layout, sized by the given
+// function, remembering the boundaries they're drawn in
+func (l *layout) addGroups(groups []*diagram.Group, size func(parser.Entity) *box) {
+	var add func(g *diagram.Group, path []parser.Entity) *boundary
+	add = func(g *diagram.Group, path []parser.Entity) *boundary {
+		if g.Element != nil && len(g.Members) == 0 {
+			n := &node{element: g.Element, box: size(g.Element), groups: path}
+			l.nodes = append(l.nodes, n)
+			l.nodeOf[g.Element] = n
+			return nil
+		}
+
+		b := &boundary{entity: g.Element, implied: g.Element == nil}
+		if b.implied {
+			b.entity = g.Boundary
+		}
+		l.boundaryOf[b.entity] = b
+
+		inner := append(path[:len(path):len(path)], b.entity)
+		for _, member := range g.Members {
+			if child := add(member, inner); child != nil {
+				b.members = append(b.members, child)
+			} else {
+				b.nodes = append(b.nodes, l.nodeOf[member.Element])
+			}
+		}
+		return b
+	}
+
+	for _, g := range groups {
+		if b := add(g, nil); b != nil {
+			l.boundaries = append(l.boundaries, b)
+		}
+	}
+}
+
+func (l *layout) addEdge(src, dst parser.Entity, label string, rel *parser.Relationship) *edge {
+	e := &edge{from: l.nodeOf[src], to: l.nodeOf[dst], label: label, rel: rel}
+	if e.from == nil || e.to == nil || e.from == e.to {
+		e.from, e.to = nil, nil
+		e.floatingFrom, e.floatingTo = src, dst
+	}
+	l.edges = append(l.edges, e)
+	return e
+}
+
+func (l *layout) run() {
+	l.breakCycles()
+	l.assignLayers()
+	l.splitLongEdges()
+	l.orderLayers()
+	l.placeNodes()
+	l.mapToDirection()
+	l.placeBoundaries(l.boundaries)
+	l.routeEdges()
+	l.normalise()
+}
+
+func (l *layout) layered() []*edge {
+	var layered []*edge
+	for _, e := range l.edges {
+		if e.from != nil {
+			layered = append(layered, e)
+		}
+	}
+	return layered
+}
+
+// breakCycles reverses the edges that lead back to a node already on the
+// current path of a depth first search
+func (l *layout) breakCycles() {
+	outgoing := make(map[*node][]*edge)
+	for _, e := range l.layered() {
+		outgoing[e.from] = append(outgoing[e.from], e)
+	}
+
+	const (
+		unvisited = iota
+		visiting
+		visited
+	)
+	state := make(map[*node]int)
+
+	var visit func(n *node)
+	visit = func(n *node) {
+		state[n] = visiting
+		for _, e := range outgoing[n] {
+			switch state[e.to] {
+			case visiting:
+				e.reversed = true
+			case unvisited:
+				visit(e.to)
+			}
+		}
+		state[n] = visited
+	}
+
+	for _, n := range l.nodes {
+		if state[n] == unvisited {
+			visit(n)
+		}
+	}
+
+	for _, e := range l.layered() {
+		if e.reversed {
+			e.from, e.to = e.to, e.from
+		}
+	}
+}
+
+// assignLayers puts every node one layer below the lowest of the nodes
+// with edges to it
+func (l *layout) assignLayers() {
+	incoming := make(map[*node][]*node)
+	for _, e := range l.layered() {
+		incoming[e.to] = append(incoming[e.to], e.from)
+	}
+
+	done := make(map[*node]bool)
+	var layerOf func(n *node) int
+	layerOf = func(n *node) int {
+		if done[n] {
+			return n.layer
+		}
+		done[n] = true
+		for _, from := range incoming[n] {
+			if layer := layerOf(from) + 1; layer > n.layer {
+				n.layer = layer
+			}
+		}
+		return n.layer
+	}
+
+	for _, n := range l.nodes {
+		layerOf(n)
+		for len(l.layers) <= n.layer {
+			l.layers = append(l.layers, nil)
+		}
+		l.layers[n.layer] = append(l.layers[n.layer], n)
+	}
+}
+
+// splitLongEdges adds a dummy node on every layer a long edge crosses
+func (l *layout) splitLongEdges() {
+	for _, e := range l.layered() {
+		prev := e.from
+		for layer := e.from.layer + 1; layer < e.to.layer; layer++ {
+			dummy := &node{layer: layer}
+			l.layers[layer] = append(l.layers[layer], dummy)
+			e.dummies = append(e.dummies, dummy)
+			link(prev, dummy)
+			prev = dummy
+		}
+		link(prev, e.to)
+	}
+
+	for _, layer := range l.layers {
+		for i, n := range layer {
+			n.position = i
+		}
+	}
+}
+
+func link(from, to *node) {
+	from.down = append(from.down, to)
+	to.up = append(to.up, from)
+}
+
+// orderLayers sweeps down and up the layers, ordering each by the average
+// position of its neighbours in the layer before
+func (l *layout) orderLayers() {
+	for i := 0; i < sweeps; i++ {
+		if i%2 == 0 {
+			for layer := 1; layer < len(l.layers); layer++ {
+				sortLayer(l.layers[layer], func(n *node) []*node { return n.up })
+			}
+		} else {
+			for layer := len(l.layers) - 2; layer >= 0; layer-- {
+				sortLayer(l.layers[layer], func(n *node) []*node { return n.down })
+			}
+		}
+	}
+}
+
+// sortLayer orders a layer by barycentre, while keeping the members of each
+// boundary together so that boundaries don't overlap one another
+func sortLayer(layer []*node, neighbours func(*node) []*node) {
+	bary := make(map[*node]float64, len(layer))
+	for _, n := range layer {
+		bary[n] = float64(n.position)
+		if adjacent := neighbours(n); len(adjacent) > 0 {
+			sum := 0.0
+			for _, a := range adjacent {
+				sum += float64(a.position)
+			}
+			bary[n] = sum / float64(len(adjacent))
+		}
+	}
+
+	// the barycentre of a boundary is the average of its members
+	type groupKey struct {
+		depth  int
+		entity parser.Entity
+	}
+	groupSum := make(map[groupKey]float64)
+	groupCount := make(map[groupKey]int)
+	for _, n := range layer {
+		for depth, g := range n.groups {
+			key := groupKey{depth, g}
+			groupSum[key] += bary[n]
+			groupCount[key]++
+		}
+	}
+	keyAt := func(n *node, depth int) float64 {
+		if depth < len(n.groups) {
+			key := groupKey{depth, n.groups[depth]}
+			return groupSum[key] / float64(groupCount[key])
+		}
+		return bary[n]
+	}
+
+	sort.SliceStable(layer, func(i, j int) bool {
+		a, b := layer[i], layer[j]
+		depth := len(a.groups)
+		if len(b.groups) > depth {
+			depth = len(b.groups)
+		}
+		for d := 0; d < depth; d++ {
+			ka, kb := keyAt(a, d), keyAt(b, d)
+			if ka != kb {
+				return ka < kb
+			}
+			if d >= len(a.groups) || d >= len(b.groups) || a.groups[d] != b.groups[d] {
+				// different groups with the same barycentre
+				return groupOrder(a, d) < groupOrder(b, d)
+			}
+		}
+		if bary[a] != bary[b] {
+			return bary[a] < bary[b]
+		}
+		return a.position < b.position
+	})
+
+	for i, n := range layer {
+		n.position = i
+	}
+}
+
+// groupOrder breaks ties between groups with the same barycentre by the
+// identifier of the group, so that the result doesn't depend on the order
+// of the layer
+func groupOrder(n *node, depth int) string {
+	if depth < len(n.groups) {
+		return string(n.groups[depth].Id())
+	}
+	if n.element != nil {
+		return string(n.element.Id())
+	}
+	return ""
+}
+
+// placeNodes spreads every layer along the cross axis, pulling nodes towards
+// their neighbours, and stacks the layers along the rank axis
+func (l *layout) placeNodes() {
+	horizontal := l.direction == parser.LayoutLeftRight || l.direction == parser.LayoutRightLeft
+
+	for _, n := range l.allNodes() {
+		if n.box == nil {
+			continue
+		}
+		n.crossSize, n.rankSize = n.box.width, n.box.height
+		if horizontal {
+			n.crossSize, n.rankSize = n.box.height, n.box.width
+		}
+	}
+
+	for _, layer := range l.layers {
+		x := 0.0
+		for i, n := range layer {
+			if i > 0 {
+				x += l.gap(layer[i-1], n)
+			}
+			n.cross = x
+		}
+	}
+
+	for i := 0; i < sweeps; i++ {
+		if i%2 == 0 {
+			for layer := 1; layer < len(l.layers); layer++ {
+				l.pull(l.layers[layer], func(n *node) []*node { return n.up })
+			}
+		} else {
+			for layer := len(l.layers) - 2; layer >= 0; layer-- {
+				l.pull(l.layers[layer], func(n *node) []*node { return n.down })
+			}
+		}
+	}
+
+	rank := 0.0
+	for i, layer := range l.layers {
+		size := 0.0
+		for _, n := range layer {
+			size = math.Max(size, n.rankSize)
+		}
+		if i > 0 {
+			rank += l.rankSep
+		}
+		for _, n := range layer {
+			n.rank = rank + size/2
+		}
+		rank += size
+	}
+}
+
+// gap is the space needed between the centres of two adjacent nodes
+func (l *layout) gap(a, b *node) float64 {
+	sep := l.nodeSep
+	if a.box == nil || b.box == nil {
+		sep /= 4
+	}
+
+	// leave room for the boundaries either node is in but not the other
+	common := 0
+	for common < len(a.groups) && common < len(b.groups) && a.groups[common] == b.groups[common] {
+		common++
+	}
+	sep += boundaryPadding * float64(len(a.groups)-common+len(b.groups)-common)
+
+	return a.crossSize/2 + sep + b.crossSize/2
+}
+
+// pull moves the nodes of a layer towards the average of their neighbours,
+// keeping them in order and apart
+func (l *layout) pull(layer []*node, neighbours func(*node) []*node) {
+	if len(layer) == 0 {
+		return
+	}
+
+	desired := make([]float64, len(layer))
+	for i, n := range layer {
+		desired[i] = n.cross
+		if adjacent := neighbours(n); len(adjacent) > 0 {
+			sum := 0.0
+			for _, a := range adjacent {
+				sum += a.cross
+			}
+			desired[i] = sum / float64(len(adjacent))
+		}
+	}
+
+	placed := make([]float64, len(layer))
+	for i, n := range layer {
+		placed[i] = desired[i]
+		if i > 0 {
+			placed[i] = math.Max(desired[i], placed[i-1]+l.gap(layer[i-1], n))
+		}
+	}
+
+	// pushing nodes apart only ever moves them one way, so the whole
+	// layer is shifted back by the average it was pushed
+	shift := 0.0
+	for i := range layer {
+		shift += placed[i] - desired[i]
+	}
+	shift /= float64(len(layer))
+
+	for i, n := range layer {
+		n.cross = placed[i] - shift
+	}
+}
+
+func (l *layout) allNodes() []*node {
+	var all []*node
+	for _, layer := range l.layers {
+		all = append(all, layer...)
+	}
+	return all
+}
+
+// toPoint maps a position along the axes to the direction of the view
+func (l *layout) toPoint(cross, rank float64) point {
+	switch l.direction {
+	case parser.LayoutBottomTop:
+		return point{cross, -rank}
+	case parser.LayoutLeftRight:
+		return point{rank, cross}
+	case parser.LayoutRightLeft:
+		return point{-rank, cross}
+	}
+	return point{cross, rank}
+}
+
+func (l *layout) mapToDirection() {
+	for _, n := range l.allNodes() {
+		c := l.toPoint(n.cross, n.rank)
+		if n.box == nil {
+			n.bounds = rect{c.x, c.y, 0, 0}
+			continue
+		}
+		n.bounds = rect{c.x - n.box.width/2, c.y - n.box.height/2, n.box.width, n.box.height}
+	}
+}
+
+// placeBoundaries fits every boundary around what's inside it
+func (l *layout) placeBoundaries(boundaries []*boundary) {
+	for _, b := range boundaries {
+		l.placeBoundaries(b.members)
+
+		var inside []rect
+		for _, n := range b.nodes {
+			inside = append(inside, n.bounds)
+		}
+		for _, m := range b.members {
+			inside = append(inside, m.bounds)
+		}
+		if len(inside) == 0 {
+			continue
+		}
+
+		minX, minY := math.Inf(1), math.Inf(1)
+		maxX, maxY := math.Inf(-1), math.Inf(-1)
+		for _, r := range inside {
+			minX, minY = math.Min(minX, r.x), math.Min(minY, r.y)
+			maxX, maxY = math.Max(maxX, r.x+r.width), math.Max(maxY, r.y+r.height)
+		}
+
+		b.bounds = rect{
+			x:      minX - boundaryPadding,
+			y:      minY - boundaryPadding,
+			width:  maxX - minX + 2*boundaryPadding,
+			height: maxY - minY + 2*boundaryPadding + boundaryLabelHeight,
+		}
+	}
+}
+
+// bounds returns where an element was drawn, either as a node or a boundary
+func (l *layout) bounds(e parser.Entity) (rect, bool) {
+	if n, ok := l.nodeOf[e]; ok {
+		return n.bounds, true
+	}
+	if b, ok := l.boundaryOf[e]; ok {
+		return b.bounds, true
+	}
+	return rect{}, false
+}
+
+func (l *layout) routeEdges() {
+	for _, e := range l.edges {
+		if e.from == nil {
+			from, okFrom := l.bounds(e.floatingFrom)
+			to, okTo := l.bounds(e.floatingTo)
+			if okFrom && okTo {
+				e.points = []point{clip(from, to.centre()), clip(to, from.centre())}
+			}
+			continue
+		}
+
+		if e.orthogonal {
+			e.points = l.orthogonalRoute(e)
+		} else {
+			e.points = l.directRoute(e)
+		}
+
+		if e.reversed {
+			for i, j := 0, len(e.points)-1; i < j; i, j = i+1, j-1 {
+				e.points[i], e.points[j] = e.points[j], e.points[i]
+			}
+		}
+	}
+}
+
+// directRoute runs straight through the dummy nodes of the edge, from and
+// to the borders of the nodes at either end
+func (l *layout) directRoute(e *edge) []point {
+	points := []point{e.from.bounds.centre()}
+	for _, d := range e.dummies {
+		points = append(points, d.bounds.centre())
+	}
+	points = append(points, e.to.bounds.centre())
+
+	points[0] = clip(e.from.bounds, points[1])
+	last := len(points) - 1
+	points[last] = clip(e.to.bounds, points[last-1])
+	return points
+}
+
+// orthogonalRoute leaves and enters the nodes on the sides facing along the
+// rank axis, turning half way between layers
+func (l *layout) orthogonalRoute(e *edge) []point {
+	anchors := []*node{e.from}
+	anchors = append(anchors, e.dummies...)
+	anchors = append(anchors, e.to)
+
+	type axes struct{ cross, rank float64 }
+	var route []axes
+	route = append(route, axes{e.from.cross, e.from.rank + e.from.rankSize/2})
+	for i := 1; i < len(anchors); i++ {
+		prev := route[len(route)-1]
+		next := axes{anchors[i].cross, anchors[i].rank}
+		if i == len(anchors)-1 {
+			next.rank -= anchors[i].rankSize / 2
+		}
+		if prev.cross != next.cross {
+			mid := (prev.rank + next.rank) / 2
+			route = append(route, axes{prev.cross, mid}, axes{next.cross, mid})
+		}
+		route = append(route, next)
+	}
+
+	points := make([]point, len(route))
+	for i, r := range route {
+		points[i] = l.toPoint(r.cross, r.rank)
+	}
+	return points
+}
+
+// clip returns where a line from the centre of a rectangle towards a point
+// leaves the rectangle
+func clip(r rect, towards point) point {
+	c := r.centre()
+	dx, dy := towards.x-c.x, towards.y-c.y
+	if dx == 0 && dy == 0 || r.width == 0 && r.height == 0 {
+		return c
+	}
+
+	scale := math.Inf(1)
+	if dx != 0 {
+		scale = math.Min(scale, r.width/2/math.Abs(dx))
+	}
+	if dy != 0 {
+		scale = math.Min(scale, r.height/2/math.Abs(dy))
+	}
+	if scale > 1 {
+		scale = 1
+	}
+	return point{c.x + dx*scale, c.y + dy*scale}
+}
+
+// normalise moves everything so that the layout starts at the origin
+func (l *layout) normalise() {
+	minX, minY := math.Inf(1), math.Inf(1)
+	extend := func(r rect) {
+		minX, minY = math.Min(minX, r.x), math.Min(minY, r.y)
+	}
+	for _, n := range l.allNodes() {
+		extend(n.bounds)
+	}
+	for _, b := range l.allBoundaries() {
+		extend(b.bounds)
+	}
+	if math.IsInf(minX, 1) {
+		return
+	}
+
+	for _, n := range l.allNodes() {
+		n.bounds.x -= minX
+		n.bounds.y -= minY
+	}
+	for _, b := range l.allBoundaries() {
+		b.bounds.x -= minX
+		b.bounds.y -= minY
+	}
+	for _, e := range l.edges {
+		for i := range e.points {
+			e.points[i].x -= minX
+			e.points[i].y -= minY
+		}
+	}
+}
+
+// size is the extent of everything in the layout once normalised
+func (l *layout) size() (width, height float64) {
+	extend := func(r rect) {
+		width, height = math.Max(width, r.x+r.width), math.Max(height, r.y+r.height)
+	}
+	for _, n := range l.allNodes() {
+		extend(n.bounds)
+	}
+	for _, b := range l.allBoundaries() {
+		extend(b.bounds)
+	}
+	for _, e := range l.edges {
+		for _, p := range e.points {
+			extend(rect{p.x, p.y, 0, 0})
+		}
+	}
+	return width, height
+}
+
+// allBoundaries returns every boundary, outermost first
+func (l *layout) allBoundaries() []*boundary {
+	var all []*boundary
+	var walk func([]*boundary)
+	walk = func(bs []*boundary) {
+		for _, b := range bs {
+			all = append(all, b)
+			walk(b.members)
+		}
+	}
+	walk(l.boundaries)
+	return all
+}
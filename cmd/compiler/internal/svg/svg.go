@@ -0,0 +1,514 @@
+package svg
+
+import (
+	"bufio"
+	"fmt"
+	"html"
+	"io"
+	"math"
+	"strconv"
+	"strings"
+
+	"go.burian.dev/c4/cmd/compiler/internal/diagram"
+	"go.burian.dev/c4/cmd/compiler/internal/parser"
+)
+
+// Extension is the file extension of rendered views
+const Extension = ".svg"
+
+const (
+	defaultWidth      = 450
+	defaultHeight     = 300
+	defaultPersonSize = 400
+	defaultFontSize   = 24
+
+	margin      = 50
+	titleHeight = 120
+)
+
+// the default colours of the C4 model, for elements without styles
+var defaultBackgrounds = map[string]string{
+	"Person":          "#08427b",
+	"Software System": "#1168bd",
+	"Container":       "#438dd5",
+	"Component":       "#85bbf0",
+}
+
+// box is an element along with everything needed to draw it
+type box struct {
+	width, height float64
+	style         *parser.ElementStyle
+
+	name, kind, description string
+}
+
+// Render lays out a checked view and draws it as a standalone SVG, styled
+// by the styles of the workspace
+func Render(out io.Writer, w *parser.Workspace, view parser.View) error {
+	var styles *parser.Styles
+	if w.Views != nil {
+		styles = w.Views.Styles
+	}
+	base := view.Base()
+
+	direction := parser.LayoutTopBottom
+	nodeSep, rankSep := 300.0, 300.0
+	if base.AutoLayout != nil {
+		direction = base.AutoLayout.Direction
+		nodeSep = float64(base.AutoLayout.NodeSeparation)
+		rankSep = float64(base.AutoLayout.RankSeparation)
+	}
+
+	l := newLayout(direction, nodeSep, rankSep)
+	l.addGroups(diagram.GroupElements(base.Elements), func(e parser.Entity) *box {
+		return newBox(e, styles)
+	})
+
+	if dynamic, ok := view.(*parser.DynamicView); ok {
+		for _, step := range dynamic.Steps {
+			text := step.Order + ": " + step.Description
+			e := l.addEdge(step.Source, step.Destination, text, step.Relationship)
+			e.orthogonal = styles.RelationshipStyle(parser.TagsOf(step.Relationship)).Routing == parser.RoutingOrthogonal
+		}
+	} else {
+		for _, r := range base.Relationships {
+			e := l.addEdge(r.Source, r.Destination, r.Description, r)
+			e.orthogonal = styles.RelationshipStyle(parser.TagsOf(r)).Routing == parser.RoutingOrthogonal
+		}
+	}
+
+	l.run()
+
+	c := &canvas{w: bufio.NewWriter(out), styles: styles}
+	c.draw(l, view)
+	return c.w.Flush()
+}
+
+func newBox(e parser.Entity, styles *parser.Styles) *box {
+	tags := parser.TagsOf(e)
+
+	style := new(parser.ElementStyle)
+	for _, tag := range tags {
+		if background, has := defaultBackgrounds[tag]; has {
+			style.Background = background
+		}
+	}
+	style.Color = "#ffffff"
+	if style.Background == "" {
+		style.Background = "#ffffff"
+		style.Color = "#000000"
+	}
+	if style.Background == defaultBackgrounds["Component"] {
+		style.Color = "#000000"
+	}
+	if _, isPerson := e.(*parser.Person); isPerson {
+		style.Shape = parser.ShapePerson
+	}
+	for _, tag := range tags {
+		if tag == "Database" {
+			style.Shape = parser.ShapeCylinder
+		}
+	}
+	style.FontSize = defaultFontSize
+	style.StrokeWidth = 2
+
+	mergeStyle(style, styles.ElementStyle(tags))
+	if style.Stroke == "" {
+		style.Stroke = darken(style.Background)
+	}
+
+	b := &box{style: style, width: defaultWidth, height: defaultHeight}
+	if style.Shape == parser.ShapePerson {
+		b.width, b.height = defaultPersonSize, defaultPersonSize
+	}
+	if style.Width > 0 {
+		b.width = float64(style.Width)
+	}
+	if style.Height > 0 {
+		b.height = float64(style.Height)
+	}
+
+	base := e.Base()
+	b.name, b.description = base.Name, base.Description
+	technology := base.Technology
+	switch obj := e.(type) {
+	case *parser.Person:
+		b.kind = "Person"
+	case *parser.SoftwareSystem:
+		b.kind = "Software System"
+	case *parser.Container:
+		b.kind = "Container"
+	case *parser.Component:
+		b.kind = "Component"
+	case *parser.DeploymentNode:
+		b.kind = "Deployment Node"
+	case *parser.InfrastructureNode:
+		b.kind = "Infrastructure Node"
+	case *parser.ContainerInstance:
+		b.kind = "Container"
+		if obj.Container != nil {
+			deployed := obj.Container.Base()
+			b.name, b.description, technology = deployed.Name, deployed.Description, deployed.Technology
+		}
+	case *parser.SoftwareSystemInstance:
+		b.kind = "Software System"
+		if obj.SoftwareSystem != nil {
+			deployed := obj.SoftwareSystem.Base()
+			b.name, b.description, technology = deployed.Name, deployed.Description, deployed.Technology
+		}
+	}
+	if technology != "" {
+		b.kind += ": " + technology
+	}
+	if b.name == "" {
+		b.name = string(e.Id())
+	}
+	return b
+}
+
+// mergeStyle copies every property set in the style over the defaults
+func mergeStyle(into, style *parser.ElementStyle) {
+	merged := &parser.Styles{Elements: []*parser.ElementStyle{into}}
+	style.Tag = into.Tag
+	merged.Merge(&parser.Styles{Elements: []*parser.ElementStyle{style}})
+}
+
+type canvas struct {
+	w      *bufio.Writer
+	styles *parser.Styles
+}
+
+func (c *canvas) draw(l *layout, view parser.View) {
+	width, height := l.size()
+	width += 2 * margin
+	height += 2*margin + titleHeight
+
+	c.printf(`<svg xmlns="http://www.w3.org/2000/svg" width="%s" height="%s" viewBox="0 0 %s %s" font-family="Arial, Helvetica, sans-serif">`+"\n",
+		num(width), num(height), num(width), num(height))
+	c.printf(`<rect width="100%%" height="100%%" fill="#ffffff"/>` + "\n")
+	c.printf(`<g transform="translate(%d %d)">`+"\n", margin, margin)
+
+	for _, b := range l.allBoundaries() {
+		c.boundary(b)
+	}
+	for _, n := range l.nodes {
+		c.element(n.box, n.bounds)
+	}
+	for _, e := range l.edges {
+		c.edge(e)
+	}
+
+	c.printf("</g>\n")
+
+	base := view.Base()
+	title := base.Description
+	if title == "" {
+		title = base.Key
+	}
+	c.text(margin, height-margin-titleHeight/2, 36, "#000000", "start", true, title)
+	c.printf("</svg>\n")
+}
+
+func (c *canvas) boundary(b *boundary) {
+	name := b.entity.Base().Name
+	kind := ""
+	switch b.entity.(type) {
+	case *parser.SoftwareSystem:
+		kind = "Software System"
+	case *parser.Container:
+		kind = "Container"
+	case *parser.DeploymentNode:
+		kind = "Deployment Node"
+		if tech := b.entity.Base().Technology; tech != "" {
+			kind += ": " + tech
+		}
+	}
+
+	dash := ` stroke-dasharray="20 10"`
+	if !b.implied {
+		// deployment nodes are drawn as solid boxes around their contents
+		dash = ""
+	}
+
+	r := b.bounds
+	c.printf(`<rect x="%s" y="%s" width="%s" height="%s" rx="10" fill="none" stroke="#444444" stroke-width="2"%s/>`+"\n",
+		num(r.x), num(r.y), num(r.width), num(r.height), dash)
+
+	if n, ok := b.entity.(*parser.DeploymentNode); ok && n.Instances > 1 {
+		name = fmt.Sprintf("%s (x%d)", name, n.Instances)
+	}
+	c.text(r.x+20, r.y+r.height-boundaryLabelHeight+10, 24, "#444444", "start", true, name)
+	if kind != "" {
+		c.text(r.x+20, r.y+r.height-boundaryLabelHeight+36, 18, "#444444", "start", false, "["+kind+"]")
+	}
+}
+
+func (c *canvas) element(b *box, r rect) {
+	s := b.style
+	attrs := fmt.Sprintf(`fill="%s" stroke="%s" stroke-width="%d"`, s.Background, s.Stroke, s.StrokeWidth)
+	switch s.Border {
+	case parser.BorderDashed:
+		attrs += ` stroke-dasharray="15 8"`
+	case parser.BorderDotted:
+		attrs += ` stroke-dasharray="3 6"`
+	}
+	if s.Opacity != nil {
+		attrs += fmt.Sprintf(` opacity="%s"`, num(float64(*s.Opacity)/100))
+	}
+
+	// the area inside the shape that text is written in
+	textArea := r
+
+	switch s.Shape {
+	case parser.ShapePerson:
+		head := r.width / 5
+		c.printf(`<circle cx="%s" cy="%s" r="%s" %s/>`+"\n", num(r.x+r.width/2), num(r.y+head), num(head), attrs)
+		body := rect{r.x, r.y + head*1.8, r.width, r.height - head*1.8}
+		c.printf(`<rect x="%s" y="%s" width="%s" height="%s" rx="%s" %s/>`+"\n", num(body.x), num(body.y), num(body.width), num(body.height), num(head*0.8), attrs)
+		textArea = body
+
+	case parser.ShapeCylinder:
+		ry := r.height / 10
+		c.printf(`<path d="M %s %s A %s %s 0 0 1 %s %s L %s %s A %s %s 0 0 1 %s %s Z" %s/>`+"\n",
+			num(r.x), num(r.y+ry),
+			num(r.width/2), num(ry), num(r.x+r.width), num(r.y+ry),
+			num(r.x+r.width), num(r.y+r.height-ry),
+			num(r.width/2), num(ry), num(r.x), num(r.y+r.height-ry),
+			attrs)
+		c.printf(`<path d="M %s %s A %s %s 0 0 0 %s %s" fill="none" stroke="%s" stroke-width="%d"/>`+"\n",
+			num(r.x), num(r.y+ry), num(r.width/2), num(ry), num(r.x+r.width), num(r.y+ry), s.Stroke, s.StrokeWidth)
+		textArea = rect{r.x, r.y + 2*ry, r.width, r.height - 3*ry}
+
+	case parser.ShapeCircle, parser.ShapeEllipse:
+		c.printf(`<ellipse cx="%s" cy="%s" rx="%s" ry="%s" %s/>`+"\n", num(r.x+r.width/2), num(r.y+r.height/2), num(r.width/2), num(r.height/2), attrs)
+		textArea = rect{r.x + r.width/7, r.y + r.height/7, r.width * 5 / 7, r.height * 5 / 7}
+
+	case parser.ShapeHexagon:
+		q := r.width / 4
+		c.printf(`<polygon points="%s,%s %s,%s %s,%s %s,%s %s,%s %s,%s" %s/>`+"\n",
+			num(r.x+q), num(r.y), num(r.x+r.width-q), num(r.y), num(r.x+r.width), num(r.y+r.height/2),
+			num(r.x+r.width-q), num(r.y+r.height), num(r.x+q), num(r.y+r.height), num(r.x), num(r.y+r.height/2),
+			attrs)
+		textArea = rect{r.x + q/2, r.y, r.width - q, r.height}
+
+	case parser.ShapeDiamond:
+		c.printf(`<polygon points="%s,%s %s,%s %s,%s %s,%s" %s/>`+"\n",
+			num(r.x+r.width/2), num(r.y), num(r.x+r.width), num(r.y+r.height/2),
+			num(r.x+r.width/2), num(r.y+r.height), num(r.x), num(r.y+r.height/2),
+			attrs)
+		textArea = rect{r.x + r.width/4, r.y + r.height/4, r.width / 2, r.height / 2}
+
+	case parser.ShapeBox:
+		c.printf(`<rect x="%s" y="%s" width="%s" height="%s" %s/>`+"\n", num(r.x), num(r.y), num(r.width), num(r.height), attrs)
+
+	default:
+		c.printf(`<rect x="%s" y="%s" width="%s" height="%s" rx="15" %s/>`+"\n", num(r.x), num(r.y), num(r.width), num(r.height), attrs)
+	}
+
+	c.label(b, textArea)
+}
+
+// label writes the name, type, and description of an element, centred in
+// the given area
+func (c *canvas) label(b *box, area rect) {
+	s := b.style
+	size := float64(s.FontSize)
+	small := size * 0.75
+
+	type line struct {
+		text string
+		size float64
+		bold bool
+	}
+	lines := []line{}
+	for _, text := range wrap(b.name, area.width-20, size) {
+		lines = append(lines, line{text, size, true})
+	}
+	if s.Metadata == nil || *s.Metadata {
+		lines = append(lines, line{"[" + b.kind + "]", small, false})
+	}
+	if b.description != "" && (s.Description == nil || *s.Description) {
+		lines = append(lines, line{"", small / 2, false})
+		for _, text := range wrap(b.description, area.width-20, small) {
+			lines = append(lines, line{text, small, false})
+		}
+	}
+
+	total := 0.0
+	for _, l := range lines {
+		total += l.size * 1.2
+	}
+
+	y := area.y + (area.height-total)/2
+	for _, l := range lines {
+		y += l.size * 1.2
+		if l.text != "" {
+			c.text(area.x+area.width/2, y-l.size*0.25, l.size, s.Color, "middle", l.bold, l.text)
+		}
+	}
+}
+
+func (c *canvas) edge(e *edge) {
+	if len(e.points) < 2 {
+		return
+	}
+
+	var style *parser.RelationshipStyle
+	if e.rel != nil {
+		style = c.styles.RelationshipStyle(parser.TagsOf(e.rel))
+	} else {
+		style = c.styles.RelationshipStyle([]string{"Relationship"})
+	}
+
+	colour := "#707070"
+	if style.Color != "" {
+		colour = style.Color
+	}
+	thickness := 2
+	if style.Thickness > 0 {
+		thickness = style.Thickness
+	}
+	dash := ` stroke-dasharray="15 10"`
+	if style.Dashed != nil && !*style.Dashed {
+		dash = ""
+	}
+	opacity := ""
+	if style.Opacity != nil {
+		opacity = fmt.Sprintf(` opacity="%s"`, num(float64(*style.Opacity)/100))
+	}
+
+	path := new(strings.Builder)
+	for i, p := range e.points {
+		if i == 0 {
+			fmt.Fprintf(path, "M %s %s", num(p.x), num(p.y))
+			continue
+		}
+		fmt.Fprintf(path, " L %s %s", num(p.x), num(p.y))
+	}
+
+	c.printf(`<path d="%s" fill="none" stroke="%s" stroke-width="%d"%s%s/>`+"\n", path, colour, thickness, dash, opacity)
+	c.arrowhead(e.points[len(e.points)-2], e.points[len(e.points)-1], colour)
+
+	label := e.label
+	technology := ""
+	if e.rel != nil && e.rel.Technology != "" {
+		technology = "[" + e.rel.Technology + "]"
+	}
+	if label == "" && technology == "" {
+		return
+	}
+
+	fontSize := 20.0
+	if style.FontSize > 0 {
+		fontSize = float64(style.FontSize)
+	}
+	position := 50
+	if style.Position != nil {
+		position = *style.Position
+	}
+	at := along(e.points, float64(position)/100)
+
+	lines := wrap(label, 300, fontSize)
+	if technology != "" {
+		lines = append(lines, technology)
+	}
+	y := at.y - float64(len(lines)-1)*fontSize*0.6
+	for _, text := range lines {
+		c.printf(`<text x="%s" y="%s" font-size="%s" fill="%s" text-anchor="middle" stroke="#ffffff" stroke-width="6" paint-order="stroke">%s</text>`+"\n",
+			num(at.x), num(y), num(fontSize), colour, html.EscapeString(text))
+		y += fontSize * 1.2
+	}
+}
+
+func (c *canvas) arrowhead(from, to point, colour string) {
+	angle := math.Atan2(to.y-from.y, to.x-from.x)
+	const length, spread = 20.0, 0.4
+	left := point{to.x - length*math.Cos(angle-spread), to.y - length*math.Sin(angle-spread)}
+	right := point{to.x - length*math.Cos(angle+spread), to.y - length*math.Sin(angle+spread)}
+	c.printf(`<polygon points="%s,%s %s,%s %s,%s" fill="%s"/>`+"\n",
+		num(to.x), num(to.y), num(left.x), num(left.y), num(right.x), num(right.y), colour)
+}
+
+func (c *canvas) text(x, y, size float64, colour, anchor string, bold bool, text string) {
+	weight := ""
+	if bold {
+		weight = ` font-weight="bold"`
+	}
+	c.printf(`<text x="%s" y="%s" font-size="%s" fill="%s" text-anchor="%s"%s>%s</text>`+"\n",
+		num(x), num(y), num(size), colour, anchor, weight, html.EscapeString(text))
+}
+
+func (c *canvas) printf(format string, a ...any) {
+	fmt.Fprintf(c.w, format, a...)
+}
+
+// along returns the point the given fraction of the way along a line
+func along(points []point, fraction float64) point {
+	total := 0.0
+	for i := 1; i < len(points); i++ {
+		total += distance(points[i-1], points[i])
+	}
+
+	remaining := total * fraction
+	for i := 1; i < len(points); i++ {
+		d := distance(points[i-1], points[i])
+		if d >= remaining && d > 0 {
+			t := remaining / d
+			return point{
+				points[i-1].x + (points[i].x-points[i-1].x)*t,
+				points[i-1].y + (points[i].y-points[i-1].y)*t,
+			}
+		}
+		remaining -= d
+	}
+	return points[len(points)-1]
+}
+
+func distance(a, b point) float64 {
+	return math.Hypot(b.x-a.x, b.y-a.y)
+}
+
+// wrap breaks text into lines that fit the width, estimating the width of
+// each character from the font size
+func wrap(text string, width, fontSize float64) []string {
+	perLine := int(width / (fontSize * 0.55))
+	if perLine < 1 {
+		perLine = 1
+	}
+
+	var lines []string
+	for _, paragraph := range strings.Split(text, "\n") {
+		line := ""
+		for _, word := range strings.Fields(paragraph) {
+			if line != "" && len([]rune(line))+1+len([]rune(word)) > perLine {
+				lines = append(lines, line)
+				line = ""
+			}
+			if line != "" {
+				line += " "
+			}
+			line += word
+		}
+		lines = append(lines, line)
+	}
+	return lines
+}
+
+// darken returns a colour 30% darker, for the borders of elements
+func darken(colour string) string {
+	colour, ok := parser.NormalizeColour(colour)
+	if !ok {
+		return "#000000"
+	}
+	v, err := strconv.ParseUint(colour[1:], 16, 32)
+	if err != nil {
+		return "#000000"
+	}
+	r, g, b := (v>>16)&0xff, (v>>8)&0xff, v&0xff
+	return fmt.Sprintf("#%02x%02x%02x", r*7/10, g*7/10, b*7/10)
+}
+
+// numbers are rounded so that output is stable and readable
+func num(f float64) string {
+	return strconv.FormatFloat(math.Round(f*10)/10, 'f', -1, 64)
+}
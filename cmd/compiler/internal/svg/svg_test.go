@@ -0,0 +1,216 @@
+package svg
+
+import (
+	"strings"
+	"testing"
+
+	"go.burian.dev/c4/cmd/compiler/internal/diagram"
+	"go.burian.dev/c4/cmd/compiler/internal/parser"
+)
+
+func system(id string) *parser.SoftwareSystem {
+	s := &parser.SoftwareSystem{}
+	s.SetId(parser.IdentifierString(id))
+	s.SetFullyQualifiedId(parser.IdentifierString(id))
+	s.Name = id
+	return s
+}
+
+func overlaps(a, b rect) bool {
+	return a.x < b.x+b.width && b.x < a.x+a.width && a.y < b.y+b.height && b.y < a.y+a.height
+}
+
+func TestLayout(t *testing.T) {
+	a, b, c, d := system("a"), system("b"), system("c"), system("d")
+	elements := []parser.Entity{a, b, c, d}
+
+	size := func(parser.Entity) *box { return &box{width: 100, height: 50} }
+
+	tests := []struct {
+		name      string
+		direction string
+		edges     [][2]parser.Entity
+
+		// the entities expected to be strictly before the other, along
+		// the direction of the layout
+		before [][2]parser.Entity
+	}{
+		{
+			name:      "chain",
+			direction: parser.LayoutTopBottom,
+			edges:     [][2]parser.Entity{{a, b}, {b, c}, {a, d}},
+			before:    [][2]parser.Entity{{a, b}, {b, c}, {a, d}},
+		},
+		{
+			name:      "long edges",
+			direction: parser.LayoutTopBottom,
+			edges:     [][2]parser.Entity{{a, b}, {b, c}, {c, d}, {a, d}},
+			before:    [][2]parser.Entity{{a, b}, {b, c}, {c, d}},
+		},
+		{
+			name:      "cycle",
+			direction: parser.LayoutTopBottom,
+			edges:     [][2]parser.Entity{{a, b}, {b, c}, {c, a}},
+			before:    [][2]parser.Entity{{a, b}, {b, c}},
+		},
+		{
+			name:      "left to right",
+			direction: parser.LayoutLeftRight,
+			edges:     [][2]parser.Entity{{a, b}, {b, c}},
+			before:    [][2]parser.Entity{{a, b}, {b, c}},
+		},
+		{
+			name:      "bottom to top",
+			direction: parser.LayoutBottomTop,
+			edges:     [][2]parser.Entity{{a, b}, {b, c}},
+			before:    [][2]parser.Entity{{a, b}, {b, c}},
+		},
+		{
+			name:      "right to left",
+			direction: parser.LayoutRightLeft,
+			edges:     [][2]parser.Entity{{a, b}},
+			before:    [][2]parser.Entity{{a, b}},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			l := newLayout(tt.direction, 50, 50)
+			l.addGroups(diagram.GroupElements(elements), size)
+			for _, e := range tt.edges {
+				l.addEdge(e[0], e[1], "", nil)
+			}
+			l.run()
+
+			for i, n := range l.nodes {
+				if n.bounds.x < 0 || n.bounds.y < 0 {
+					t.Errorf("%s placed outside the layout at %v", n.element.Id(), n.bounds)
+				}
+				for _, other := range l.nodes[i+1:] {
+					if overlaps(n.bounds, other.bounds) {
+						t.Errorf("%s at %v overlaps %s at %v", n.element.Id(), n.bounds, other.element.Id(), other.bounds)
+					}
+				}
+			}
+
+			for _, pair := range tt.before {
+				first, second := l.nodeOf[pair[0]].bounds, l.nodeOf[pair[1]].bounds
+				var ordered bool
+				switch tt.direction {
+				case parser.LayoutTopBottom:
+					ordered = first.y+first.height <= second.y
+				case parser.LayoutBottomTop:
+					ordered = second.y+second.height <= first.y
+				case parser.LayoutLeftRight:
+					ordered = first.x+first.width <= second.x
+				case parser.LayoutRightLeft:
+					ordered = second.x+second.width <= first.x
+				}
+				if !ordered {
+					t.Errorf("expected %s at %v before %s at %v", pair[0].Id(), first, pair[1].Id(), second)
+				}
+			}
+
+			for _, e := range l.edges {
+				if len(e.points) < 2 {
+					t.Errorf("edge has no route: %v", e.points)
+				}
+			}
+		})
+	}
+}
+
+func TestRender(t *testing.T) {
+	shop := system("shop")
+	shop.Name = "Shop & Co"
+
+	web := &parser.Container{}
+	web.SetId("web")
+	web.SetFullyQualifiedId("shop.web")
+	web.SetParent(shop)
+	web.Name = "Web"
+	web.Technology = "go"
+	shop.Add(web)
+
+	customer := &parser.Person{}
+	customer.SetId("customer")
+	customer.SetFullyQualifiedId("customer")
+	customer.Name = "Customer"
+
+	browses := &parser.Relationship{Source: customer, Destination: web}
+	browses.Description = "Browses"
+
+	view := &parser.ContainerView{}
+	view.Key = "containers"
+	view.Elements = []parser.Entity{customer, web}
+	view.Relationships = []*parser.Relationship{browses}
+
+	buf := new(strings.Builder)
+	if err := Render(buf, &parser.Workspace{}, view); err != nil {
+		t.Fatalf("unexpected render error: %s", err)
+	}
+
+	got := buf.String()
+	for _, want := range []string{
+		`<svg xmlns="http://www.w3.org/2000/svg"`,
+		`>Shop &amp; Co</text>`,
+		`>Customer</text>`,
+		`>[Container: go]</text>`,
+		`>Browses</text>`,
+		`>containers</text>`,
+		`fill="#438dd5"`,
+		"</svg>\n",
+	} {
+		if !strings.Contains(got, want) {
+			t.Errorf("expected output to contain %q, got\n%s", want, got)
+		}
+	}
+}
+
+func TestNewBox_Shape(t *testing.T) {
+	db := &parser.Container{}
+	db.SetId("db")
+	db.SetFullyQualifiedId("shop.db")
+	db.Tags = []string{"Database"}
+
+	customer := &parser.Person{}
+	customer.SetId("customer")
+	customer.SetFullyQualifiedId("customer")
+
+	tests := []struct {
+		name   string
+		entity parser.Entity
+		styles *parser.Styles
+		want   string
+	}{
+		{
+			name:   "plain system",
+			entity: system("shop"),
+			want:   "",
+		},
+		{
+			name:   "person",
+			entity: customer,
+			want:   parser.ShapePerson,
+		},
+		{
+			name:   "database",
+			entity: db,
+			want:   parser.ShapeCylinder,
+		},
+		{
+			name:   "database styled otherwise",
+			entity: db,
+			styles: &parser.Styles{Elements: []*parser.ElementStyle{{Tag: "Database", Shape: parser.ShapeHexagon}}},
+			want:   parser.ShapeHexagon,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := newBox(tt.entity, tt.styles).style.Shape; got != tt.want {
+				t.Errorf("got shape %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,60 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"path/filepath"
+	"time"
+
+	"go.burian.dev/c4/cmd/compiler/internal/loader"
+)
+
+// runLock manages the lock file of the hashes of every source a target
+// fetches. Updating it fetches them all again, and records their hashes
+// afresh.
+func runLock(args []string) int {
+	flags := flag.NewFlagSet("lock", flag.ExitOnError)
+	remote := &remoteFlags{allow: true}
+	remote.register(flags)
+	flags.Usage = func() {
+		fmt.Fprintf(flags.Output(), "usage: %s lock update [-allow-hosts hosts] target.c4\n", filepath.Base(os.Args[0]))
+		flags.PrintDefaults()
+	}
+
+	if len(args) == 0 || args[0] != "update" {
+		flags.Usage()
+		return 2
+	}
+	flags.Parse(args[1:])
+
+	target := flags.Arg(0)
+	if target == "" {
+		flags.Usage()
+		return 2
+	}
+
+	// everything is fetched, since a cached source may be out of date
+	lock := loader.NewLock(lockPathFor(target))
+	comp := &compiler{
+		loader: remote.loader(lock),
+		lock:   lock,
+		logger: log.New(io.Discard, "", 0),
+	}
+	var cancel context.CancelFunc
+	comp.context, cancel = context.WithTimeout(context.Background(), time.Second*5)
+	defer cancel()
+
+	if _, err := comp.GetCheckedWorkspaceFor(target); err != nil {
+		fmt.Fprintf(os.Stderr, "error locking %s:\n> %s\n", target, comp.prettyPrintError(err))
+		return 1
+	}
+	if err := lock.Save(); err != nil {
+		fmt.Fprintf(os.Stderr, "error locking %s:\n> %s\n", target, err)
+		return 1
+	}
+	return 0
+}
@@ -0,0 +1,130 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"go.burian.dev/c4/cmd/compiler/internal/dot"
+	"go.burian.dev/c4/cmd/compiler/internal/mermaid"
+	"go.burian.dev/c4/cmd/compiler/internal/parser"
+	"go.burian.dev/c4/cmd/compiler/internal/plantuml"
+	"go.burian.dev/c4/cmd/compiler/internal/structurizr"
+	"go.burian.dev/c4/cmd/compiler/internal/svg"
+)
+
+const formatJson = "json"
+
+// viewFormat renders each view of a workspace to its own file
+type viewFormat struct {
+	extension string
+	render    func(io.Writer, *parser.Workspace, parser.View) error
+}
+
+var viewFormats = map[string]viewFormat{
+	"plantuml": {plantuml.Extension, plantuml.Render},
+	"mermaid":  {mermaid.Extension, mermaid.Render},
+	"dot":      {dot.Extension, dot.Render},
+	"svg":      {svg.Extension, svg.Render},
+}
+
+// workspaceFormat renders a whole workspace to a single file
+type workspaceFormat struct {
+	extension string
+	render    func(io.Writer, *parser.Workspace) error
+}
+
+var workspaceFormats = map[string]workspaceFormat{
+	"dot-model":   {dot.Extension, dot.RenderModel},
+	"structurizr": {structurizr.Extension, structurizr.Export},
+}
+
+func outputFormats() []string {
+	formats := []string{formatJson}
+	for format := range viewFormats {
+		formats = append(formats, format)
+	}
+	for format := range workspaceFormats {
+		formats = append(formats, format)
+	}
+	sort.Strings(formats[1:])
+	return formats
+}
+
+func defaultOutput(format string) string {
+	if _, perView := viewFormats[format]; perView {
+		return "out"
+	}
+	if whole, ok := workspaceFormats[format]; ok {
+		return "out" + whole.extension
+	}
+	return "out.c4m"
+}
+
+// writeViews writes every view into the output directory, named by its key
+func (c *compiler) writeViews(w *parser.Workspace, format viewFormat) error {
+	if w.Views == nil {
+		return fmt.Errorf("workspace has no views to write")
+	}
+
+	if err := os.MkdirAll(c.outputFile, 0o755); err != nil {
+		return err
+	}
+
+	// keys that only differ in what's replaced would overwrite each other
+	written := make(map[string]string)
+	for _, view := range w.Views.All() {
+		key := view.Base().Key
+		name := filepath.Join(c.outputFile, viewFileName(key)+format.extension)
+		if other, has := written[name]; has {
+			return fmt.Errorf("views %s and %s would both be written to %s, give one a different key", other, key, name)
+		}
+		written[name] = key
+
+		if err := writeView(name, w, view, format); err != nil {
+			return fmt.Errorf("error writing view %s:\n> %w", key, err)
+		}
+	}
+	return nil
+}
+
+func (c *compiler) writeWorkspace(w *parser.Workspace, format workspaceFormat) error {
+	file, err := os.Create(c.outputFile)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	if err := format.render(file, w); err != nil {
+		return err
+	}
+	return file.Close()
+}
+
+func writeView(name string, w *parser.Workspace, view parser.View, format viewFormat) error {
+	file, err := os.Create(name)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	if err := format.render(file, w, view); err != nil {
+		return err
+	}
+	return file.Close()
+}
+
+// keys may be any string, so anything that could escape the output
+// directory is replaced
+func viewFileName(key string) string {
+	return strings.Map(func(r rune) rune {
+		switch r {
+		case '/', '\\', ':', 0:
+			return '_'
+		}
+		return r
+	}, strings.TrimLeft(key, "."))
+}
@@ -0,0 +1,54 @@
+package main
+
+import (
+	"flag"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"go.burian.dev/c4/cmd/compiler/internal/loader"
+)
+
+// remoteFlags are how sources may be fetched from remote hosts, for the
+// commands that compile
+type remoteFlags struct {
+	allow    bool
+	hosts    string
+	cacheDir string
+	offline  bool
+}
+
+func (rf *remoteFlags) register(flags *flag.FlagSet) {
+	flags.StringVar(&rf.hosts, "allow-hosts", "", "a comma separated list of the only hosts #fetch may load from")
+}
+
+// loader gives a loader that fetches as the flags allow, checking what it
+// fetches against the lock
+func (rf *remoteFlags) loader(lock *loader.Lock) loader.Loader {
+	var hosts []string
+	if rf.hosts != "" {
+		hosts = strings.Split(rf.hosts, ",")
+	}
+	l := loader.NewLoader(loader.AllowRemote(), loader.AllowedRemoteHosts(hosts...), loader.LockedBy(lock))
+
+	if rf.cacheDir != "" && rf.offline {
+		l = loader.NewCachingLoader(l, rf.cacheDir, loader.Offline())
+	} else if rf.cacheDir != "" {
+		l = loader.NewCachingLoader(l, rf.cacheDir)
+	}
+	return l
+}
+
+// defaultCacheDir is where fetched sources are cached, unless told otherwise
+func defaultCacheDir() string {
+	dir, err := os.UserCacheDir()
+	if err != nil {
+		return ""
+	}
+	return filepath.Join(dir, "c4")
+}
+
+// lockPathFor gives where the lock file for a target is kept, next to it
+func lockPathFor(target string) string {
+	return filepath.Join(filepath.Dir(target), loader.LockFileName)
+}
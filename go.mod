@@ -2,4 +2,4 @@ module go.burian.dev/c4
 
 go 1.20
 
-require golang.org/x/tools v0.8.0 // indirect
+require golang.org/x/tools v0.8.0